@@ -193,7 +193,10 @@ update_subscription() {
   # -sub: 订阅链接
   # -format xray: 输出 xray 格式
   # -dir: 输出目录 (每个节点单独一个文件)
-  if "$MODDIR/bin/proxylink" -sub "$url" -insecure -dns -format xray -dir "$sub_dir" >> "$LOG_FILE" 2>&1; then
+  # -dns: 已启用节点域名预解析 (有 dns_bootstrap.json) 时，沿用其设置预解析本订阅的节点
+  local dns_opt=""
+  [ -f "$MODDIR/config/dns_bootstrap.json" ] && dns_opt="-dns"
+  if "$MODDIR/bin/proxylink" -sub "$url" -insecure $dns_opt -format xray -dir "$sub_dir" >> "$LOG_FILE" 2>&1; then
     log "INFO" "订阅更新完成"
     echo "已导入节点"
   else
@@ -93,6 +93,24 @@ do_start() {
   [ -f "$outbound_config" ] || die "出站配置文件不存在: $outbound_config"
   [ -d "$CONFDIR" ] || die "confdir 目录不存在: $CONFDIR"
 
+  # 启用 FakeDNS 时按 02_dns.json、03_inbounds.json 的当前内容重新生成片段
+  if [ -f "$CONFDIR/02_dns_fakedns.json" ]; then
+    "$MODDIR/bin/proxylink" fakedns on -module "$MODDIR" >> "$LOG_FILE" 2>&1 \
+      || log "WARN" "FakeDNS 片段重新生成失败，沿用上次结果"
+  fi
+
+  # 应用分组: 按当前 packages.list 重新解析 UID (重装应用后 UID 会变化)，生成分组入站和映射
+  if [ -f "$MODDIR/config/app_groups.json" ]; then
+    "$MODDIR/bin/proxylink" appgroup apply -module "$MODDIR" >> "$LOG_FILE" 2>&1 \
+      || log "WARN" "应用分组生成失败，沿用上次结果"
+  fi
+
+  # 刷新节点域名预解析 (订阅以 -dns 生成时才有记录)，此时透明代理尚未启用
+  if [ -f "$MODDIR/config/dns_bootstrap.json" ]; then
+    "$MODDIR/bin/proxylink" dns refresh -module "$MODDIR" >> "$LOG_FILE" 2>&1 \
+      || log "WARN" "节点域名预解析刷新失败，沿用上次结果"
+  fi
+
   log "INFO" "配置目录: $CONFDIR"
   log "INFO" "路由配置: $routing_config"
   log "INFO" "出站配置: $outbound_config"
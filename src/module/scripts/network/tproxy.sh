@@ -381,13 +381,13 @@ validate_config() {
         return 1
     fi
 
-    if ! echo "$MARK_VALUE" | grep -E '^[0-9]+$' > /dev/null || [ "$MARK_VALUE" -lt 1 ] || [ "$MARK_VALUE" -gt 2147483647 ]; then
-        log Error "Invalid MARK_VALUE: $MARK_VALUE"
+    if ! echo "$MARK_VALUE" | grep -E '^(0[xX][0-9a-fA-F]{1,8}|[1-9][0-9]*)$' > /dev/null || [ "$((MARK_VALUE))" -lt 1 ] || [ "$((MARK_VALUE))" -gt 4294967295 ]; then
+        log Error "Invalid MARK_VALUE: $MARK_VALUE (decimal or 0x hex, 1-0xffffffff)"
         return 1
     fi
 
-    if ! echo "$MARK_VALUE6" | grep -E '^[0-9]+$' > /dev/null || [ "$MARK_VALUE6" -lt 1 ] || [ "$MARK_VALUE6" -gt 2147483647 ]; then
-        log Error "Invalid MARK_VALUE6: $MARK_VALUE6"
+    if ! echo "$MARK_VALUE6" | grep -E '^(0[xX][0-9a-fA-F]{1,8}|[1-9][0-9]*)$' > /dev/null || [ "$((MARK_VALUE6))" -lt 1 ] || [ "$((MARK_VALUE6))" -gt 4294967295 ]; then
+        log Error "Invalid MARK_VALUE6: $MARK_VALUE6 (decimal or 0x hex, 1-0xffffffff)"
         return 1
     fi
 
@@ -594,6 +594,17 @@ find_packages_uid() {
     local token
     local uid_base
     local final_uid
+    # Prefer proxylink from the module, which also handles shared UIDs, and fall back to packages.list lookups
+    local proxylink="$SCRIPT_DIR/../../bin/proxylink"
+    if [ -x "$proxylink" ]; then
+        if out=$("$proxylink" apps resolve -uids "$@"); then
+            log Info "Resolved packages $* to UIDs $out"
+            echo "$out"
+            return 0
+        fi
+        log Warn "proxylink failed to resolve $*, falling back to packages.list lookup"
+        out=""
+    fi
     for token in "$@"; do
         local user_prefix=0
         local package="$token"
@@ -1138,6 +1149,9 @@ setup_proxy_chain() {
 
     local uids
     local uid
+    # App groups go first so that group apps are not caught by the blacklist/whitelist
+    setup_app_groups "$family" "$mode" uid
+
     if [ "$APP_PROXY_ENABLE" -eq 1 ]; then
         if check_kernel_feature "NETFILTER_XT_MATCH_OWNER"; then
             log Info "Setting up application filter rules in $APP_PROXY_MODE mode"
@@ -1192,6 +1206,8 @@ setup_proxy_chain() {
         fi
     fi
 
+    setup_app_groups "$family" "$mode" port
+
     if [ "$PERFORMANCE_MODE" -eq 1 ] && check_kernel_feature "NETFILTER_XT_MATCH_CONNTRACK"; then
         if [ "$mode" = "tproxy" ]; then
             $cmd -t "$table" -A "PROXY_PREROUTING$suffix" -m conntrack --ctstate NEW,RELATED -j CONNMARK --set-mark "$mark"
@@ -1237,6 +1253,93 @@ setup_proxy_chain() {
     log Info "$mode_name chains for IPv${family} setup completed"
 }
 
+# The group port doubles as the connmark value in TPROXY mode, so it must not
+# match MARK_VALUE, MARK_VALUE6 or ROUTING_MARK (compared under its mask)
+app_group_mark_clash() {
+    local port="$1"
+    local value="${ROUTING_MARK%%/*}"
+    local mask=4294967295
+
+    [ "$port" -eq "$((MARK_VALUE))" ] && return 0
+    [ "$port" -eq "$((MARK_VALUE6))" ] && return 0
+    [ -n "$ROUTING_MARK" ] || return 1
+    case "$ROUTING_MARK" in
+        */*) mask="${ROUTING_MARK#*/}" ;;
+    esac
+    [ "$((port & mask))" -eq "$((value & mask))" ]
+}
+
+setup_app_groups() {
+    local family="$1"
+    local mode="$2"
+    local stage="$3" # uid: match group apps in APP_CHAIN, port: send group connections to group ports
+    local suffix=""
+    local mark="$MARK_VALUE"
+    local cmd="iptables"
+    local file="$CONFIG_DIR/app_groups.conf"
+
+    if [ "$family" = "6" ]; then
+        suffix="6"
+        mark="$MARK_VALUE6"
+        cmd="ip6tables"
+    fi
+
+    # Generated by `proxylink appgroup apply`, one "uid port group app" entry per line
+    [ -f "$file" ] || return 0
+    [ "$mode" = "redirect" ] && [ "$stage" = "port" ] && return 0
+
+    if ! check_kernel_feature "NETFILTER_XT_MATCH_OWNER"; then
+        [ "$stage" = "uid" ] && log Warn "App groups require NETFILTER_XT_MATCH_OWNER kernel feature which is not available"
+        return 0
+    fi
+    if [ "$mode" = "tproxy" ] && ! check_kernel_feature "NETFILTER_XT_CONNMARK"; then
+        [ "$stage" = "uid" ] && log Warn "App groups in TPROXY mode require NETFILTER_XT_CONNMARK kernel feature which is not available"
+        return 0
+    fi
+
+    local uid
+    local port
+    local group
+    local app
+    local ports=""
+    while read -r uid port group app; do
+        case "$uid" in
+            '' | \#*) continue ;;
+        esac
+        case "$uid$port" in
+            *[!0-9]*)
+                log Warn "Invalid app group entry: $uid $port $group"
+                continue
+                ;;
+        esac
+        if [ "$mode" = "tproxy" ] && app_group_mark_clash "$port"; then
+            [ "$stage" = "uid" ] && log Warn "App group $group port $port clashes with MARK_VALUE, MARK_VALUE6 or ROUTING_MARK, skipped"
+            continue
+        fi
+
+        if [ "$stage" = "uid" ]; then
+            if [ "$mode" = "redirect" ]; then
+                $cmd -t nat -A "APP_CHAIN$suffix" -m owner --uid-owner "$uid" -j REDIRECT --to-ports "$port"
+            else
+                # The group port doubles as the connmark value, distinct from MARK_VALUE
+                $cmd -t mangle -A "APP_CHAIN$suffix" -m owner --uid-owner "$uid" -j CONNMARK --set-mark "$port"
+                $cmd -t mangle -A "APP_CHAIN$suffix" -m owner --uid-owner "$uid" -j RETURN
+            fi
+            log Info "Added app group $group for UID $uid ($app) on port $port"
+        else
+            case " $ports " in
+                *" $port "*) continue ;;
+            esac
+            ports="$ports $port"
+            $cmd -t mangle -A "PROXY_PREROUTING$suffix" -p tcp -m connmark --mark "$port" -j TPROXY --on-port "$port" --tproxy-mark "$mark"
+            $cmd -t mangle -A "PROXY_PREROUTING$suffix" -p udp -m connmark --mark "$port" -j TPROXY --on-port "$port" --tproxy-mark "$mark"
+            $cmd -t mangle -A "PROXY_OUTPUT$suffix" -m connmark --mark "$port" -j MARK --set-mark "$mark"
+            $cmd -t mangle -A "PROXY_OUTPUT$suffix" -m connmark --mark "$port" -j ACCEPT
+            log Info "Added TPROXY rules for app group $group on port $port"
+        fi
+    done < "$file"
+}
+
 setup_dns_hijack() {
     local family="$1"
     local mode="$2"
@@ -1768,6 +1871,7 @@ Options:
       Files that may be read from or written to in this directory:
       • tproxy.conf          (optional) user configuration overrides
       • runtime_tproxy.conf  (generated/used during runtime for cleanup)
+      • app_groups.conf      (optional) app group UID → port map (proxylink appgroup apply)
       • cn.zone              (China IPv4 CIDR list, auto-downloaded if missing/old)
       • cn_ipv6.zone         (China IPv6 CIDR list, auto-downloaded if IPv6 enabled)
       • tmp/                 (temporary subdirectory for mktemp files, downloads, etc.)
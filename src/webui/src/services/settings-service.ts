@@ -78,17 +78,18 @@ export interface DnsServerConfig {
   [key: string]: unknown;
 }
 
-/** 路由规则接口 */
+/** 路由规则接口 (proxylink 写入的规则中列表字段为数组) */
 export interface RoutingRule {
   name?: string;
   type?: string;
-  domain?: string;
-  ip?: string;
+  domain?: string | string[];
+  ip?: string | string[];
   port?: string;
-  protocol?: string;
+  protocol?: string | string[];
   network?: string;
-  inboundTag?: string;
+  inboundTag?: string | string[];
   outboundTag?: string;
+  balancerTag?: string;
   enabled?: boolean;
   visible?: boolean;
 }
@@ -102,7 +103,14 @@ interface XrayRule {
   protocol?: string[];
   network?: string;
   inboundTag?: string[];
-  outboundTag: string;
+  outboundTag?: string;
+  balancerTag?: string;
+}
+
+/** 逗号分隔字符串或数组转为列表 */
+function toList(value: string | string[]): string[] {
+  const items = Array.isArray(value) ? value : value.split(",");
+  return items.map((v) => v.trim()).filter((v) => v !== "");
 }
 
 /** 操作结果接口 */
@@ -434,20 +442,22 @@ export class SettingsService {
       for (const rule of rules) {
         if (rule.enabled === false) continue;
 
-        const xrayRule: XrayRule = {
-          type: "field",
-          outboundTag: rule.outboundTag || "proxy",
-        };
+        // 负载均衡规则 (如应用分组) 使用 balancerTag，二者只能有一个
+        const xrayRule: XrayRule = rule.balancerTag
+          ? { type: "field", balancerTag: rule.balancerTag }
+          : { type: "field", outboundTag: rule.outboundTag || "proxy" };
 
         // 处理 domain
         if (rule.domain) {
-          xrayRule.domain = rule.domain.split(",").map((d) => {
-            d = d.trim();
+          xrayRule.domain = toList(rule.domain).map((d) => {
             if (
               d.startsWith("geosite:") ||
               d.startsWith("domain:") ||
               d.startsWith("full:") ||
-              d.startsWith("regexp:")
+              d.startsWith("regexp:") ||
+              d.startsWith("keyword:") ||
+              d.startsWith("ext:") ||
+              d.startsWith("dotless:")
             ) {
               return d;
             }
@@ -457,7 +467,7 @@ export class SettingsService {
 
         // 处理 ip
         if (rule.ip) {
-          xrayRule.ip = rule.ip.split(",").map((i) => i.trim());
+          xrayRule.ip = toList(rule.ip);
         }
 
         // 处理 port
@@ -467,7 +477,7 @@ export class SettingsService {
 
         // 处理 protocol
         if (rule.protocol) {
-          xrayRule.protocol = rule.protocol.split(",").map((p) => p.trim());
+          xrayRule.protocol = toList(rule.protocol);
         }
 
         // 处理 network
@@ -477,7 +487,7 @@ export class SettingsService {
 
         // 处理 inboundTag
         if (rule.inboundTag) {
-          xrayRule.inboundTag = rule.inboundTag.split(",").map((i) => i.trim());
+          xrayRule.inboundTag = toList(rule.inboundTag);
         }
 
         xrayRules.push(xrayRule);
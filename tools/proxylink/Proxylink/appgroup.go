@@ -0,0 +1,404 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"proxylink/pkg/appgroup"
+	"proxylink/pkg/packages"
+	"proxylink/pkg/routing"
+	"proxylink/pkg/util"
+	"proxylink/pkg/xrayconf"
+)
+
+// appGroupRulePrefix 应用分组规则在 routing_rules.json 中的名称前缀
+const appGroupRulePrefix = "应用分组"
+
+// appgroupCommands appgroup 子命令表
+var appgroupCommands = map[string]func(args []string) error{
+	"apply": runAppGroupApply,
+	"off":   runAppGroupOff,
+	"list":  runAppGroupList,
+}
+
+// runAppGroup 处理 appgroup 子命令
+func runAppGroup(args []string) error {
+	if len(args) > 0 {
+		if run, ok := appgroupCommands[args[0]]; ok {
+			return run(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, `用法:
+  proxylink appgroup apply [选项]   按 config/app_groups.json 生成分组入站、路由规则和 UID 映射
+  proxylink appgroup off [选项]     删除生成的分组入站、路由规则和 UID 映射
+  proxylink appgroup list [选项]    列出分组及其端口、去向和已解析的 UID`)
+	if len(args) == 0 {
+		return fmt.Errorf("缺少 appgroup 子命令")
+	}
+	return fmt.Errorf("未知 appgroup 子命令: %s", args[0])
+}
+
+// runAppGroupApply 生成应用分组
+func runAppGroupApply(args []string) error {
+	fs := flag.NewFlagSet("appgroup apply", flag.ExitOnError)
+	moduleDir := fs.String("module", defaultModuleDir, "模块目录")
+	packageList := fs.String("packages", packages.DefaultPath, "packages.list 路径")
+	printOnly := fs.Bool("print", false, "只输出入站片段和 UID 映射，不写入")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `用法:
+  proxylink appgroup apply [选项]
+
+读取 config/app_groups.json，为每个启用的分组生成:
+  confdir/03_inbounds_appgroups.json  以 tproxy-in 为模板、tag 为 app-<分组名> 的入站 (端口依次分配)
+  routing_rules.json                  "应用分组: <分组名>" 规则 (按 inboundTag 转到分组出站或负载均衡)，并重新编译 rule.json
+  config/tproxy/app_groups.conf       每行 "UID 端口 分组 应用"，tproxy.sh 据此把分组应用的流量转到分组端口
+应用 UID 在重装后会变化，服务启动时会自动重新执行。重启服务后生效。
+
+选项:`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	layout := newModuleLayout(*moduleDir)
+	config, err := appgroup.Load(layout.AppGroups)
+	if err != nil {
+		return err
+	}
+	groups := config.Enabled()
+	if len(groups) == 0 {
+		fmt.Fprintln(os.Stderr, "没有启用的分组，删除已生成的文件")
+		return removeAppGroups(layout)
+	}
+
+	fragment, err := buildAppGroupInbounds(layout, config)
+	if err != nil {
+		return err
+	}
+	list, err := packages.Read(*packageList)
+	if err != nil {
+		return err
+	}
+	mappings := resolveAppGroups(list, groups)
+	mapContent := appgroup.MarshalMap(mappings)
+
+	if *printOnly {
+		fmt.Printf("// %s\n%s// %s\n%s", appgroup.FragmentName, fragment, appgroup.MapName, mapContent)
+		return nil
+	}
+	if err := writeIfChanged(filepath.Join(layout.Confdir, appgroup.FragmentName), fragment); err != nil {
+		return err
+	}
+	if err := writeIfChanged(layout.AppGroupMap, mapContent); err != nil {
+		return err
+	}
+	if err := syncRoutingRules(layout, appGroupRulePrefix, appGroupRules(groups)); err != nil {
+		return err
+	}
+	checkAppGroupTargets(layout, groups)
+
+	for _, g := range groups {
+		uids := 0
+		for _, m := range mappings {
+			if m.Group == g.Name {
+				uids++
+			}
+		}
+		fmt.Fprintf(os.Stderr, "分组 %s: 端口 %d → %s，%d 个 UID\n", g.Name, g.Port, g.Target(), uids)
+	}
+	fmt.Fprintln(os.Stderr, "应用分组已更新，重启服务后生效")
+	return nil
+}
+
+// appGroupBase 合并 confdir 中除分组片段以外的文件，返回透明代理入站所在的配置
+func appGroupBase(layout moduleLayout) (*xrayconf.Config, error) {
+	paths, err := xrayconf.ConfdirFiles(layout.Confdir)
+	if err != nil {
+		return nil, err
+	}
+	var files []*xrayconf.File
+	for _, p := range paths {
+		if filepath.Base(p) == appgroup.FragmentName {
+			continue
+		}
+		file, err := xrayconf.LoadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	cfg, _ := xrayconf.Merge(files)
+	return cfg, nil
+}
+
+// buildAppGroupInbounds 以生效的 tproxy-in (启用 FakeDNS 时为其片段中的版本) 为模板生成分组入站
+// 自动分配的端口避开其他入站和 tproxy.conf 使用的端口
+func buildAppGroupInbounds(layout moduleLayout, config *appgroup.Config) ([]byte, error) {
+	cfg, err := appGroupBase(layout)
+	if err != nil {
+		return nil, err
+	}
+
+	reserved := map[int]bool{}
+	var base *xrayconf.Detour
+	for i, in := range cfg.Inbounds {
+		if port, err := xrayconf.InboundPort(in.Raw); err == nil {
+			reserved[port] = true
+		}
+		if in.Tag == appgroup.TemplateInbound {
+			base = &cfg.Inbounds[i]
+		}
+	}
+	if base == nil {
+		return nil, fmt.Errorf("confdir 中没有入站 %s", appgroup.TemplateInbound)
+	}
+	vars, err := util.ReadShellVars(layout.TproxyConf)
+	if err != nil {
+		vars = map[string]string{}
+	}
+	for _, key := range []string{"PROXY_TCP_PORT", "PROXY_UDP_PORT", "DNS_PORT"} {
+		if port, err := strconv.Atoi(vars[key]); err == nil {
+			reserved[port] = true
+		}
+	}
+	marks, err := tproxyMarks(vars)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.CheckMarks(marks, reserved); err != nil {
+		return nil, err
+	}
+
+	if err := config.AssignPorts(reserved); err != nil {
+		return nil, err
+	}
+	return appgroup.BuildInbounds(base.Raw, config.Enabled())
+}
+
+// tproxyMarkDefaults tproxy.sh 中 MARK_VALUE/MARK_VALUE6 的默认值
+var tproxyMarkDefaults = map[string]string{"MARK_VALUE": "20", "MARK_VALUE6": "25"}
+
+// tproxyMarks 读取 tproxy.conf 中的标记，未设置时按 tproxy.sh 的默认值，ROUTING_MARK 可为空
+func tproxyMarks(vars map[string]string) ([]appgroup.Mark, error) {
+	var marks []appgroup.Mark
+	for _, key := range []string{"MARK_VALUE", "MARK_VALUE6", "ROUTING_MARK"} {
+		value := vars[key]
+		if value == "" {
+			value = tproxyMarkDefaults[key]
+		}
+		if value == "" {
+			continue
+		}
+		m, err := appgroup.ParseMark(key, value)
+		if err != nil {
+			return nil, fmt.Errorf("tproxy.conf: %v", err)
+		}
+		marks = append(marks, m)
+	}
+	return marks, nil
+}
+
+// resolveAppGroups 将分组中的应用解析为 UID，同一 UID 只归属第一个分组
+// 共享 UID 的应用无法分开，给出提示
+func resolveAppGroups(list *packages.List, groups []*appgroup.Group) []appgroup.Mapping {
+	var mappings []appgroup.Mapping
+	owner := map[int]string{}
+	for _, g := range groups {
+		for _, app := range g.Apps {
+			r := list.ResolveToken(app)
+			if r.Error != "" {
+				fmt.Fprintf(os.Stderr, "警告: 分组 %s: %s\n", g.Name, r.Error)
+				continue
+			}
+			uid := r.UID
+			if len(r.SharedWith) > 0 {
+				fmt.Fprintf(os.Stderr, "分组 %s: %s 与 %s 共享 UID %d，将一同进入该分组\n", g.Name, app, strings.Join(r.SharedWith, ", "), uid)
+			}
+			if other, ok := owner[uid]; ok {
+				fmt.Fprintf(os.Stderr, "警告: 分组 %s: %s (UID %d) 已属于分组 %s，忽略\n", g.Name, app, uid, other)
+				continue
+			}
+			owner[uid] = g.Name
+			mappings = append(mappings, appgroup.Mapping{UID: uid, Port: g.Port, Group: g.Name, App: app})
+		}
+	}
+	return mappings
+}
+
+// appGroupRules 分组的路由规则: 分组入站的 DNS 请求同样交给 dns-out，其余流量转到分组去向
+// 规则由 appgroup apply 维护，在 WebUI 中隐藏 (规则编辑对话框不含 inboundTag/balancerTag，保存会丢失)
+func appGroupRules(groups []*appgroup.Group) []*routing.UIRule {
+	enabled, visible := true, false
+	var tags []string
+	for _, g := range groups {
+		tags = append(tags, g.InboundTag())
+	}
+	rules := []*routing.UIRule{{
+		Name:    appGroupRulePrefix + ": DNS",
+		Enabled: &enabled,
+		Visible: &visible,
+		Rule: routing.Rule{
+			Type:        "field",
+			InboundTag:  tags,
+			Port:        "53",
+			OutboundTag: "dns-out",
+		},
+	}}
+	for _, g := range groups {
+		rules = append(rules, &routing.UIRule{
+			Name:    appGroupRulePrefix + ": " + g.Name,
+			Enabled: &enabled,
+			Visible: &visible,
+			Rule: routing.Rule{
+				Type:        "field",
+				InboundTag:  routing.StringList{g.InboundTag()},
+				OutboundTag: g.OutboundTag,
+				BalancerTag: g.BalancerTag,
+			},
+		})
+	}
+	return rules
+}
+
+// checkAppGroupTargets 提示当前出站配置中不存在的分组出站
+func checkAppGroupTargets(layout moduleLayout, groups []*appgroup.Group) {
+	moduleVars, _ := util.ReadShellVars(layout.ModuleConf)
+	if moduleVars["CURRENT_CONFIG"] == "" {
+		return
+	}
+	file, err := xrayconf.LoadFile(layout.localPath(moduleVars["CURRENT_CONFIG"]))
+	if err != nil {
+		return
+	}
+	cfg, _ := xrayconf.Merge([]*xrayconf.File{file})
+	tags := cfg.OutboundTags()
+	for _, builtin := range []string{"direct", "block", "proxy"} {
+		tags[builtin] = true
+	}
+	for _, g := range groups {
+		if g.OutboundTag != "" && !tags[g.OutboundTag] {
+			fmt.Fprintf(os.Stderr, "警告: 分组 %s 的出站 %q 不在当前出站配置中\n", g.Name, g.OutboundTag)
+		}
+	}
+}
+
+// reapplyAppGroups 在 tproxy-in 变化后 (如 FakeDNS 开关) 重新生成分组入站 (未启用分组时跳过)
+func reapplyAppGroups(layout moduleLayout) error {
+	fragment := filepath.Join(layout.Confdir, appgroup.FragmentName)
+	if !fileExists(fragment) || !fileExists(layout.AppGroups) {
+		return nil
+	}
+	config, err := appgroup.Load(layout.AppGroups)
+	if err != nil {
+		return err
+	}
+	content, err := buildAppGroupInbounds(layout, config)
+	if err != nil {
+		return err
+	}
+	return writeIfChanged(fragment, content)
+}
+
+// removeAppGroups 删除分组入站、UID 映射和路由规则
+func removeAppGroups(layout moduleLayout) error {
+	for _, path := range []string{filepath.Join(layout.Confdir, appgroup.FragmentName), layout.AppGroupMap} {
+		if err := os.Remove(path); err == nil {
+			fmt.Fprintf(os.Stderr, "已删除 %s\n", path)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return syncRoutingRules(layout, appGroupRulePrefix, nil)
+}
+
+// runAppGroupOff 删除生成的应用分组 (保留 app_groups.json)
+func runAppGroupOff(args []string) error {
+	fs := flag.NewFlagSet("appgroup off", flag.ExitOnError)
+	moduleDir := fs.String("module", defaultModuleDir, "模块目录")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := removeAppGroups(newModuleLayout(*moduleDir)); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "应用分组已关闭，重启服务后生效 (删除或禁用 app_groups.json 中的分组可避免启动时重新生成)")
+	return nil
+}
+
+// runAppGroupList 列出分组
+func runAppGroupList(args []string) error {
+	fs := flag.NewFlagSet("appgroup list", flag.ExitOnError)
+	moduleDir := fs.String("module", defaultModuleDir, "模块目录")
+	asJSON := fs.Bool("json", false, "以 JSON 输出")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	layout := newModuleLayout(*moduleDir)
+	config, err := appgroup.Load(layout.AppGroups)
+	if err != nil {
+		return err
+	}
+	mappings, err := appgroup.ReadMap(layout.AppGroupMap)
+	if err != nil {
+		return err
+	}
+	ports := map[string]int{}
+	for _, m := range mappings {
+		ports[m.Group] = m.Port
+	}
+
+	type groupStatus struct {
+		*appgroup.Group
+		Inbound  string             `json:"inbound"`
+		Mappings []appgroup.Mapping `json:"mappings"`
+	}
+	var status []groupStatus
+	for _, g := range config.Groups {
+		s := groupStatus{Group: g, Inbound: g.InboundTag(), Mappings: []appgroup.Mapping{}}
+		if g.Port == 0 {
+			g.Port = ports[g.Name]
+		}
+		for _, m := range mappings {
+			if m.Group == g.Name {
+				s.Mappings = append(s.Mappings, m)
+			}
+		}
+		status = append(status, s)
+	}
+	if *asJSON {
+		return printJSON(status)
+	}
+
+	for _, s := range status {
+		state := ""
+		if !s.IsEnabled() {
+			state = " (已禁用)"
+		}
+		port := "-"
+		if s.Port != 0 {
+			port = strconv.Itoa(s.Port)
+		}
+		fmt.Printf("%s%s: %s → %s, 端口 %s\n", s.Name, state, s.Inbound, s.Target(), port)
+		var resolved []string
+		for _, m := range s.Mappings {
+			resolved = append(resolved, fmt.Sprintf("%s=%d", m.App, m.UID))
+		}
+		if len(resolved) > 0 {
+			fmt.Printf("  已解析: %s\n", strings.Join(resolved, " "))
+		}
+		if missing := len(s.Apps) - len(s.Mappings); missing > 0 && s.IsEnabled() {
+			fmt.Printf("  未解析: %d 个 (未安装或与其他分组重复)\n", missing)
+		}
+	}
+	if len(mappings) == 0 {
+		fmt.Fprintln(os.Stderr, "尚未生成 UID 映射，请执行 proxylink appgroup apply")
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"proxylink/pkg/packages"
+)
+
+// appsCommands apps 子命令表
+var appsCommands = map[string]func(args []string) error{
+	"resolve": runAppsResolve,
+	"list":    runAppsList,
+}
+
+// runApps 处理 apps 子命令
+func runApps(args []string) error {
+	if len(args) > 0 {
+		if run, ok := appsCommands[args[0]]; ok {
+			return run(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, `用法:
+  proxylink apps resolve [选项] <应用>...   将包名、"用户ID:包名" 或 UID 解析为 UID 及其应用
+  proxylink apps list [选项]               列出已安装应用及各用户下的 UID`)
+	if len(args) == 0 {
+		return fmt.Errorf("缺少 apps 子命令")
+	}
+	return fmt.Errorf("未知 apps 子命令: %s", args[0])
+}
+
+// runAppsResolve 解析应用写法
+func runAppsResolve(args []string) error {
+	fs := flag.NewFlagSet("apps resolve", flag.ExitOnError)
+	packageList := fs.String("packages", packages.DefaultPath, "packages.list 路径")
+	usersDir := fs.String("users-dir", packages.DefaultUsersDir, "Android 用户目录 (-all-users 使用)")
+	allUsers := fs.Bool("all-users", false, "包名解析为设备上所有用户的 UID (写了 用户ID: 的不受影响)")
+	asJSON := fs.Bool("json", false, "以 JSON 输出")
+	uidsOnly := fs.Bool("uids", false, "只输出 UID，以空格分隔 (供脚本使用)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `用法:
+  proxylink apps resolve [选项] <应用>...
+
+应用写法与 tproxy.conf 的应用列表一致: 包名 (用户 0)、用户ID:包名 (UID = 用户ID*100000 + appId)，
+或直接写 UID (反查使用该 UID 的应用)。共享 UID 的应用会一并列出。
+无法解析的应用输出警告，全部无法解析时返回错误。
+
+选项:`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("缺少应用")
+	}
+
+	list, err := packages.Read(*packageList)
+	if err != nil {
+		return err
+	}
+	users := []int{0}
+	if *allUsers {
+		if users, err = packages.ReadUsers(*usersDir); err != nil {
+			return err
+		}
+	}
+
+	var results []packages.Resolution
+	for _, token := range fs.Args() {
+		r := list.ResolveToken(token)
+		if !*allUsers || r.Error != "" || strings.Contains(token, ":") || isUID(token) {
+			results = append(results, r)
+			continue
+		}
+		for _, user := range users {
+			results = append(results, list.ResolveToken(strconv.Itoa(user)+":"+token))
+		}
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+			if !*asJSON {
+				fmt.Fprintf(os.Stderr, "警告: %s\n", r.Error)
+			}
+		}
+	}
+	switch {
+	case *asJSON:
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	case *uidsOnly:
+		var uids []string
+		for _, r := range results {
+			if r.Error == "" {
+				uids = append(uids, strconv.Itoa(r.UID))
+			}
+		}
+		fmt.Println(strings.Join(uids, " "))
+	default:
+		for _, r := range results {
+			if r.Error != "" {
+				continue
+			}
+			name := r.Package
+			if name == "" {
+				name = "-"
+			}
+			fmt.Printf("%-40s %-10d %s", r.Token, r.UID, name)
+			if len(r.SharedWith) > 0 {
+				fmt.Printf(" (共享: %s)", strings.Join(r.SharedWith, ", "))
+			}
+			fmt.Println()
+		}
+	}
+	if failed == len(results) {
+		return fmt.Errorf("没有可解析的应用")
+	}
+	return nil
+}
+
+func isUID(token string) bool {
+	_, err := strconv.Atoi(strings.TrimSpace(token))
+	return err == nil
+}
+
+// runAppsList 列出已安装应用
+func runAppsList(args []string) error {
+	fs := flag.NewFlagSet("apps list", flag.ExitOnError)
+	packageList := fs.String("packages", packages.DefaultPath, "packages.list 路径")
+	usersDir := fs.String("users-dir", packages.DefaultUsersDir, "Android 用户目录")
+	filter := fs.String("filter", "", "只列出包名包含该字符串的应用")
+	sharedOnly := fs.Bool("shared", false, "只列出共享 UID 的应用")
+	asJSON := fs.Bool("json", false, "以 JSON 输出")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	list, err := packages.Read(*packageList)
+	if err != nil {
+		return err
+	}
+	users, err := packages.ReadUsers(*usersDir)
+	if err != nil {
+		return err
+	}
+
+	type appEntry struct {
+		*packages.Package
+		UIDs       map[string]int `json:"uids"` // 用户 ID → UID
+		SharedWith []string       `json:"sharedWith,omitempty"`
+	}
+	entries := []appEntry{}
+	for _, p := range list.Packages {
+		if *filter != "" && !strings.Contains(p.Name, *filter) {
+			continue
+		}
+		shared := list.Shared(p)
+		if *sharedOnly && len(shared) == 0 {
+			continue
+		}
+		e := appEntry{Package: p, UIDs: map[string]int{}, SharedWith: shared}
+		for _, user := range users {
+			e.UIDs[strconv.Itoa(user)] = packages.UID(user, p.AppID)
+		}
+		entries = append(entries, e)
+	}
+	if *asJSON {
+		return printJSON(map[string]interface{}{"users": users, "packages": entries})
+	}
+
+	for _, e := range entries {
+		var uids []string
+		for _, user := range users {
+			uids = append(uids, strconv.Itoa(packages.UID(user, e.AppID)))
+		}
+		fmt.Printf("%-50s %s", e.Name, strings.Join(uids, ","))
+		if len(e.SharedWith) > 0 {
+			fmt.Printf(" (共享: %s)", strings.Join(e.SharedWith, ", "))
+		}
+		fmt.Println()
+	}
+	fmt.Fprintf(os.Stderr, "%d 个应用，用户 %s\n", len(entries), joinInts(users))
+	return nil
+}
+
+func joinInts(values []int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ",")
+}
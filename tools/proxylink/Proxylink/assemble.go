@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"proxylink/pkg/appgroup"
+	"proxylink/pkg/util"
+	"proxylink/pkg/xrayconf"
+)
+
+// defaultModuleDir 设备上的模块目录
+const defaultModuleDir = "/data/adb/modules/netproxy"
+
+// moduleLayout 模块内的配置路径
+type moduleLayout struct {
+	Dir         string
+	Confdir     string
+	ModuleConf  string
+	TproxyConf  string
+	Bootstrap   string // 节点域名预解析状态 (放在 config/xray 之外，避免被 Xray 或 lint 当作配置加载)
+	AppGroups   string // 应用分组定义
+	AppGroupMap string // 应用分组的 UID → 端口映射 (tproxy.sh 读取)
+}
+
+func newModuleLayout(dir string) moduleLayout {
+	return moduleLayout{
+		Dir:         dir,
+		Confdir:     filepath.Join(dir, "config", "xray", "confdir"),
+		ModuleConf:  filepath.Join(dir, "config", "module.conf"),
+		TproxyConf:  filepath.Join(dir, "config", "tproxy", "tproxy.conf"),
+		Bootstrap:   filepath.Join(dir, "config", "dns_bootstrap.json"),
+		AppGroups:   filepath.Join(dir, "config", "app_groups.json"),
+		AppGroupMap: filepath.Join(dir, "config", "tproxy", appgroup.MapName),
+	}
+}
+
+// routingFile 按出站模式返回路由文件 (与 service.sh 一致)
+func (l moduleLayout) routingFile(mode string) string {
+	name := "rule.json"
+	switch mode {
+	case "global":
+		name = "global.json"
+	case "direct":
+		name = "direct.json"
+	}
+	return filepath.Join(l.Confdir, "routing", name)
+}
+
+// localPath 将 module.conf 中的设备绝对路径映射到 -module 目录下
+func (l moduleLayout) localPath(path string) string {
+	if l.Dir != defaultModuleDir && strings.HasPrefix(path, defaultModuleDir+"/") {
+		return filepath.Join(l.Dir, strings.TrimPrefix(path, defaultModuleDir+"/"))
+	}
+	return path
+}
+
+// effectiveFlags 选择生效配置的公共参数 (assemble / route test)
+type effectiveFlags struct {
+	moduleDir *string
+	mode      *string
+	routing   *string
+	outbound  *string
+}
+
+func addEffectiveFlags(fs *flag.FlagSet) *effectiveFlags {
+	return &effectiveFlags{
+		moduleDir: fs.String("module", defaultModuleDir, "模块目录"),
+		mode:      fs.String("mode", "", "出站模式: rule, global, direct (默认读取 module.conf)"),
+		routing:   fs.String("routing", "", "路由文件 (覆盖 -mode)"),
+		outbound:  fs.String("outbound", "", "出站文件 (默认 module.conf 的 CURRENT_CONFIG)"),
+	}
+}
+
+func (f *effectiveFlags) layout() moduleLayout {
+	return newModuleLayout(*f.moduleDir)
+}
+
+// load 按 service.sh 的启动参数加载并合并配置，返回合并结果和加载顺序
+func (f *effectiveFlags) load() (*xrayconf.Config, []string, []xrayconf.Issue, error) {
+	layout := f.layout()
+	moduleVars, _ := util.ReadShellVars(layout.ModuleConf)
+
+	routing := *f.routing
+	if routing == "" {
+		m := *f.mode
+		if m == "" {
+			m = moduleVars["OUTBOUND_MODE"]
+		}
+		routing = layout.routingFile(m)
+	}
+
+	outbound := *f.outbound
+	if outbound == "" {
+		if moduleVars["CURRENT_CONFIG"] == "" {
+			return nil, nil, nil, fmt.Errorf("未指定 -outbound，且 %s 中没有 CURRENT_CONFIG", layout.ModuleConf)
+		}
+		outbound = layout.localPath(moduleVars["CURRENT_CONFIG"])
+	}
+
+	// Xray 先加载 -config 文件，再加载 -confdir 中的文件
+	paths := []string{routing, outbound}
+	confdirFiles, err := xrayconf.ConfdirFiles(layout.Confdir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	paths = append(paths, confdirFiles...)
+
+	var files []*xrayconf.File
+	for _, p := range paths {
+		file, err := xrayconf.LoadFile(p)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		files = append(files, file)
+	}
+
+	cfg, issues := xrayconf.Merge(files)
+	return cfg, paths, issues, nil
+}
+
+// runAssemble 处理 assemble 子命令
+func runAssemble(args []string) error {
+	fs := flag.NewFlagSet("assemble", flag.ExitOnError)
+	source := addEffectiveFlags(fs)
+	tproxyConf := fs.String("tproxy", "", "tproxy.conf 路径 (默认模块内)")
+	inboundTag := fs.String("inbound", "tproxy-in", "透明代理入站 tag")
+	output := fs.String("o", "", "输出到文件 (默认标准输出)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `用法:
+  proxylink assemble [选项]
+
+按 service.sh 的启动参数 (-confdir + 路由文件 + 出站文件) 和 Xray 的合并规则
+输出最终生效的配置，并报告重复 tag、指向不存在出站的规则、端口不一致等问题。
+
+选项:`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, paths, issues, err := source.load()
+	if err != nil {
+		return err
+	}
+	issues = append(issues, xrayconf.CheckRouting(cfg)...)
+
+	conf := *tproxyConf
+	if conf == "" {
+		conf = source.layout().TproxyConf
+	}
+	if vars, err := util.ReadShellVars(conf); err != nil {
+		issues = append(issues, xrayconf.Issue{Level: xrayconf.LevelWarn, Source: conf, Message: fmt.Sprintf("无法读取: %v", err)})
+	} else if port, err := strconv.Atoi(vars["PROXY_TCP_PORT"]); err != nil {
+		issues = append(issues, xrayconf.Issue{Level: xrayconf.LevelWarn, Source: conf, Message: "PROXY_TCP_PORT 未设置或不是数字"})
+	} else {
+		issues = append(issues, xrayconf.CheckInboundPort(cfg, *inboundTag, port)...)
+	}
+
+	if len(cfg.Outbounds) > 0 {
+		issues = append(issues, xrayconf.Issue{Level: xrayconf.LevelInfo, Message: fmt.Sprintf("默认出站 (未命中规则时使用): %q", cfg.Outbounds[0].Tag)})
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if *output != "" {
+		if err := os.WriteFile(*output, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "已写入: %s\n", *output)
+	} else {
+		fmt.Println(string(data))
+	}
+
+	errorCount := 0
+	fmt.Fprintf(os.Stderr, "加载顺序: %s\n", strings.Join(baseNames(paths), " → "))
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stderr, issue)
+		if issue.Level == xrayconf.LevelError {
+			errorCount++
+		}
+	}
+	if errorCount > 0 {
+		return fmt.Errorf("发现 %d 个错误", errorCount)
+	}
+	return nil
+}
+
+// baseNames 返回文件名列表
+func baseNames(paths []string) []string {
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = filepath.Base(p)
+	}
+	return names
+}
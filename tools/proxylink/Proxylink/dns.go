@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"proxylink/pkg/bootstrap"
+	"proxylink/pkg/fakedns"
+	"proxylink/pkg/routing"
+	"proxylink/pkg/tproxy"
+)
+
+// bootstrapRulePrefix 节点 IP 直连规则在 routing_rules.json 中的名称前缀
+const bootstrapRulePrefix = "节点服务器"
+
+// dnsCommands dns 子命令表
+var dnsCommands = map[string]func(args []string) error{
+	"refresh": runDNSRefresh,
+	"list":    runDNSList,
+}
+
+// runDNS 处理 dns 子命令
+func runDNS(args []string) error {
+	if len(args) > 0 {
+		if run, ok := dnsCommands[args[0]]; ok {
+			return run(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, `用法:
+  proxylink dns refresh [选项]   重新解析已过期 (TTL) 的节点域名并更新 hosts 片段或直连规则
+  proxylink dns list [选项]      列出预解析的节点域名
+
+预解析由生成节点时的 -dns 启用，状态保存在 <module>/config/dns_bootstrap.json`)
+	if len(args) == 0 {
+		return fmt.Errorf("缺少 dns 子命令")
+	}
+	return fmt.Errorf("未知 dns 子命令: %s", args[0])
+}
+
+// writeBootstrap 处理 -dns: 预解析本次输出节点的服务器域名并写入模块配置
+func writeBootstrap() error {
+	if !*dnsBootstrap {
+		return nil
+	}
+	if !isXrayFormat() {
+		return fmt.Errorf("-dns 仅支持 Xray 输出格式")
+	}
+
+	layout := newModuleLayout(*moduleDir)
+	state, err := bootstrap.LoadState(layout.Bootstrap)
+	if err != nil {
+		return err
+	}
+	// 只有显式指定时才替换记录中的设置，subscription.sh 更新订阅时沿用上次的引导 DNS 和写入方式
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "dns-server":
+			state.Server = *dnsServer
+		case "dns-mode":
+			state.Mode = *dnsMode
+		case "dns-ipv6":
+			state.IPv6 = *dnsIPv6
+		}
+	})
+	resolver, err := newBootstrapResolver(layout, state)
+	if err != nil {
+		return err
+	}
+
+	var domains []string
+	for _, n := range currentManifest.Nodes {
+		domains = append(domains, n.Server)
+	}
+	state.SetSource(bootstrapSource(), domains)
+
+	_, errs := bootstrap.Refresh(resolver, state.Due(time.Now(), false), time.Now())
+	for _, err := range errs {
+		warn("预解析: %v", err)
+	}
+	return saveBootstrap(layout, state)
+}
+
+// newBootstrapResolver 按状态创建引导解析器
+// 透明代理启用时直接向引导 DNS 发出的查询同样会被劫持，因此带上 tproxy.conf 中核心进程的
+// ROUTING_MARK 和用户组，命中 tproxy.sh 对核心流量的放行规则；仍落入 FakeDNS 地址池的结果视为失败
+func newBootstrapResolver(layout moduleLayout, state *bootstrap.State) (*bootstrap.Resolver, error) {
+	resolver, err := bootstrap.NewResolver(state.Server)
+	if err != nil {
+		return nil, err
+	}
+	resolver.IPv6 = state.IPv6
+
+	if config, err := tproxy.Load(layout.TproxyConf); config != nil {
+		resolver.Mark = config.RoutingMarkValue()
+		if gid, err := config.CoreGID(); err == nil {
+			resolver.GID = gid
+		} else {
+			fmt.Fprintf(os.Stderr, "预解析: %v，查询不切换用户组\n", err)
+		}
+	} else if !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "预解析: 读取 tproxy.conf 失败，查询可能被透明代理劫持: %v\n", err)
+	}
+
+	pools := []string{fakedns.DefaultPoolV4, fakedns.DefaultPoolV6}
+	if data, err := os.ReadFile(filepath.Join(layout.Confdir, fakedns.DNSFragment)); err == nil {
+		if opts, err := fakedns.ReadOptions(data, nil); err == nil {
+			for _, p := range opts.Pools {
+				pools = append(pools, p.IPPool)
+			}
+		}
+	}
+	for _, pool := range pools {
+		if _, ipnet, err := net.ParseCIDR(pool); err == nil {
+			resolver.Reject = append(resolver.Reject, ipnet)
+		}
+	}
+	return resolver, nil
+}
+
+// bootstrapSource 本次生成在状态文件中的来源名，同一来源再次生成时替换其域名
+func bootstrapSource() string {
+	switch {
+	case *outputDir != "":
+		return filepath.Base(filepath.Clean(*outputDir))
+	case *subName != "":
+		return *subName
+	case *outputFile != "":
+		return filepath.Base(*outputFile)
+	}
+	return "default"
+}
+
+// saveBootstrap 写入状态文件并按写入方式更新 hosts 片段或直连规则
+func saveBootstrap(layout moduleLayout, state *bootstrap.State) error {
+	if err := applyBootstrap(layout, state); err != nil {
+		return err
+	}
+	data, err := state.Marshal()
+	if err != nil {
+		return err
+	}
+	return routing.WriteFileAtomic(layout.Bootstrap, data)
+}
+
+// applyBootstrap 生成当前写入方式的输出，并清理另一种方式留下的输出
+func applyBootstrap(layout moduleLayout, state *bootstrap.State) error {
+	fragment := filepath.Join(layout.Confdir, bootstrap.FragmentName)
+	hosts := state.Mode == bootstrap.ModeHosts && len(state.IPs()) > 0
+
+	if hosts {
+		basePath := dnsBaseFile(layout)
+		base, err := os.ReadFile(basePath)
+		if err != nil {
+			return err
+		}
+		content, skipped, err := bootstrap.HostsFragment(base, state.Entries)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filepath.Base(basePath), err)
+		}
+		for _, d := range skipped {
+			fmt.Fprintf(os.Stderr, "预解析: %s 已在 %s 的 hosts 中，保留原有地址\n", d, filepath.Base(basePath))
+		}
+		if err := writeIfChanged(fragment, content); err != nil {
+			return err
+		}
+	} else if err := os.Remove(fragment); err == nil {
+		fmt.Fprintf(os.Stderr, "已删除 %s\n", fragment)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	var rules []*routing.UIRule
+	if state.Mode == bootstrap.ModeSockopt && len(state.IPs()) > 0 {
+		enabled, visible := true, true
+		rules = append(rules, &routing.UIRule{
+			Name:    bootstrapRulePrefix + ": 直连",
+			Enabled: &enabled,
+			Visible: &visible,
+			Rule: routing.Rule{
+				Type:        "field",
+				IP:          state.IPs(),
+				OutboundTag: "direct",
+			},
+		})
+	}
+	return syncRoutingRules(layout, bootstrapRulePrefix, rules)
+}
+
+// dnsBaseFile 返回 hosts 片段之前生效的 dns 配置: 启用 FakeDNS 时为其片段，否则为 02_dns.json
+func dnsBaseFile(layout moduleLayout) string {
+	if fragment := filepath.Join(layout.Confdir, fakedns.DNSFragment); fileExists(fragment) {
+		return fragment
+	}
+	return filepath.Join(layout.Confdir, "02_dns.json")
+}
+
+// reapplyBootstrap 在 dns 基础配置变化后重新生成 hosts 片段 (没有预解析记录时跳过)
+func reapplyBootstrap(layout moduleLayout) error {
+	if !fileExists(layout.Bootstrap) {
+		return nil
+	}
+	state, err := bootstrap.LoadState(layout.Bootstrap)
+	if err != nil {
+		return err
+	}
+	return applyBootstrap(layout, state)
+}
+
+// writeIfChanged 内容变化时原子写入
+func writeIfChanged(path string, content []byte) error {
+	if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, content) {
+		return nil
+	}
+	if err := routing.WriteFileAtomic(path, content); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "已写入 %s\n", path)
+	return nil
+}
+
+// runDNSRefresh 重新解析过期的节点域名
+func runDNSRefresh(args []string) error {
+	fs := flag.NewFlagSet("dns refresh", flag.ExitOnError)
+	moduleDir := fs.String("module", defaultModuleDir, "模块目录")
+	server := fs.String("server", "", "更换引导 DNS (IP[:端口])")
+	mode := fs.String("mode", "", "更换写入方式: hosts, sockopt")
+	force := fs.Bool("force", false, "忽略 TTL，重新解析全部域名")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `用法:
+  proxylink dns refresh [选项]
+
+重新解析 TTL 已过期的节点域名 (TTL 限制在 5 分钟到 24 小时之间)，解析失败时保留旧地址。
+同时按 02_dns.json (启用 FakeDNS 时为其片段) 的当前内容重新生成 hosts 片段，IP 有变化时需要重启 Xray 生效。
+
+选项:`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	layout := newModuleLayout(*moduleDir)
+	if _, err := os.Stat(layout.Bootstrap); err != nil {
+		return fmt.Errorf("没有预解析记录 (%s)，请先以 -dns 生成节点", layout.Bootstrap)
+	}
+	state, err := bootstrap.LoadState(layout.Bootstrap)
+	if err != nil {
+		return err
+	}
+	if *server != "" {
+		state.Server = *server
+	}
+	if *mode != "" {
+		if err := bootstrap.ValidateMode(*mode); err != nil {
+			return err
+		}
+		state.Mode = *mode
+	}
+	resolver, err := newBootstrapResolver(layout, state)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	due := state.Due(now, *force)
+	changed, errs := bootstrap.Refresh(resolver, due, now)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+	}
+	if err := saveBootstrap(layout, state); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "已解析 %d 个到期域名 (共 %d 个)，%d 个地址有变化", len(due)-len(errs), len(state.Entries), changed)
+	if next := state.NextExpiry(); !next.IsZero() {
+		fmt.Fprintf(os.Stderr, "，下次过期 %s", next.Local().Format("2006-01-02 15:04:05"))
+	}
+	fmt.Fprintln(os.Stderr)
+	if changed > 0 {
+		fmt.Fprintln(os.Stderr, "节点地址有变化，重启 Xray 后生效")
+	}
+	return nil
+}
+
+// runDNSList 列出预解析的节点域名
+func runDNSList(args []string) error {
+	fs := flag.NewFlagSet("dns list", flag.ExitOnError)
+	moduleDir := fs.String("module", defaultModuleDir, "模块目录")
+	asJSON := fs.Bool("json", false, "以 JSON 输出")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	state, err := bootstrap.LoadState(newModuleLayout(*moduleDir).Bootstrap)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(state)
+	}
+
+	now := time.Now()
+	for _, e := range state.Entries {
+		expires := "已过期"
+		if left := e.Expires.Sub(now); left > 0 {
+			expires = left.Round(time.Second).String()
+		}
+		ips := strings.Join(e.IPs, ",")
+		if ips == "" {
+			ips = "-"
+		}
+		fmt.Printf("%-40s %-40s %-10s %s\n", e.Domain, ips, expires, strings.Join(e.Sources, ","))
+		if e.Error != "" {
+			fmt.Printf("  错误: %s\n", e.Error)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "引导 DNS %s，写入方式 %s，%d 个域名\n", state.Server, state.Mode, len(state.Entries))
+	return nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"proxylink/pkg/fakedns"
+	"proxylink/pkg/routing"
+)
+
+// fakednsCommands fakedns 子命令表
+var fakednsCommands = map[string]func(args []string) error{
+	"on":     runFakeDNSOn,
+	"off":    runFakeDNSOff,
+	"status": runFakeDNSStatus,
+}
+
+// runFakeDNS 处理 fakedns 子命令
+func runFakeDNS(args []string) error {
+	if len(args) > 0 {
+		if run, ok := fakednsCommands[args[0]]; ok {
+			return run(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, `用法:
+  proxylink fakedns on [选项]    生成并启用 FakeDNS 片段 (再次执行按 02_dns.json 重新生成)
+  proxylink fakedns off [选项]   删除 FakeDNS 片段，恢复真实 IP 模式
+  proxylink fakedns status       查看是否启用及地址池、例外域名`)
+	if len(args) == 0 {
+		return fmt.Errorf("缺少 fakedns 子命令")
+	}
+	return fmt.Errorf("未知 fakedns 子命令: %s", args[0])
+}
+
+// fakednsFragments 返回 confdir 中的 FakeDNS 片段路径
+func fakednsFragments(layout moduleLayout) (dnsPath, inboundPath string) {
+	return filepath.Join(layout.Confdir, fakedns.DNSFragment), filepath.Join(layout.Confdir, fakedns.InboundFragment)
+}
+
+// runFakeDNSOn 生成 FakeDNS 片段
+func runFakeDNSOn(args []string) error {
+	fs := flag.NewFlagSet("fakedns on", flag.ExitOnError)
+	moduleDir := fs.String("module", defaultModuleDir, "模块目录")
+	pools := fs.String("pool", "", "地址池，逗号分隔，可带 :poolSize (默认 "+fakedns.DefaultPoolV4+")")
+	poolSize := fs.Int("pool-size", fakedns.DefaultPoolSize, "未指定 :poolSize 的地址池大小")
+	ipv6 := fs.Bool("ipv6", false, "增加 IPv6 地址池 "+fakedns.DefaultPoolV6)
+	exceptions := fs.String("exceptions", strings.Join(fakedns.DefaultExceptions, ","), "保留真实 IP 的域名，逗号分隔 (Xray 域名写法)")
+	server := fs.String("exception-server", "", "解析例外域名的 DNS (默认沿用 02_dns.json 中 geosite:cn 的服务器)")
+	inbound := fs.String("inbound", fakedns.DefaultInbound, "识别 FakeDNS 地址的入站")
+	printOnly := fs.Bool("print", false, "只输出片段，不写入")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `用法:
+  proxylink fakedns on [选项]
+
+在 confdir 中生成两个片段 (删除即恢复):
+  02_dns_fakedns.json      fakedns 地址池 + 02_dns.json 的 dns 配置，例外域名由真实 DNS 解析，
+                           其余未被域名规则命中的查询返回 FakeDNS 地址
+  03_inbounds_fakedns.json tproxy-in 的 sniffing.destOverride 加入 fakedns
+已启用时再次执行且未指定选项，沿用上次的地址池和例外域名。重启服务后生效。
+
+选项:`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	layout := newModuleLayout(*moduleDir)
+	dnsPath, inboundPath := fakednsFragments(layout)
+
+	opts := fakedns.Options{Exceptions: splitComma(*exceptions), ExceptionServer: *server, Inbound: *inbound}
+	if *pools == "" {
+		opts.Pools = append(opts.Pools, fakedns.Pool{IPPool: fakedns.DefaultPoolV4, PoolSize: *poolSize})
+	} else {
+		for _, spec := range splitComma(*pools) {
+			pool, err := parsePool(spec, *poolSize)
+			if err != nil {
+				return err
+			}
+			opts.Pools = append(opts.Pools, pool)
+		}
+	}
+	if *ipv6 {
+		opts.Pools = append(opts.Pools, fakedns.Pool{IPPool: fakedns.DefaultPoolV6, PoolSize: *poolSize})
+	}
+	explicit := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "module" && f.Name != "print" {
+			explicit = true
+		}
+	})
+	if !explicit {
+		if previous, err := previousFakeDNSOptions(dnsPath, inboundPath); err == nil {
+			opts = *previous
+		}
+	}
+	for _, p := range opts.Pools {
+		if err := fakedns.ValidatePool(p); err != nil {
+			return err
+		}
+	}
+
+	base, err := os.ReadFile(filepath.Join(layout.Confdir, "02_dns.json"))
+	if err != nil {
+		return err
+	}
+	dnsContent, err := fakedns.BuildDNS(base, opts)
+	if err != nil {
+		return fmt.Errorf("02_dns.json: %v", err)
+	}
+	base, err = os.ReadFile(filepath.Join(layout.Confdir, "03_inbounds.json"))
+	if err != nil {
+		return err
+	}
+	inboundContent, err := fakedns.BuildInbound(base, opts.Inbound)
+	if err != nil {
+		return fmt.Errorf("03_inbounds.json: %v", err)
+	}
+
+	if *printOnly {
+		fmt.Printf("// %s\n%s// %s\n%s", fakedns.DNSFragment, dnsContent, fakedns.InboundFragment, inboundContent)
+		return nil
+	}
+	if err := writeIfChanged(dnsPath, dnsContent); err != nil {
+		return err
+	}
+	if err := writeIfChanged(inboundPath, inboundContent); err != nil {
+		return err
+	}
+	if err := reapplyBootstrap(layout); err != nil {
+		return err
+	}
+	if err := reapplyAppGroups(layout); err != nil {
+		return err
+	}
+	checkFakeDNSRouting(layout)
+	fmt.Fprintln(os.Stderr, "FakeDNS 已启用，重启服务后生效")
+	return nil
+}
+
+// previousFakeDNSOptions 读取已启用的片段中的选项
+func previousFakeDNSOptions(dnsPath, inboundPath string) (*fakedns.Options, error) {
+	dnsData, err := os.ReadFile(dnsPath)
+	if err != nil {
+		return nil, err
+	}
+	inboundData, _ := os.ReadFile(inboundPath)
+	return fakedns.ReadOptions(dnsData, inboundData)
+}
+
+// checkFakeDNSRouting 提示会让 FakeDNS 地址失效的路由设置
+// FakeDNS 地址只在嗅探还原域名后才有意义，路由按 IP 解析域名时会再次拿到 FakeDNS 地址
+func checkFakeDNSRouting(layout moduleLayout) {
+	data, err := os.ReadFile(filepath.Join(layout.Confdir, "routing", "rule.json"))
+	if err != nil {
+		return
+	}
+	var config routing.Config
+	if json.Unmarshal(data, &config) != nil {
+		return
+	}
+	if ds := config.Routing.DomainStrategy; ds != "" && ds != "AsIs" {
+		warn("rule.json 的 domainStrategy 为 %s，按 IP 匹配的规则会用到 FakeDNS 地址，建议使用 AsIs", ds)
+	}
+}
+
+// parsePool 解析 "198.18.0.0/15:65535" 形式的地址池
+func parsePool(spec string, defaultSize int) (fakedns.Pool, error) {
+	pool := fakedns.Pool{IPPool: spec, PoolSize: defaultSize}
+	if i := strings.LastIndex(spec, ":"); i > strings.LastIndex(spec, "/") {
+		size, err := strconv.Atoi(spec[i+1:])
+		if err != nil {
+			return pool, fmt.Errorf("无效的地址池 %q", spec)
+		}
+		pool.IPPool, pool.PoolSize = spec[:i], size
+	}
+	return pool, nil
+}
+
+func splitComma(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// runFakeDNSOff 删除 FakeDNS 片段
+func runFakeDNSOff(args []string) error {
+	fs := flag.NewFlagSet("fakedns off", flag.ExitOnError)
+	moduleDir := fs.String("module", defaultModuleDir, "模块目录")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	layout := newModuleLayout(*moduleDir)
+	dnsPath, inboundPath := fakednsFragments(layout)
+	removed := 0
+	for _, path := range []string{dnsPath, inboundPath} {
+		if err := os.Remove(path); err == nil {
+			fmt.Fprintf(os.Stderr, "已删除 %s\n", path)
+			removed++
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if removed == 0 {
+		fmt.Fprintln(os.Stderr, "FakeDNS 未启用")
+		return nil
+	}
+	if err := reapplyBootstrap(layout); err != nil {
+		return err
+	}
+	if err := reapplyAppGroups(layout); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "FakeDNS 已关闭，重启服务后生效")
+	return nil
+}
+
+// runFakeDNSStatus 查看 FakeDNS 状态
+func runFakeDNSStatus(args []string) error {
+	fs := flag.NewFlagSet("fakedns status", flag.ExitOnError)
+	moduleDir := fs.String("module", defaultModuleDir, "模块目录")
+	asJSON := fs.Bool("json", false, "以 JSON 输出")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dnsPath, inboundPath := fakednsFragments(newModuleLayout(*moduleDir))
+	opts, err := previousFakeDNSOptions(dnsPath, inboundPath)
+	enabled := err == nil && fileExists(inboundPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if *asJSON {
+		status := map[string]interface{}{"enabled": enabled}
+		if opts != nil {
+			status["pools"] = opts.Pools
+			status["exceptions"] = opts.Exceptions
+			status["exceptionServer"] = opts.ExceptionServer
+			status["inbound"] = opts.Inbound
+		}
+		return printJSON(status)
+	}
+
+	if opts == nil {
+		fmt.Println("FakeDNS: 未启用")
+		return nil
+	}
+	if !enabled {
+		fmt.Printf("FakeDNS: 不完整 (缺少 %s)，请重新执行 proxylink fakedns on\n", fakedns.InboundFragment)
+	} else {
+		fmt.Println("FakeDNS: 已启用")
+	}
+	for _, p := range opts.Pools {
+		fmt.Printf("  地址池: %s (%d)\n", p.IPPool, p.PoolSize)
+	}
+	fmt.Printf("  例外:   %s → %s\n", strings.Join(opts.Exceptions, ", "), opts.ExceptionServer)
+	fmt.Printf("  入站:   %s\n", opts.Inbound)
+	return nil
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"proxylink/pkg/geo"
+	"proxylink/pkg/routing"
+)
+
+// geoCommands geo 子命令表
+var geoCommands = map[string]func(args []string) error{
+	"list":   runGeoList,
+	"show":   runGeoShow,
+	"lookup": runGeoLookup,
+	"build":  runGeoBuild,
+}
+
+// runGeo 处理 geo 子命令
+func runGeo(args []string) error {
+	if len(args) > 0 {
+		if run, ok := geoCommands[args[0]]; ok {
+			return run(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, `用法:
+  proxylink geo list <file>                  列出分类及条目数
+  proxylink geo show <file> <code[@attr]>    输出分类内容
+  proxylink geo lookup <file> <domain|ip>    查找包含目标的分类
+  proxylink geo build -o <file> <code=list>... 从文本列表构建 dat 文件
+
+<file> 为路径或资源目录 (默认 <module>/bin) 中的文件名，如 geosite.dat、geosite-ads.dat`)
+	if len(args) == 0 {
+		return fmt.Errorf("缺少 geo 子命令")
+	}
+	return fmt.Errorf("未知 geo 子命令: %s", args[0])
+}
+
+// geoFlags geo 子命令的公共参数
+type geoFlags struct {
+	moduleDir *string
+	assets    *string
+	json      *bool
+}
+
+func addGeoFlags(fs *flag.FlagSet) *geoFlags {
+	return &geoFlags{
+		moduleDir: fs.String("module", defaultModuleDir, "模块目录"),
+		assets:    fs.String("assets", "", "dat 文件目录 (默认 <module>/bin)"),
+		json:      fs.Bool("json", false, "以 JSON 输出"),
+	}
+}
+
+// assetDir 返回 Xray 查找 dat 文件的目录 (与 xray 可执行文件同目录)
+func (f *geoFlags) assetDir() string {
+	if *f.assets != "" {
+		return *f.assets
+	}
+	return filepath.Join(*f.moduleDir, "bin")
+}
+
+// open 加载 dat 文件，省略扩展名时补全 .dat
+func (f *geoFlags) open(name string) (*geo.File, error) {
+	if filepath.Ext(name) == "" {
+		name += ".dat"
+	}
+	return geo.NewStore(f.assetDir()).Open(name)
+}
+
+// parseGeoArgs 解析参数并校验位置参数个数
+func parseGeoArgs(fs *flag.FlagSet, args []string, want int) ([]string, error) {
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(positional) != want {
+		fs.Usage()
+		return nil, fmt.Errorf("需要 %d 个参数，实际为 %d 个", want, len(positional))
+	}
+	return positional, nil
+}
+
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// runGeoList 列出分类
+func runGeoList(args []string) error {
+	fs := flag.NewFlagSet("geo list", flag.ExitOnError)
+	opts := addGeoFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "用法:\n  proxylink geo list <file> [选项]\n\n选项:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseGeoArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	file, err := opts.open(positional[0])
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		Code  string `json:"code"`
+		Count int    `json:"count"`
+	}
+	var entries []entry
+	for _, s := range file.Sites {
+		entries = append(entries, entry{s.Code, len(s.Domains)})
+	}
+	for _, s := range file.IPs {
+		entries = append(entries, entry{s.Code, len(s.CIDRs)})
+	}
+
+	if *opts.json {
+		return printJSON(map[string]interface{}{"file": file.Path, "kind": file.Kind, "entries": entries})
+	}
+	for _, e := range entries {
+		fmt.Printf("%-32s %d\n", e.Code, e.Count)
+	}
+	fmt.Fprintf(os.Stderr, "%s (%s): %d 个分类\n", filepath.Base(file.Path), file.Kind, len(entries))
+	return nil
+}
+
+// runGeoShow 输出分类内容
+func runGeoShow(args []string) error {
+	fs := flag.NewFlagSet("geo show", flag.ExitOnError)
+	opts := addGeoFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `用法:
+  proxylink geo show <file> <code[@attr]> [选项]
+
+域名按路由规则的写法输出 (domain:/full:/keyword:/regexp:，属性以 @ 附加)，
+geoip 分类输出 CIDR。code 可带 @attr 或 @!attr 过滤属性。
+
+选项:`)
+		fs.PrintDefaults()
+	}
+	positional, err := parseGeoArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	file, err := opts.open(positional[0])
+	if err != nil {
+		return err
+	}
+	code := positional[1]
+
+	var lines []string
+	if file.Kind == geo.KindIP {
+		set, err := file.IPSet(code)
+		if err != nil {
+			return err
+		}
+		if *opts.json {
+			return printJSON(set)
+		}
+		for _, c := range set.CIDRs {
+			lines = append(lines, c.String())
+		}
+		if set.ReverseMatch {
+			fmt.Fprintln(os.Stderr, "注意: 该分类为 reverseMatch，匹配不在以下网段内的 IP")
+		}
+	} else {
+		site, err := file.Site(code)
+		if err != nil {
+			return err
+		}
+		if *opts.json {
+			return printJSON(site)
+		}
+		for _, d := range site.Domains {
+			lines = append(lines, d.String())
+		}
+	}
+
+	fmt.Println(strings.Join(lines, "\n"))
+	fmt.Fprintf(os.Stderr, "%s: %d 条\n", code, len(lines))
+	return nil
+}
+
+// runGeoLookup 反查目标所属的分类
+func runGeoLookup(args []string) error {
+	fs := flag.NewFlagSet("geo lookup", flag.ExitOnError)
+	opts := addGeoFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `用法:
+  proxylink geo lookup <file> <domain|ip> [选项]
+
+按 Xray 的匹配方式查找包含目标的全部分类，并给出命中的条目。
+
+选项:`)
+		fs.PrintDefaults()
+	}
+	positional, err := parseGeoArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	file, err := opts.open(positional[0])
+	if err != nil {
+		return err
+	}
+	target := positional[1]
+
+	var hits []geo.Hit
+	if file.Kind == geo.KindIP {
+		ip := net.ParseIP(strings.Trim(target, "[]"))
+		if ip == nil {
+			return fmt.Errorf("%s 是 geoip 文件，目标必须是 IP: %s", filepath.Base(file.Path), target)
+		}
+		hits = file.LookupIP(ip)
+	} else {
+		if hits, err = file.LookupDomain(target); err != nil {
+			return err
+		}
+	}
+
+	if *opts.json {
+		return printJSON(hits)
+	}
+	for _, h := range hits {
+		fmt.Printf("%-32s %s\n", h.Code, h.Entry)
+	}
+	if len(hits) == 0 {
+		fmt.Fprintf(os.Stderr, "%s 不在 %s 的任何分类中\n", target, filepath.Base(file.Path))
+	}
+	return nil
+}
+
+// runGeoBuild 从文本列表构建 dat 文件
+func runGeoBuild(args []string) error {
+	fs := flag.NewFlagSet("geo build", flag.ExitOnError)
+	output := fs.String("o", "", "输出文件 (必填)")
+	kind := fs.String("type", "auto", "输出类型: auto, geosite, geoip")
+	strict := fs.Bool("strict", false, "存在无法识别的行时失败")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `用法:
+  proxylink geo build -o <file> [选项] <code[@attr...]=list[,list...]>...
+
+每个参数为一个分类，省略 code= 时以文件名 (不含扩展名) 作为分类名，- 为标准输入。
+code 后的 @attr 附加到该分类的每个域名，路由中可用 ext:<file>:<code>@<attr> 过滤。
+
+列表每行一条，支持:
+  example.com / full:、domain:、keyword:、regexp: 前缀，行尾可附带 @attr
+  hosts 格式:   0.0.0.0 ads.example.com
+  AdGuard 格式: ||ads.example.com^
+  IP 与 CIDR:   10.0.0.0/8、2001:db8::/32
+# 或 ! 开头为注释。同一分类的条目去重，被 domain: 覆盖的子域名和被包含的网段会被合并。
+
+选项:`)
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if *output == "" || len(positional) == 0 {
+		fs.Usage()
+		return fmt.Errorf("缺少 -o 或输入列表")
+	}
+	switch *kind {
+	case "auto", geo.KindSite, geo.KindIP:
+	default:
+		return fmt.Errorf("未知类型: %s", *kind)
+	}
+
+	builder := geo.NewBuilder()
+	for _, arg := range positional {
+		code, lists := "", arg
+		if i := strings.IndexByte(arg, '='); i >= 0 {
+			code, lists = arg[:i], arg[i+1:]
+		}
+		for _, list := range strings.Split(lists, ",") {
+			listCode := code
+			if listCode == "" {
+				if list == "-" {
+					return fmt.Errorf("标准输入需要指定分类名 (code=-)")
+				}
+				listCode = strings.TrimSuffix(filepath.Base(list), filepath.Ext(list))
+			}
+			name, attrs := splitAttrs(listCode)
+			if err := addGeoList(builder, name, attrs, list); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, w := range builder.Warnings {
+		fmt.Fprintf(os.Stderr, "跳过 %s\n", w)
+	}
+	if *strict && len(builder.Warnings) > 0 {
+		return fmt.Errorf("%d 行无法识别", len(builder.Warnings))
+	}
+
+	outKind := *kind
+	if outKind == "auto" {
+		if outKind, err = builder.Kind(); err != nil {
+			return err
+		}
+	} else if detected, err := builder.Kind(); err != nil || detected != outKind {
+		fmt.Fprintf(os.Stderr, "注意: 输出类型为 %s，其他类型的条目被忽略\n", outKind)
+	}
+
+	var data []byte
+	var summary []string
+	if outKind == geo.KindIP {
+		sets := builder.IPSets()
+		data = geo.EncodeIPList(sets)
+		for _, s := range sets {
+			summary = append(summary, fmt.Sprintf("%s: %d", s.Code, len(s.CIDRs)))
+		}
+	} else {
+		sites := builder.Sites()
+		data = geo.EncodeSiteList(sites)
+		for _, s := range sites {
+			summary = append(summary, fmt.Sprintf("%s: %d", s.Code, len(s.Domains)))
+		}
+	}
+	if len(summary) == 0 {
+		return fmt.Errorf("没有可写入的 %s 条目", outKind)
+	}
+
+	if err := routing.WriteFileAtomic(*output, data); err != nil {
+		return fmt.Errorf("写入 %s 失败: %v", *output, err)
+	}
+	fmt.Fprintf(os.Stderr, "已写入 %s (%s): %s\n", *output, outKind, strings.Join(summary, ", "))
+	return nil
+}
+
+// splitAttrs 拆分 "ads@ads@cn" 为分类名和属性
+func splitAttrs(code string) (string, []string) {
+	parts := strings.Split(code, "@")
+	return parts[0], parts[1:]
+}
+
+// addGeoList 读取一个列表文件
+func addGeoList(builder *geo.Builder, code string, attrs []string, path string) error {
+	if path == "-" {
+		return builder.Add(code, attrs, "stdin", os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return builder.Add(code, attrs, path, f)
+}
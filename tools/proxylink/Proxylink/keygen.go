@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"proxylink/pkg/keys"
+)
+
+// keyPairOutput 密钥对输出结构
+type keyPairOutput struct {
+	Type       string `json:"type"`
+	PrivateKey string `json:"privateKey"`
+	PublicKey  string `json:"publicKey"`
+}
+
+// runKeygen 处理 keygen 子命令
+func runKeygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "以 JSON 输出")
+	keyType := fs.String("type", "", "derive 输出编码: wireguard, reality (默认与输入一致)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `用法:
+  proxylink keygen wireguard [-json]          生成 WireGuard 密钥对
+  proxylink keygen reality [-json]            生成 Reality 密钥对
+  proxylink keygen derive [-type t] <私钥>     由私钥计算公钥
+
+选项:`)
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		fs.Usage()
+		return fmt.Errorf("缺少 keygen 模式")
+	}
+	mode := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var out *keyPairOutput
+
+	switch mode {
+	case "wireguard", "wg", "reality":
+		pair, err := keys.Generate()
+		if err != nil {
+			return err
+		}
+		out = encodeKeyPair(mode, pair.PrivateKey, pair.PublicKey)
+
+	case "derive":
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return fmt.Errorf("derive 需要一个私钥参数")
+		}
+		input := fs.Arg(0)
+		priv, err := keys.Decode(input)
+		if err != nil {
+			return err
+		}
+		pub, err := keys.DerivePublicKey(priv)
+		if err != nil {
+			return err
+		}
+
+		t := *keyType
+		if t == "" {
+			t = "wireguard"
+			if keys.IsRealityEncoding(input) {
+				t = "reality"
+			}
+		}
+		out = encodeKeyPair(t, priv, pub)
+
+	default:
+		fs.Usage()
+		return fmt.Errorf("未知 keygen 模式: %s", mode)
+	}
+
+	if out == nil {
+		return fmt.Errorf("未知密钥类型: %s", *keyType)
+	}
+
+	if *jsonOutput {
+		output, err := toJSON(out)
+		if err != nil {
+			return err
+		}
+		fmt.Println(output)
+		return nil
+	}
+
+	fmt.Printf("PrivateKey: %s\nPublicKey: %s\n", out.PrivateKey, out.PublicKey)
+	return nil
+}
+
+// encodeKeyPair 按密钥类型编码
+func encodeKeyPair(keyType string, priv, pub []byte) *keyPairOutput {
+	switch keyType {
+	case "wireguard", "wg":
+		return &keyPairOutput{
+			Type:       "wireguard",
+			PrivateKey: keys.EncodeWireGuard(priv),
+			PublicKey:  keys.EncodeWireGuard(pub),
+		}
+	case "reality":
+		return &keyPairOutput{
+			Type:       "reality",
+			PrivateKey: keys.EncodeReality(priv),
+			PublicKey:  keys.EncodeReality(pub),
+		}
+	default:
+		return nil
+	}
+}
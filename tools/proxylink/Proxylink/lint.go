@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"proxylink/pkg/util"
+	"proxylink/pkg/xrayconf"
+)
+
+// runLint 处理 lint 子命令
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	moduleDir := fs.String("module", defaultModuleDir, "模块目录")
+	root := fs.String("root", "", "config/xray 目录 (默认 <module>/config/xray)")
+	tproxyConf := fs.String("tproxy", "", "tproxy.conf 路径 (默认模块内)")
+	format := fs.String("format", "text", "输出格式: text, json")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `用法:
+  proxylink lint [选项]
+
+检查 config/xray 下全部 JSON: 语法 (行列号)、未知字段、指向不存在出站的规则、
+没有成员的负载均衡、不在 hosts 中的 DNS 服务器域名、api 端口冲突。
+存在 error 时退出码为 1。
+
+选项:`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("未知输出格式: %s", *format)
+	}
+
+	layout := newModuleLayout(*moduleDir)
+	dir := *root
+	if dir == "" {
+		dir = filepath.Join(layout.Dir, "config", "xray")
+	}
+	conf := *tproxyConf
+	if conf == "" {
+		conf = layout.TproxyConf
+	}
+	vars, _ := util.ReadShellVars(conf)
+
+	result, err := xrayconf.Lint(xrayconf.LintOptions{Root: dir, TproxyVars: vars})
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		output, err := toJSON(result)
+		if err != nil {
+			return err
+		}
+		fmt.Println(output)
+	} else {
+		for _, issue := range result.Issues {
+			fmt.Println(issue)
+		}
+		fmt.Printf("检查 %d 个文件: %d 个错误, %d 个警告\n", result.Files, result.Errors, result.Warnings)
+	}
+
+	if result.Errors > 0 {
+		// 结果已输出，仅通过退出码表示失败
+		os.Exit(1)
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"proxylink/pkg/bootstrap"
 	"proxylink/pkg/encoder"
 	"proxylink/pkg/generator"
 	"proxylink/pkg/model"
@@ -20,17 +21,79 @@ var (
 	parseURI     = flag.String("parse", "", "解析单条链接")
 	parseFile    = flag.String("file", "", "从文件批量解析")
 	subURL       = flag.String("sub", "", "订阅 URL")
-	outputFormat = flag.String("format", "json", "输出格式: json, xray, hy2, uri")
+	wgConfFile   = flag.String("wgconf", "", "解析 WireGuard 配置文件 (.conf)")
+	outputFormat = flag.String("format", "json", "输出格式: json, xray, xray-balancer, hy2, uri, wgconf")
 	outputFile   = flag.String("o", "", "输出到文件 (单文件模式)")
 	outputDir    = flag.String("dir", "", "输出目录 (多文件模式，每个节点单独一个文件)")
 	autoName     = flag.Bool("auto", false, "自动使用 remarks 作为文件名")
 	socksPort    = flag.Int("port", 1234, "Hysteria2 SOCKS 端口")
+	tagTemplate  = flag.String("tag", generator.DefaultTagTemplate, "多节点出站 tag 模板: {sub}, {index}, {id}, {remarks}")
+	subName      = flag.String("subname", "", "订阅名称 (用于 tag 模板中的 {sub})")
+	manifestFile = flag.String("manifest", "", "输出清单文件 (记录节点 tag 和文件)")
+	balancerMode = flag.String("strategy", "leastPing", "负载均衡策略: leastPing, leastLoad, random, roundRobin")
+	fallbackTag  = flag.String("fallback", "", "负载均衡 fallbackTag (默认第一个节点)")
+	probeURL     = flag.String("probe-url", generator.DefaultProbeURL, "节点探测地址")
+	probeInt     = flag.String("probe-interval", generator.DefaultProbeInterval, "节点探测间隔")
+	baseRouting  = flag.String("routing", "", "负载均衡合并的路由文件 (如 confdir/routing/rule.json)")
+	chainMode    = flag.Bool("chain", false, "链式代理: 按输入顺序 前置 → … → 落地 串联节点")
+	sockoptFile  = flag.String("sockopt", "", "出站默认 sockopt 文件 (JSON，格式同 Xray sockopt)")
+	sockMark     = flag.Int("mark", 0, "出站 sockopt.mark (SO_MARK)")
+	sockIface    = flag.String("interface", "", "出站 sockopt.interface (绑定网卡)")
+	sockTFO      = flag.Bool("tfo", false, "出站 sockopt.tcpFastOpen")
+	sockKeepIntv = flag.Int("keepalive", 0, "出站 sockopt.tcpKeepAliveInterval (秒)")
+	sockDomain   = flag.String("domain-strategy", "", "出站 sockopt.domainStrategy: UseIPv4, UseIPv6, ForceIP ...")
+	muxFile      = flag.String("mux-file", "", "出站 mux 设置文件 (JSON，默认读取 -dir 下 _meta.json 的 mux 字段)")
+	muxEnable    = flag.Bool("mux", false, "启用 mux 多路复用")
+	muxConc      = flag.Int("mux-concurrency", 8, "mux.concurrency (-1 仅使用 XUDP)")
+	xudpConc     = flag.Int("xudp-concurrency", 16, "mux.xudpConcurrency")
+	xudpUDP443   = flag.String("xudp-udp443", "", "mux.xudpProxyUDP443: reject, allow, skip")
+	dnsBootstrap = flag.Bool("dns", false, "预解析节点服务器域名，写入 DNS hosts 片段或直连规则 (见 proxylink dns)")
+	dnsServer    = flag.String("dns-server", bootstrap.DefaultServer, "预解析使用的引导 DNS (IP[:端口])")
+	dnsMode      = flag.String("dns-mode", bootstrap.ModeHosts, "预解析结果写入方式: hosts, sockopt")
+	dnsIPv6      = flag.Bool("dns-ipv6", false, "预解析时同时查询 AAAA 记录")
+	moduleDir    = flag.String("module", defaultModuleDir, "模块目录 (-dns 写入的位置)")
+	targetXray   = flag.String("target-xray", "", "目标 Xray 版本 (如 v25.3)，按版本改写/剔除不兼容的节点")
 	prettyPrint  = flag.Bool("pretty", true, "美化 JSON 输出")
 	insecure     = flag.Bool("insecure", false, "跳过 TLS 证书验证 (用于 Android 等环境)")
 	showHelp     = flag.Bool("h", false, "显示帮助")
 )
 
+// outboundSockopt 应用到所有生成出站的 sockopt
+var outboundSockopt *generator.SockoptBean
+
+// outboundMux 应用到所有生成出站的 mux 设置
+var outboundMux *generator.MuxBean
+
+// xrayTarget 目标 Xray 版本 (-target-xray)
+var xrayTarget *generator.XrayTarget
+
+// subcommands 子命令表
+var subcommands = map[string]func(args []string) error{
+	"keygen":   runKeygen,
+	"assemble": runAssemble,
+	"lint":     runLint,
+	"routing":  runRouting,
+	"route":    runRoute,
+	"geo":      runGeo,
+	"dns":      runDNS,
+	"fakedns":  runFakeDNS,
+	"appgroup": runAppGroup,
+	"apps":     runApps,
+	"tproxy":   runTproxy,
+}
+
 func main() {
+	// 子命令模式
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	flag.Usage = usage
 	flag.Parse()
 
@@ -41,6 +104,23 @@ func main() {
 
 	var err error
 
+	if *dnsBootstrap {
+		err = bootstrap.ValidateMode(*dnsMode)
+	}
+	if err == nil {
+		outboundSockopt, err = loadSockopt()
+	}
+	if err == nil {
+		outboundMux, err = loadMux()
+	}
+	if err == nil {
+		xrayTarget, err = generator.ParseXrayTarget(*targetXray)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+
 	switch {
 	case *parseURI != "":
 		err = handleParseSingle(*parseURI)
@@ -48,12 +128,21 @@ func main() {
 		err = handleParseFile(*parseFile)
 	case *subURL != "":
 		err = handleSubscription(*subURL)
+	case *wgConfFile != "":
+		err = handleWireGuardConf(*wgConfFile)
 	case flag.NArg() > 0:
 		err = handleParseSingle(flag.Arg(0))
 	default:
 		err = handleStdin()
 	}
 
+	if err == nil {
+		err = writeBootstrap()
+	}
+	if err == nil {
+		err = writeManifest()
+	}
+
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
 		os.Exit(1)
@@ -68,16 +157,32 @@ func usage() {
   proxylink -parse "vless://..."
   proxylink -file nodes.txt
   proxylink -sub "https://example.com/sub"
+  proxylink -wgconf wg0.conf
   echo "vless://..." | proxylink
 
+子命令:
+  keygen   生成/推导 x25519 密钥 (WireGuard/Reality)
+  assemble 合并 confdir、路由和当前出站，输出最终生效的 Xray 配置
+  lint     检查 config/xray 下的配置 (语法、字段、引用、端口)
+  routing  路由规则工具 (build: 编译为 rule.json, import: 导入 Clash/Surge/sing-box 规则集)
+  route    路由模拟 (test: 按生效路由匹配域名/IP，输出命中的规则和出站)
+  geo      geosite/geoip dat 文件工具 (list/show/lookup/build)
+  dns      节点域名预解析 (refresh: 按 TTL 重新解析, list: 查看解析结果)
+  fakedns  FakeDNS 模式 (on/off: 生成/删除 confdir 片段, status: 查看状态)
+  appgroup 应用分组 (apply: 生成分组入站/路由规则/UID 映射, off: 删除, list: 查看)
+  apps     已安装应用 (resolve: 包名/UID 互查, list: 列出应用及各用户 UID)
+  tproxy   透明代理规则 (plan: 按 tproxy.conf 输出完整 iptables/ip 规则, check: 校验配置)
+
 选项:`)
 	flag.PrintDefaults()
 	fmt.Println(`
 输出格式:
   json   - ProfileItem JSON (默认)
   xray   - Xray 出站配置
+  xray-balancer - 全部节点 + 负载均衡/观测的 confdir 片段
   hy2    - Hysteria2 原生配置
   uri    - 生成链接
+  wgconf - WireGuard 配置文件 (仅 WireGuard 节点)
 
 示例:
   # 解析单条，输出 Xray 配置
@@ -92,11 +197,35 @@ func usage() {
   # 订阅转换，每个节点单独输出一个文件到指定目录
   proxylink -sub "https://..." -format xray -dir ./nodes
 
+  # 多节点合并输出，tag 使用订阅名和序号，并记录清单
+  proxylink -sub "https://..." -subname airport -tag "{sub}-{index}" -format xray -o all.json -manifest tags.json
+
+  # 订阅节点自动选优 (合并 rule.json 的路由规则)
+  proxylink -sub "https://..." -format xray-balancer -strategy leastPing -routing confdir/routing/rule.json -o balancer.json
+
+  # 链式代理: 文件中按 前置 → 落地 顺序排列节点
+  proxylink -file chain.txt -chain -format xray -o chain.json
+
+  # 出站打标记并优先 IPv4，避免透明代理回环
+  proxylink -sub "https://..." -format xray -dir ./nodes -mark 255 -domain-strategy UseIPv4
+
+  # 预解析节点域名写入 hosts 片段，避免解析节点域名时经过代理本身
+  proxylink -sub "https://..." -format xray -dir ./nodes -dns -dns-server 223.5.5.5
+
+  # 高延迟节点启用 mux 和 XUDP
+  proxylink -parse "vmess://..." -format xray -mux -mux-concurrency 8 -xudp-concurrency 16 -xudp-udp443 skip
+
+  # 按目标内核版本生成 (h2 改写为 xhttp，剔除 QUIC/alterId 节点)
+  proxylink -sub "https://..." -format xray -dir ./nodes -target-xray v25.3 -manifest report.json
+
   # Android 设备跳过证书验证
   proxylink -sub "https://..." -insecure -format xray -dir ./nodes
 
   # 从文件批量解析，每个节点单独输出
-  proxylink -file nodes.txt -format hy2 -dir ./configs`)
+  proxylink -file nodes.txt -format hy2 -dir ./configs
+
+  # WireGuard 配置文件转 Xray 出站
+  proxylink -wgconf warp.conf -format xray`)
 }
 
 func handleParseSingle(uri string) error {
@@ -104,11 +233,15 @@ func handleParseSingle(uri string) error {
 	if err != nil {
 		return err
 	}
-
-	output, err := formatSingleProfile(profile)
+	applySubName(profile)
+	if err := checkXrayTarget(profile); err != nil {
+		return err
+	}
+	output, tag, err := formatSingleProfile(profile)
 	if err != nil {
 		return err
 	}
+	recordNode(1, profile, tag, "")
 
 	return writeOutput(output, profile.Remarks)
 }
@@ -118,9 +251,39 @@ func handleParseFile(filename string) error {
 	if err != nil {
 		return err
 	}
+	if parser.IsWireGuardConf(string(content)) {
+		return handleWireGuardConf(filename)
+	}
 	return handleBatch(string(content))
 }
 
+// handleWireGuardConf 解析 WireGuard 配置文件，文件名作为 remarks
+func handleWireGuardConf(filename string) error {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	profile, err := parser.ParseWireGuardConf(string(content))
+	if err != nil {
+		return err
+	}
+	if name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)); name != "" {
+		profile.Remarks = name
+	}
+	applySubName(profile)
+	if err := checkXrayTarget(profile); err != nil {
+		return err
+	}
+	output, tag, err := formatSingleProfile(profile)
+	if err != nil {
+		return err
+	}
+	recordNode(1, profile, tag, "")
+
+	return writeOutput(output, profile.Remarks)
+}
+
 func handleStdin() error {
 	content, err := io.ReadAll(os.Stdin)
 	if err != nil {
@@ -130,6 +293,22 @@ func handleStdin() error {
 	if input == "" {
 		return fmt.Errorf("无输入内容")
 	}
+	if parser.IsWireGuardConf(input) {
+		profile, err := parser.ParseWireGuardConf(input)
+		if err != nil {
+			return err
+		}
+		applySubName(profile)
+		if err := checkXrayTarget(profile); err != nil {
+			return err
+		}
+		output, tag, err := formatSingleProfile(profile)
+		if err != nil {
+			return err
+		}
+		recordNode(1, profile, tag, "")
+		return writeOutput(output, profile.Remarks)
+	}
 	return handleBatch(input)
 }
 
@@ -165,10 +344,34 @@ func handleBatch(content string) error {
 	return outputProfiles(profiles)
 }
 
+// applySubName 设置订阅 ID
+func applySubName(profile *model.ProfileItem) {
+	if *subName != "" {
+		profile.SubscriptionID = *subName
+	}
+}
+
 // outputProfiles 输出多个配置
 func outputProfiles(profiles []*model.ProfileItem) error {
+	for _, p := range profiles {
+		applySubName(p)
+	}
+
+	profiles = applyXrayTarget(profiles)
+	if len(profiles) == 0 {
+		return fmt.Errorf("没有可在 Xray %s 上运行的节点", xrayTarget)
+	}
+
+	// 链式代理: 所有节点合并为一个配置
+	if *chainMode {
+		return outputChain(profiles)
+	}
+
 	// 多文件模式: -dir 指定目录
 	if *outputDir != "" {
+		if *outputFormat == "xray-balancer" {
+			return fmt.Errorf("xray-balancer 将所有节点合并为一个负载均衡配置，不支持 -dir，请使用 -o")
+		}
 		return writeMultipleFiles(profiles)
 	}
 
@@ -181,6 +384,170 @@ func outputProfiles(profiles []*model.ProfileItem) error {
 	return writeOutput(output, "")
 }
 
+// applyXrayTarget 按 -target-xray 调整节点，剔除目标内核无法运行的节点
+// 仅对 Xray 输出格式生效
+func applyXrayTarget(profiles []*model.ProfileItem) []*model.ProfileItem {
+	if xrayTarget == nil || !isXrayFormat() {
+		return profiles
+	}
+	currentManifest.Target = xrayTarget.String()
+
+	var kept []*model.ProfileItem
+	for i, p := range profiles {
+		decisions := generator.ApplyXrayTarget(p, *xrayTarget)
+		for _, d := range decisions {
+			recordDecision(i+1, p, d)
+		}
+		if !generator.CompatRejected(decisions) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// checkXrayTarget 单节点模式下按 -target-xray 调整节点，无法运行时报错
+func checkXrayTarget(profile *model.ProfileItem) error {
+	if len(applyXrayTarget([]*model.ProfileItem{profile})) == 0 {
+		return fmt.Errorf("%s: 无法在 Xray %s 上运行", profile.Remarks, xrayTarget)
+	}
+	return nil
+}
+
+// isXrayFormat 判断是否输出 Xray 配置
+func isXrayFormat() bool {
+	return *outputFormat == "xray" || *outputFormat == "xray-balancer"
+}
+
+// applyOutboundOptions 应用 sockopt 和 mux 设置
+func applyOutboundOptions(outbound *generator.XrayOutbound, profile *model.ProfileItem) {
+	generator.ApplySockopt(outbound, outboundSockopt)
+	if msg := generator.ApplyMux(outbound, profile, outboundMux); msg != "" {
+		warn("%s", msg)
+	}
+}
+
+// loadMux 读取 mux 设置文件 (或订阅目录的 _meta.json) 并叠加命令行参数
+func loadMux() (*generator.MuxBean, error) {
+	mux := &generator.MuxBean{Concurrency: *muxConc, XudpConcurrency: *xudpConc}
+
+	file := *muxFile
+	if file == "" && *outputDir != "" {
+		if meta := filepath.Join(*outputDir, "_meta.json"); fileExists(meta) {
+			file = meta
+		}
+	}
+	if file != "" {
+		loaded, err := generator.LoadMux(file)
+		if err != nil {
+			return nil, err
+		}
+		mux = loaded
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "mux":
+			mux.Enabled = *muxEnable
+		case "mux-concurrency":
+			mux.Enabled = true
+			mux.Concurrency = *muxConc
+		case "xudp-concurrency":
+			mux.Enabled = true
+			mux.XudpConcurrency = *xudpConc
+		case "xudp-udp443":
+			mux.Enabled = true
+			mux.XudpProxyUDP443 = *xudpUDP443
+		}
+	})
+
+	if err := generator.ValidateMux(mux); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// loadSockopt 读取 -sockopt 文件并叠加命令行参数 (命令行优先)
+func loadSockopt() (*generator.SockoptBean, error) {
+	sockopt := &generator.SockoptBean{}
+	if *sockoptFile != "" {
+		loaded, err := generator.LoadSockopt(*sockoptFile)
+		if err != nil {
+			return nil, err
+		}
+		sockopt = loaded
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "mark":
+			sockopt.Mark = *sockMark
+		case "interface":
+			sockopt.Interface = *sockIface
+		case "tfo":
+			sockopt.TcpFastOpen = *sockTFO
+		case "keepalive":
+			sockopt.TcpKeepAliveInterval = *sockKeepIntv
+		case "domain-strategy":
+			sockopt.DomainStrategy = *sockDomain
+		}
+	})
+
+	// 预解析的结果只对 Xray 内置 DNS 生效，出站需要用内置 DNS 解析服务器地址
+	if *dnsBootstrap && sockopt.DomainStrategy == "" {
+		sockopt.DomainStrategy = "UseIP"
+	}
+
+	if err := generator.ValidateSockopt(sockopt); err != nil {
+		return nil, err
+	}
+	return sockopt, nil
+}
+
+// outputChain 输出链式代理配置
+func outputChain(profiles []*model.ProfileItem) error {
+	if *outputFormat != "xray" {
+		return fmt.Errorf("-chain 仅支持 -format xray")
+	}
+
+	config, err := generator.GenerateChainConfig(profiles)
+	if err != nil {
+		return err
+	}
+	for i, outbound := range config.Outbounds {
+		applyOutboundOptions(outbound, profiles[i])
+		recordNode(i+1, profiles[i], outbound.Tag, "")
+	}
+
+	output, err := toJSON(config)
+	if err != nil {
+		return err
+	}
+
+	// 以落地节点命名
+	exit := profiles[len(profiles)-1]
+	if *outputDir != "" {
+		if err := os.MkdirAll(*outputDir, 0755); err != nil {
+			return fmt.Errorf("创建目录失败: %v", err)
+		}
+		name := sanitizeFilename(exit.Remarks)
+		if name == "" {
+			name = "chain"
+		}
+		path := filepath.Join(*outputDir, name+getFileExtension())
+		if err := os.WriteFile(path, []byte(output), 0644); err != nil {
+			return fmt.Errorf("写入文件失败: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "已写入: %s\n", path)
+		return nil
+	}
+	return writeOutput(output, exit.Remarks)
+}
+
 // writeMultipleFiles 每个节点单独输出一个文件
 func writeMultipleFiles(profiles []*model.ProfileItem) error {
 	// 创建目录
@@ -191,7 +558,7 @@ func writeMultipleFiles(profiles []*model.ProfileItem) error {
 	ext := getFileExtension()
 
 	for i, profile := range profiles {
-		output, err := formatSingleProfile(profile)
+		output, tag, err := formatSingleProfile(profile)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "警告: 格式化 %s 失败: %v\n", profile.Remarks, err)
 			continue
@@ -210,6 +577,7 @@ func writeMultipleFiles(profiles []*model.ProfileItem) error {
 			continue
 		}
 		fmt.Fprintf(os.Stderr, "已写入: %s\n", filepath)
+		recordNode(i+1, profile, tag, filename)
 	}
 
 	return nil
@@ -247,6 +615,8 @@ func getFileExtension() string {
 	switch *outputFormat {
 	case "uri":
 		return ".txt"
+	case "wgconf":
+		return ".conf"
 	default:
 		return ".json"
 	}
@@ -286,30 +656,48 @@ func sanitizeFilename(name string) string {
 	return strings.TrimSpace(name)
 }
 
-func formatSingleProfile(profile *model.ProfileItem) (string, error) {
+// formatSingleProfile 输出单个节点，同时返回节点的最终 tag (由调用方记入清单)
+func formatSingleProfile(profile *model.ProfileItem) (string, string, error) {
+	var output string
+	var err error
 	switch *outputFormat {
 	case "xray":
-		config := generator.GenerateXrayConfig(profile)
-		return toJSON(config)
+		var config *generator.XrayConfig
+		if config, err = generator.GenerateXrayConfig(profile); err != nil {
+			return "", "", err
+		}
+		for _, outbound := range config.Outbounds {
+			applyOutboundOptions(outbound, profile)
+		}
+		output, err = toJSON(config)
 	case "hy2":
-		config := generator.GenerateHysteria2Config(profile, *socksPort)
-		return toJSON(config)
+		output, err = toJSON(generator.GenerateHysteria2Config(profile, *socksPort))
 	case "uri":
-		return encoder.ToURI(profile), nil
+		output = encoder.ToURI(profile)
+	case "wgconf":
+		output, err = generator.GenerateWireGuardConf(profile)
+	case "xray-balancer":
+		var nodes []manifestEntry
+		if output, nodes, err = formatBalancer([]*model.ProfileItem{profile}); err == nil && len(nodes) > 0 {
+			return output, nodes[0].Tag, nil
+		}
 	default:
-		return toJSON(profile)
+		output, err = toJSON(profile)
 	}
+	return output, generator.DefaultTag, err
 }
 
 func formatProfiles(profiles []*model.ProfileItem) (string, error) {
 	switch *outputFormat {
 	case "xray":
-		var outbounds []*generator.XrayOutbound
-		for _, p := range profiles {
-			outbounds = append(outbounds, generator.GenerateXrayOutbound(p))
-		}
+		outbounds, nodes := generateTaggedOutbounds(profiles, false)
+		recordNodes(nodes)
 		config := &generator.XrayConfig{Outbounds: outbounds}
 		return toJSON(config)
+	case "xray-balancer":
+		output, nodes, err := formatBalancer(profiles)
+		recordNodes(nodes)
+		return output, err
 	case "hy2":
 		var configs []*generator.Hysteria2Config
 		for _, p := range profiles {
@@ -319,11 +707,76 @@ func formatProfiles(profiles []*model.ProfileItem) (string, error) {
 	case "uri":
 		uris := encoder.ToURIBatch(profiles)
 		return strings.Join(uris, "\n"), nil
+	case "wgconf":
+		if len(profiles) != 1 {
+			return "", fmt.Errorf("wgconf 格式不支持多节点合并输出，请使用 -dir")
+		}
+		return generator.GenerateWireGuardConf(profiles[0])
 	default:
 		return toJSON(profiles)
 	}
 }
 
+// formatBalancer 生成负载均衡 confdir 片段，返回各节点的清单条目
+func formatBalancer(profiles []*model.ProfileItem) (string, []manifestEntry, error) {
+	outbounds, nodes := generateTaggedOutbounds(profiles, true)
+
+	var base *generator.RoutingConfig
+	if *baseRouting != "" {
+		data, err := os.ReadFile(*baseRouting)
+		if err != nil {
+			return "", nil, err
+		}
+		if base, err = generator.ParseRoutingFile(data); err != nil {
+			return "", nil, fmt.Errorf("解析路由文件 %s 失败: %v", *baseRouting, err)
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, "警告: 未指定 -routing，生成的 routing 仅包含负载均衡规则")
+	}
+
+	config, err := generator.GenerateBalancerConfig(outbounds, base, generator.BalancerOptions{
+		Strategy:      *balancerMode,
+		FallbackTag:   *fallbackTag,
+		ProbeURL:      *probeURL,
+		ProbeInterval: *probeInt,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	output, err := toJSON(config)
+	return output, nodes, err
+}
+
+// generateTaggedOutbounds 生成多个出站并分配 tag
+// 单节点保持 proxy 以兼容热切换，多节点 (或 unique 为 true) 按 -tag 模板分配唯一 tag
+// 返回与出站一一对应的清单条目，由输出最终配置的调用方记录
+func generateTaggedOutbounds(profiles []*model.ProfileItem, unique bool) ([]*generator.XrayOutbound, []manifestEntry) {
+	var outbounds []*generator.XrayOutbound
+	var indexes []int
+	for i, p := range profiles {
+		outbound, err := generator.GenerateXrayOutbound(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 生成 %s 失败: %v\n", p.Remarks, err)
+			continue
+		}
+		applyOutboundOptions(outbound, p)
+		outbounds = append(outbounds, outbound)
+		indexes = append(indexes, i)
+	}
+
+	tagger := generator.NewTagAllocator(*tagTemplate)
+	var nodes []manifestEntry
+	for n, outbound := range outbounds {
+		i := indexes[n]
+		if unique || len(outbounds) > 1 {
+			outbound.Tag = tagger.Next(profiles[i], i+1)
+		}
+		nodes = append(nodes, newManifestEntry(i+1, profiles[i], outbound.Tag, ""))
+	}
+
+	return outbounds, nodes
+}
+
 func toJSON(data interface{}) (string, error) {
 	var jsonBytes []byte
 	var err error
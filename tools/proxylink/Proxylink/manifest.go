@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"proxylink/pkg/generator"
+	"proxylink/pkg/model"
+)
+
+// manifestEntry 清单中的单个节点
+type manifestEntry struct {
+	Index    int    `json:"index"`
+	Tag      string `json:"tag"`
+	Remarks  string `json:"remarks"`
+	Protocol string `json:"protocol"`
+	Server   string `json:"server"`
+	Port     string `json:"port"`
+	File     string `json:"file,omitempty"`
+}
+
+// manifestDecision 按 -target-xray 对节点做出的兼容性处理
+// Input 为节点在输入中的序号 (被剔除的节点不会出现在 nodes 中)
+type manifestDecision struct {
+	Input   int    `json:"input"`
+	Remarks string `json:"remarks"`
+	generator.CompatDecision
+}
+
+// manifest 输出清单，记录每个节点的 tag 和输出位置
+type manifest struct {
+	Format    string             `json:"format"`
+	Target    string             `json:"target,omitempty"`
+	Nodes     []manifestEntry    `json:"nodes"`
+	Decisions []manifestDecision `json:"decisions,omitempty"`
+	Warnings  []string           `json:"warnings,omitempty"`
+}
+
+// currentManifest 当前运行的清单 (-manifest 指定时写出)
+var currentManifest = &manifest{}
+
+// newManifestEntry 生成节点的清单条目
+func newManifestEntry(index int, profile *model.ProfileItem, tag, file string) manifestEntry {
+	return manifestEntry{
+		Index:    index,
+		Tag:      tag,
+		Remarks:  profile.Remarks,
+		Protocol: profile.ConfigType.String(),
+		Server:   profile.Server,
+		Port:     profile.ServerPort,
+		File:     file,
+	}
+}
+
+// recordNode 记录节点到清单，每个节点只在确定最终 tag 和输出位置后记录一次
+func recordNode(index int, profile *model.ProfileItem, tag, file string) {
+	currentManifest.Nodes = append(currentManifest.Nodes, newManifestEntry(index, profile, tag, file))
+}
+
+// recordNodes 记录多个节点到清单
+func recordNodes(entries []manifestEntry) {
+	currentManifest.Nodes = append(currentManifest.Nodes, entries...)
+}
+
+// recordDecision 输出兼容性处理结果并记录到清单
+func recordDecision(index int, profile *model.ProfileItem, d generator.CompatDecision) {
+	fmt.Fprintf(os.Stderr, "兼容[%s]: %s: %s\n", d.Action, profile.Remarks, d.Message)
+	currentManifest.Decisions = append(currentManifest.Decisions, manifestDecision{
+		Input:          index,
+		Remarks:        profile.Remarks,
+		CompatDecision: d,
+	})
+}
+
+// warn 输出警告并记录到清单
+func warn(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprintf(os.Stderr, "警告: %s\n", msg)
+	currentManifest.Warnings = append(currentManifest.Warnings, msg)
+}
+
+// writeManifest 写出清单文件
+func writeManifest() error {
+	if *manifestFile == "" {
+		return nil
+	}
+
+	currentManifest.Format = *outputFormat
+	output, err := toJSON(currentManifest)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*manifestFile, []byte(output), 0644); err != nil {
+		return fmt.Errorf("写入清单失败: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "已写入清单: %s\n", *manifestFile)
+	return nil
+}
@@ -0,0 +1,301 @@
+package appgroup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"proxylink/pkg/util"
+)
+
+// FragmentName 分组入站的 confdir 片段，排在 03_inbounds.json 之后加载
+const FragmentName = "03_inbounds_appgroups.json"
+
+// MapName tproxy.sh 读取的 UID → 端口映射，放在 tproxy.conf 同目录
+const MapName = "app_groups.conf"
+
+// DefaultBasePort 未指定端口的分组从这里开始依次分配 (tproxy-in 默认 12345)
+const DefaultBasePort = 12346
+
+// TemplateInbound 分组入站的模板: 透明代理入站
+const TemplateInbound = "tproxy-in"
+
+// TagPrefix 分组入站的 tag 前缀，路由规则按 inboundTag 匹配
+const TagPrefix = "app-"
+
+// Group 一个应用分组: 分组内应用的流量经独立入站进入 Xray，按 inboundTag 分流
+type Group struct {
+	Name        string   `json:"name"`
+	Apps        []string `json:"apps"` // 包名、"用户ID:包名" 或 UID
+	OutboundTag string   `json:"outboundTag,omitempty"`
+	BalancerTag string   `json:"balancerTag,omitempty"`
+	Port        int      `json:"port,omitempty"` // 为空时自动分配
+	Enabled     *bool    `json:"enabled,omitempty"`
+}
+
+// IsEnabled 未设置 enabled 时视为启用
+func (g *Group) IsEnabled() bool {
+	return g.Enabled == nil || *g.Enabled
+}
+
+// InboundTag 分组入站的 tag
+func (g *Group) InboundTag() string {
+	return TagPrefix + g.Name
+}
+
+// Target 分组流量的去向，用于显示
+func (g *Group) Target() string {
+	if g.BalancerTag != "" {
+		return "负载均衡 " + g.BalancerTag
+	}
+	return g.OutboundTag
+}
+
+// Config app_groups.json 内容
+type Config struct {
+	BasePort int      `json:"basePort,omitempty"`
+	Groups   []*Group `json:"groups"`
+}
+
+// groupNameRegex 分组名会出现在入站 tag 和映射文件中，限制为小写字母、数字、- 和 _
+var groupNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Load 读取分组配置，拒绝未知字段以便发现拼写错误
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	dec.DisallowUnknownFields()
+	var c Config
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %v", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &c, nil
+}
+
+// Validate 校验分组名、去向和手动指定的端口
+func (c *Config) Validate() error {
+	if c.BasePort < 0 || c.BasePort > 65535 {
+		return fmt.Errorf("basePort %d 超出范围", c.BasePort)
+	}
+	names := map[string]bool{}
+	ports := map[int]string{}
+	for i, g := range c.Groups {
+		if !groupNameRegex.MatchString(g.Name) {
+			return fmt.Errorf("分组 #%d: 名称 %q 只能包含小写字母、数字、- 和 _", i+1, g.Name)
+		}
+		if names[g.Name] {
+			return fmt.Errorf("分组 %s 重复", g.Name)
+		}
+		names[g.Name] = true
+		if (g.OutboundTag == "") == (g.BalancerTag == "") {
+			return fmt.Errorf("分组 %s: outboundTag 和 balancerTag 必须且只能设置一个", g.Name)
+		}
+		if g.Port != 0 {
+			if g.Port < 1 || g.Port > 65535 {
+				return fmt.Errorf("分组 %s: 端口 %d 超出范围", g.Name, g.Port)
+			}
+			if other, ok := ports[g.Port]; ok {
+				return fmt.Errorf("分组 %s 与 %s 的端口 %d 重复", g.Name, other, g.Port)
+			}
+			ports[g.Port] = g.Name
+		}
+	}
+	return nil
+}
+
+// Enabled 返回启用的分组
+func (c *Config) Enabled() []*Group {
+	var groups []*Group
+	for _, g := range c.Groups {
+		if g.IsEnabled() {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// AssignPorts 为未指定端口的启用分组分配端口: 从 basePort 起跳过已占用的端口
+// reserved 为其他入站、tproxy.conf 等已使用的端口
+func (c *Config) AssignPorts(reserved map[int]bool) error {
+	used := map[int]bool{}
+	for port := range reserved {
+		used[port] = true
+	}
+	for _, g := range c.Enabled() {
+		if g.Port == 0 {
+			continue
+		}
+		if reserved[g.Port] {
+			return fmt.Errorf("分组 %s: 端口 %d 已被占用", g.Name, g.Port)
+		}
+		used[g.Port] = true
+	}
+
+	next := c.BasePort
+	if next == 0 {
+		next = DefaultBasePort
+	}
+	for _, g := range c.Enabled() {
+		if g.Port != 0 {
+			continue
+		}
+		for used[next] {
+			next++
+		}
+		if next > 65535 {
+			return fmt.Errorf("分组 %s: 没有可用端口", g.Name)
+		}
+		g.Port = next
+		used[next] = true
+	}
+	return nil
+}
+
+// Mark tproxy.conf 中的标记 (MARK_VALUE、MARK_VALUE6、ROUTING_MARK)
+type Mark struct {
+	Name  string // 变量名
+	Value uint32
+	Mask  uint32
+}
+
+// ParseMark 解析 iptables 的 值[/掩码] 写法，支持十进制和 0x 十六进制
+func ParseMark(name, s string) (Mark, error) {
+	m := Mark{Name: name, Mask: 0xffffffff}
+	value, mask, hasMask := strings.Cut(s, "/")
+	v, err := strconv.ParseUint(value, 0, 32)
+	if err != nil {
+		return m, fmt.Errorf("%s=%q 不是有效的标记值", name, s)
+	}
+	m.Value = uint32(v)
+	if hasMask {
+		v, err := strconv.ParseUint(mask, 0, 32)
+		if err != nil {
+			return m, fmt.Errorf("%s=%q 不是有效的标记值", name, s)
+		}
+		m.Mask = uint32(v)
+	}
+	return m, nil
+}
+
+// Matches 端口作为标记值时是否与 m 相同 (按掩码比较)
+func (m Mark) Matches(port int) bool {
+	return uint32(port)&m.Mask == m.Value&m.Mask
+}
+
+// CheckMarks TPROXY 模式下分组端口同时作为 connmark 值，与 tproxy.conf 的标记相同时
+// 分组规则会截获普通连接或被绕过规则放行: 手动指定的端口冲突时报错，
+// 与标记相同的端口加入 reserved，自动分配时跳过
+func (c *Config) CheckMarks(marks []Mark, reserved map[int]bool) error {
+	for _, g := range c.Enabled() {
+		for _, m := range marks {
+			if g.Port != 0 && m.Matches(g.Port) {
+				return fmt.Errorf("分组 %s: 端口 %d 同时作为连接标记，与 %s 冲突", g.Name, g.Port, m.Name)
+			}
+		}
+	}
+	for _, m := range marks {
+		if m.Mask == 0xffffffff {
+			if m.Value <= 65535 {
+				reserved[int(m.Value)] = true
+			}
+			continue
+		}
+		for port := 1; port <= 65535; port++ {
+			if m.Matches(port) {
+				reserved[port] = true
+			}
+		}
+	}
+	return nil
+}
+
+// BuildInbounds 以透明代理入站 base 为模板，为每个分组生成 tag 和端口不同的入站
+// 监听地址、嗅探和 sockopt 与模板一致，因此 tproxy-in 启用 FakeDNS 时分组入站同样生效
+func BuildInbounds(base json.RawMessage, groups []*Group) ([]byte, error) {
+	var template map[string]json.RawMessage
+	if err := json.Unmarshal(base, &template); err != nil {
+		return nil, err
+	}
+
+	inbounds := []json.RawMessage{}
+	for _, g := range groups {
+		in := map[string]json.RawMessage{}
+		for k, v := range template {
+			in[k] = v
+		}
+		in["tag"], _ = json.Marshal(g.InboundTag())
+		in["port"] = json.RawMessage(strconv.Itoa(g.Port))
+		inbounds = append(inbounds, util.OrderedObject(in, base))
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(map[string]interface{}{"inbounds": inbounds}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Mapping 映射文件中的一行: 该 UID 的流量转到分组端口
+type Mapping struct {
+	UID   int    `json:"uid"`
+	Port  int    `json:"port"`
+	Group string `json:"group"`
+	App   string `json:"app"`
+}
+
+// MarshalMap 输出 tproxy.sh 读取的映射文件，每行 "UID 端口 分组 应用"
+func MarshalMap(mappings []Mapping) []byte {
+	var b strings.Builder
+	b.WriteString("# 由 proxylink appgroup apply 根据 config/app_groups.json 生成，请勿手动修改\n")
+	b.WriteString("# uid port group app\n")
+	for _, m := range mappings {
+		fmt.Fprintf(&b, "%d %d %s %s\n", m.UID, m.Port, m.Group, m.App)
+	}
+	return []byte(b.String())
+}
+
+// ReadMap 读取映射文件，文件不存在时返回空列表
+func ReadMap(path string) ([]Mapping, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var mappings []Mapping
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%s:%d: 字段不足", path, i+1)
+		}
+		m := Mapping{Group: fields[2]}
+		if m.UID, err = strconv.Atoi(fields[0]); err != nil {
+			return nil, fmt.Errorf("%s:%d: 无效的 UID %s", path, i+1, fields[0])
+		}
+		if m.Port, err = strconv.Atoi(fields[1]); err != nil {
+			return nil, fmt.Errorf("%s:%d: 无效的端口 %s", path, i+1, fields[1])
+		}
+		if len(fields) > 3 {
+			m.App = fields[3]
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
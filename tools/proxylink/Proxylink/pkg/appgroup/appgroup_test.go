@@ -0,0 +1,57 @@
+package appgroup
+
+import (
+	"testing"
+)
+
+func parseMarks(t *testing.T, vars ...string) []Mark {
+	t.Helper()
+	var marks []Mark
+	for i := 0; i < len(vars); i += 2 {
+		m, err := ParseMark(vars[i], vars[i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		marks = append(marks, m)
+	}
+	return marks
+}
+
+func TestCheckMarks(t *testing.T) {
+	marks := parseMarks(t, "MARK_VALUE", "0x3046", "MARK_VALUE6", "25", "ROUTING_MARK", "0x10000/0xffff0000")
+
+	c := &Config{Groups: []*Group{{Name: "work", OutboundTag: "proxy", Port: 12358}}}
+	if err := c.CheckMarks(marks, map[int]bool{}); err == nil || err.Error() != "分组 work: 端口 12358 同时作为连接标记，与 MARK_VALUE 冲突" {
+		t.Errorf("CheckMarks = %v", err)
+	}
+
+	c = &Config{BasePort: 12358, Groups: []*Group{
+		{Name: "a", OutboundTag: "proxy"},
+		{Name: "b", OutboundTag: "direct", Port: 25, Enabled: new(bool)},
+	}}
+	reserved := map[int]bool{}
+	if err := c.CheckMarks(marks, reserved); err != nil {
+		t.Fatal(err)
+	}
+	if !reserved[12358] || !reserved[25] {
+		t.Errorf("reserved = %v, want 12358 and 25", reserved)
+	}
+	if err := c.AssignPorts(reserved); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Groups[0].Port; got != 12359 {
+		t.Errorf("assigned port = %d, want 12359", got)
+	}
+}
+
+func TestMarkMatches(t *testing.T) {
+	m := parseMarks(t, "ROUTING_MARK", "0x100/0xff00")[0]
+	for port, want := range map[int]bool{0x100: true, 0x1ff: true, 0x200: false, 0x10100: true} {
+		if got := m.Matches(port); got != want {
+			t.Errorf("Matches(%#x) = %v, want %v", port, got, want)
+		}
+	}
+	if _, err := ParseMark("ROUTING_MARK", "0x1/mask"); err == nil {
+		t.Error("ParseMark accepted an invalid mask")
+	}
+}
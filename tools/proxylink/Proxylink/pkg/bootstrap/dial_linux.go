@@ -0,0 +1,33 @@
+package bootstrap
+
+import (
+	"net"
+	"runtime"
+	"syscall"
+)
+
+// dial 连接服务器: Mark 通过 SO_MARK 设置；GID 通过临时切换当前线程的 fsgid 设置，
+// iptables owner 匹配按创建 socket 时的 fsuid/fsgid 判断，切换只影响这一个 socket
+func (r *Resolver) dial(network string) (net.Conn, error) {
+	d := net.Dialer{Timeout: r.Timeout}
+	if r.Mark != 0 {
+		d.Control = func(_, _ string, c syscall.RawConn) error {
+			var err error
+			if cerr := c.Control(func(fd uintptr) {
+				err = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK, int(r.Mark))
+			}); cerr != nil {
+				return cerr
+			}
+			return err
+		}
+	}
+	if r.GID != 0 {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		if err := syscall.Setfsgid(r.GID); err != nil {
+			return nil, err
+		}
+		defer syscall.Setfsgid(syscall.Getegid())
+	}
+	return d.Dial(network, r.Server)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package bootstrap
+
+import "net"
+
+// dial 连接服务器 (非 Linux 没有透明代理规则，忽略 Mark 和 GID)
+func (r *Resolver) dial(network string) (net.Conn, error) {
+	return net.DialTimeout(network, r.Server, r.Timeout)
+}
@@ -0,0 +1,69 @@
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FragmentName hosts 片段的文件名，排在 02_dns.json 和 02_dns_fakedns.json 之后加载
+// Xray 合并多个配置时 dns 字段整体覆盖，因此片段包含前面生效的完整 dns 配置
+const FragmentName = "02_dns_hosts.json"
+
+// HostsFragment 在 base (02_dns.json 或 FakeDNS 片段) 的 dns.hosts 中加入已解析的节点域名
+// base 中已有的同名 hosts 优先，返回片段内容和被跳过的域名
+func HostsFragment(base []byte, entries []*Entry) ([]byte, []string, error) {
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(bytes.TrimPrefix(base, []byte("\xef\xbb\xbf")), &config); err != nil {
+		return nil, nil, err
+	}
+	dns := map[string]json.RawMessage{}
+	if raw, ok := config["dns"]; ok {
+		if err := json.Unmarshal(raw, &dns); err != nil {
+			return nil, nil, fmt.Errorf("dns: %v", err)
+		}
+	}
+	hosts := map[string]json.RawMessage{}
+	if raw, ok := dns["hosts"]; ok {
+		if err := json.Unmarshal(raw, &hosts); err != nil {
+			return nil, nil, fmt.Errorf("dns.hosts: %v", err)
+		}
+	}
+
+	existing := map[string]bool{}
+	for key := range hosts {
+		existing[strings.TrimPrefix(strings.ToLower(key), "full:")] = true
+	}
+
+	var skipped []string
+	for _, e := range entries {
+		if len(e.IPs) == 0 {
+			continue
+		}
+		if existing[e.Domain] {
+			skipped = append(skipped, e.Domain)
+			continue
+		}
+		ips, err := json.Marshal(e.IPs)
+		if err != nil {
+			return nil, nil, err
+		}
+		hosts[e.Domain] = ips
+	}
+
+	raw, err := json.Marshal(hosts)
+	if err != nil {
+		return nil, nil, err
+	}
+	dns["hosts"] = raw
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(map[string]interface{}{"dns": dns}); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), skipped, nil
+}
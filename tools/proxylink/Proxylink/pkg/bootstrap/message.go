@@ -0,0 +1,150 @@
+package bootstrap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DNS 记录类型
+const (
+	typeA     = 1
+	typeCNAME = 5
+	typeAAAA  = 28
+	classIN   = 1
+)
+
+// rcodeNames 常见的 DNS 响应码
+var rcodeNames = map[int]string{
+	1: "FORMERR",
+	2: "SERVFAIL",
+	3: "NXDOMAIN",
+	4: "NOTIMP",
+	5: "REFUSED",
+}
+
+var errShortMessage = errors.New("DNS 响应被截断")
+
+// buildQuery 构造递归查询报文
+func buildQuery(id uint16, name string, qtype uint16) ([]byte, error) {
+	msg := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], 0x0100) // RD
+	binary.BigEndian.PutUint16(msg[4:], 1)      // QDCOUNT
+
+	name = strings.TrimSuffix(name, ".")
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return nil, fmt.Errorf("无效的域名: %s", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, classIN)
+	return msg, nil
+}
+
+// record 响应中的一条 A/AAAA 记录
+type record struct {
+	IP  net.IP
+	TTL uint32
+}
+
+// response 解析后的响应
+type response struct {
+	Truncated bool
+	Records   []record
+	CNAMETTL  uint32 // CNAME 链上最小的 TTL (没有 CNAME 时为 0)
+}
+
+// parseResponse 解析响应报文，只取 answer 段中类型为 qtype 的记录
+func parseResponse(msg []byte, id uint16, qtype uint16) (*response, error) {
+	if len(msg) < 12 {
+		return nil, errShortMessage
+	}
+	if binary.BigEndian.Uint16(msg[0:]) != id {
+		return nil, errors.New("DNS 响应 ID 不匹配")
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	if flags&0x8000 == 0 {
+		return nil, errors.New("不是 DNS 响应")
+	}
+	resp := &response{Truncated: flags&0x0200 != 0}
+	if rcode := int(flags & 0x000f); rcode != 0 {
+		if name, ok := rcodeNames[rcode]; ok {
+			return nil, fmt.Errorf("DNS 返回 %s", name)
+		}
+		return nil, fmt.Errorf("DNS 返回错误码 %d", rcode)
+	}
+
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+	pos := 12
+	for i := 0; i < qdcount; i++ {
+		var err error
+		if pos, err = skipName(msg, pos); err != nil {
+			return nil, err
+		}
+		pos += 4
+	}
+
+	for i := 0; i < ancount; i++ {
+		var err error
+		if pos, err = skipName(msg, pos); err != nil {
+			return nil, err
+		}
+		if pos+10 > len(msg) {
+			return nil, errShortMessage
+		}
+		rtype := binary.BigEndian.Uint16(msg[pos:])
+		class := binary.BigEndian.Uint16(msg[pos+2:])
+		ttl := binary.BigEndian.Uint32(msg[pos+4:])
+		length := int(binary.BigEndian.Uint16(msg[pos+8:]))
+		pos += 10
+		if pos+length > len(msg) {
+			return nil, errShortMessage
+		}
+		data := msg[pos : pos+length]
+		pos += length
+
+		if class != classIN {
+			continue
+		}
+		switch {
+		case rtype == typeCNAME:
+			if resp.CNAMETTL == 0 || ttl < resp.CNAMETTL {
+				resp.CNAMETTL = ttl
+			}
+		case rtype == qtype && rtype == typeA && length == net.IPv4len,
+			rtype == qtype && rtype == typeAAAA && length == net.IPv6len:
+			resp.Records = append(resp.Records, record{IP: append(net.IP{}, data...), TTL: ttl})
+		}
+	}
+	return resp, nil
+}
+
+// skipName 跳过 (可能压缩的) 域名，返回其后的偏移
+func skipName(msg []byte, pos int) (int, error) {
+	for {
+		if pos >= len(msg) {
+			return 0, errShortMessage
+		}
+		n := int(msg[pos])
+		switch {
+		case n == 0:
+			return pos + 1, nil
+		case n&0xc0 == 0xc0:
+			if pos+2 > len(msg) {
+				return 0, errShortMessage
+			}
+			return pos + 2, nil
+		case n&0xc0 != 0:
+			return 0, fmt.Errorf("偏移 %d: 无效的标签类型", pos)
+		}
+		pos += 1 + n
+	}
+}
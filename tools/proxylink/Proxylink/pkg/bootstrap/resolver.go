@@ -0,0 +1,158 @@
+package bootstrap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// DefaultServer 默认的引导 DNS (与 02_dns.json 中直连解析 DoH 域名的服务器一致)
+const DefaultServer = "223.5.5.5"
+
+// Resolver 向单个 UDP DNS 服务器直接查询，不经过系统解析器
+// 透明代理下系统解析会被劫持到 dns-out，引导解析必须绕开
+// 透明代理已启用时，查询还需带上核心进程的标记或组才能命中 tproxy.sh 的放行规则
+type Resolver struct {
+	Server  string // IP:端口
+	Timeout time.Duration
+	IPv6    bool         // 同时查询 AAAA
+	Mark    uint32       // 查询 socket 的 SO_MARK (ROUTING_MARK)，0 为不设置
+	GID     int          // 创建查询 socket 时使用的组 (核心进程组)，0 为不切换
+	Reject  []*net.IPNet // 不接受的地址 (FakeDNS 地址池): 说明查询仍被劫持到了 Xray
+}
+
+// NewResolver 解析服务器地址，只接受 IP 或 IP:端口 (DoH/DoT 本身需要先解析域名)
+func NewResolver(server string) (*Resolver, error) {
+	addr := strings.TrimPrefix(strings.TrimSpace(server), "udp://")
+	if strings.Contains(addr, "://") {
+		return nil, fmt.Errorf("引导 DNS 只支持 IP[:端口] 形式的 UDP DNS: %s", server)
+	}
+	host, port := addr, "53"
+	if h, p, err := net.SplitHostPort(addr); err == nil {
+		host, port = h, p
+	}
+	if net.ParseIP(host) == nil {
+		return nil, fmt.Errorf("引导 DNS 必须是 IP 地址: %s", server)
+	}
+	return &Resolver{Server: net.JoinHostPort(host, port), Timeout: 5 * time.Second}, nil
+}
+
+// Answer 一个域名的解析结果，TTL 取所有记录 (含 CNAME) 中最小的值
+type Answer struct {
+	IPs []net.IP
+	TTL uint32
+}
+
+// Lookup 查询 A (及 AAAA) 记录；IP 字面量直接返回
+func (r *Resolver) Lookup(domain string) (*Answer, error) {
+	if ip := net.ParseIP(domain); ip != nil {
+		return &Answer{IPs: []net.IP{ip}}, nil
+	}
+	types := []uint16{typeA}
+	if r.IPv6 {
+		types = append(types, typeAAAA)
+	}
+
+	answer := &Answer{}
+	var rejected []string
+	for _, qtype := range types {
+		resp, err := r.query(domain, qtype)
+		if err != nil {
+			return nil, err
+		}
+		ttls := []uint32{resp.CNAMETTL}
+		for _, rec := range resp.Records {
+			if r.rejects(rec.IP) {
+				rejected = append(rejected, rec.IP.String())
+				continue
+			}
+			answer.IPs = append(answer.IPs, rec.IP)
+			ttls = append(ttls, rec.TTL)
+		}
+		for _, ttl := range ttls {
+			if ttl > 0 && (answer.TTL == 0 || ttl < answer.TTL) {
+				answer.TTL = ttl
+			}
+		}
+	}
+	if len(answer.IPs) == 0 && len(rejected) > 0 {
+		return nil, fmt.Errorf("%s 解析到 FakeDNS 地址 %s，查询被透明代理劫持", domain, strings.Join(rejected, ","))
+	}
+	if len(answer.IPs) == 0 {
+		return nil, fmt.Errorf("%s 没有 A/AAAA 记录", domain)
+	}
+	return answer, nil
+}
+
+// rejects 判断地址是否在 Reject 中
+func (r *Resolver) rejects(ip net.IP) bool {
+	for _, n := range r.Reject {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// query 先用 UDP 查询，响应被截断时改用 TCP
+func (r *Resolver) query(domain string, qtype uint16) (*response, error) {
+	id := uint16(rand.Intn(1 << 16))
+	msg, err := buildQuery(id, domain, qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.exchange("udp", msg, id, qtype)
+	if err == nil && resp.Truncated {
+		resp, err = r.exchange("tcp", msg, id, qtype)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询 %s: %v", domain, err)
+	}
+	return resp, nil
+}
+
+func (r *Resolver) exchange(network string, msg []byte, id, qtype uint16) (*response, error) {
+	conn, err := r.dial(network)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(r.Timeout))
+
+	if network == "tcp" {
+		framed := binary.BigEndian.AppendUint16(nil, uint16(len(msg)))
+		if _, err := conn.Write(append(framed, msg...)); err != nil {
+			return nil, err
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, err
+		}
+		return parseResponse(buf, id, qtype)
+	}
+
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := parseResponse(buf[:n], id, qtype)
+		if err != nil && n >= 2 && binary.BigEndian.Uint16(buf) != id {
+			continue // 迟到的其他响应
+		}
+		return resp, err
+	}
+}
@@ -0,0 +1,246 @@
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 预解析结果的写入方式
+const (
+	ModeHosts   = "hosts"   // 写入 dns.hosts 片段
+	ModeSockopt = "sockopt" // 出站 sockopt.domainStrategy + 节点 IP 直连规则
+)
+
+// TTL 的上下限: 过短的 TTL 会让刷新过于频繁，过长则可能错过节点换 IP
+const (
+	MinTTL = 5 * time.Minute
+	MaxTTL = 24 * time.Hour
+)
+
+// Entry 一个节点服务器域名的解析结果
+type Entry struct {
+	Domain   string    `json:"domain"`
+	Sources  []string  `json:"sources"` // 使用该域名的订阅 (输出目录名)
+	IPs      []string  `json:"ips,omitempty"`
+	TTL      uint32    `json:"ttl,omitempty"`
+	Resolved time.Time `json:"resolved"`
+	Expires  time.Time `json:"expires"`
+	Error    string    `json:"error,omitempty"` // 最近一次解析失败的原因，失败时保留旧结果
+}
+
+// State 预解析状态文件，记录引导 DNS、写入方式和每个域名的解析结果
+type State struct {
+	Server  string   `json:"server"`
+	Mode    string   `json:"mode"`
+	IPv6    bool     `json:"ipv6,omitempty"`
+	Entries []*Entry `json:"entries"`
+}
+
+// ValidateMode 校验写入方式
+func ValidateMode(mode string) error {
+	switch mode {
+	case ModeHosts, ModeSockopt:
+		return nil
+	}
+	return fmt.Errorf("未知的预解析写入方式: %s (可选 hosts, sockopt)", mode)
+}
+
+// LoadState 读取状态文件，文件不存在时返回空状态
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &State{Server: DefaultServer, Mode: ModeHosts}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s State
+	if err := json.Unmarshal(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), &s); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %v", path, err)
+	}
+	return &s, nil
+}
+
+// Marshal 以 4 空格缩进输出 (与 confdir 中的文件一致)
+func (s *State) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// SetSource 将来源 source 的域名替换为 domains，返回新增的条目
+// 不再被任何来源使用的域名被删除；IP 字面量不需要解析，直接忽略
+func (s *State) SetSource(source string, domains []string) []*Entry {
+	wanted := map[string]bool{}
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSuffix(d, "."))
+		if d != "" && net.ParseIP(strings.Trim(d, "[]")) == nil {
+			wanted[d] = true
+		}
+	}
+
+	var kept, added []*Entry
+	for _, e := range s.Entries {
+		e.Sources = removeString(e.Sources, source)
+		if wanted[e.Domain] {
+			e.Sources = append(e.Sources, source)
+			sort.Strings(e.Sources)
+			delete(wanted, e.Domain)
+		}
+		if len(e.Sources) > 0 {
+			kept = append(kept, e)
+		}
+	}
+	for d := range wanted {
+		e := &Entry{Domain: d, Sources: []string{source}}
+		kept = append(kept, e)
+		added = append(added, e)
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Domain < kept[j].Domain })
+	s.Entries = kept
+	return added
+}
+
+// Due 返回需要 (重新) 解析的条目: 从未解析成功或已过期
+func (s *State) Due(now time.Time, force bool) []*Entry {
+	var due []*Entry
+	for _, e := range s.Entries {
+		if force || len(e.IPs) == 0 || !now.Before(e.Expires) {
+			due = append(due, e)
+		}
+	}
+	return due
+}
+
+// NextExpiry 返回最早的过期时间 (没有条目时为零值)
+func (s *State) NextExpiry() time.Time {
+	var next time.Time
+	for _, e := range s.Entries {
+		if next.IsZero() || e.Expires.Before(next) {
+			next = e.Expires
+		}
+	}
+	return next
+}
+
+// RefreshTimeout 一次刷新的总时限，到时未完成的域名按解析失败处理 (保留旧地址)
+// service.sh 启动 Xray 前同步刷新，时限决定了最多推迟启动多久
+const RefreshTimeout = 10 * time.Second
+
+// refreshWorkers 同时进行的查询数
+const refreshWorkers = 8
+
+// Refresh 并发解析给定条目，返回 IP 发生变化的条目数和解析失败的错误
+// 解析失败 (含超过 RefreshTimeout) 时保留旧 IP，并在 MinTTL 后重试
+func Refresh(r *Resolver, entries []*Entry, now time.Time) (int, []error) {
+	type result struct {
+		answer *Answer
+		err    error
+	}
+	results := make([]chan result, len(entries))
+	sem := make(chan struct{}, refreshWorkers)
+	for i, e := range entries {
+		results[i] = make(chan result, 1)
+		go func(domain string, out chan<- result) {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			answer, err := r.Lookup(domain)
+			out <- result{answer, err}
+		}(e.Domain, results[i])
+	}
+
+	timer := time.NewTimer(RefreshTimeout)
+	defer timer.Stop()
+	expired := false
+	changed := 0
+	var errs []error
+	for i, e := range entries {
+		var res result
+		if !expired {
+			select {
+			case res = <-results[i]:
+			case <-timer.C:
+				expired = true
+			}
+		}
+		if expired {
+			select {
+			case res = <-results[i]:
+			default:
+				res.err = fmt.Errorf("查询 %s: %s 内未完成", e.Domain, RefreshTimeout)
+			}
+		}
+
+		if res.err != nil {
+			e.Error = res.err.Error()
+			e.Expires = now.Add(MinTTL)
+			errs = append(errs, res.err)
+			continue
+		}
+		if e.update(res.answer, now) {
+			changed++
+		}
+	}
+	return changed, errs
+}
+
+// update 写入解析结果，返回 IP 是否有变化
+func (e *Entry) update(answer *Answer, now time.Time) bool {
+	ips := make([]string, 0, len(answer.IPs))
+	seen := map[string]bool{}
+	for _, ip := range answer.IPs {
+		if s := ip.String(); !seen[s] {
+			seen[s] = true
+			ips = append(ips, s)
+		}
+	}
+	sort.Strings(ips)
+	changed := strings.Join(ips, ",") != strings.Join(e.IPs, ",")
+
+	ttl := time.Duration(answer.TTL) * time.Second
+	if ttl < MinTTL {
+		ttl = MinTTL
+	} else if ttl > MaxTTL {
+		ttl = MaxTTL
+	}
+	e.IPs = ips
+	e.TTL = answer.TTL
+	e.Resolved = now
+	e.Expires = now.Add(ttl)
+	e.Error = ""
+	return changed
+}
+
+// IPs 返回所有已解析的 IP (去重排序)
+func (s *State) IPs() []string {
+	seen := map[string]bool{}
+	var ips []string
+	for _, e := range s.Entries {
+		for _, ip := range e.IPs {
+			if !seen[ip] {
+				seen[ip] = true
+				ips = append(ips, ip)
+			}
+		}
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+func removeString(list []string, s string) []string {
+	var out []string
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,252 @@
+package fakedns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"proxylink/pkg/util"
+)
+
+// confdir 片段文件名: Xray 合并时 dns 整体覆盖、同 tag 入站替换，
+// 因此两个片段分别排在 02_dns.json 和 03_inbounds.json 之后
+const (
+	DNSFragment     = "02_dns_fakedns.json"
+	InboundFragment = "03_inbounds_fakedns.json"
+)
+
+// 默认地址池 (与 Xray 文档一致)
+const (
+	DefaultPoolV4   = "198.18.0.0/15"
+	DefaultPoolV6   = "fc00::/18"
+	DefaultPoolSize = 65535
+)
+
+// ExceptionTag 例外 DNS 服务器的 tag，用于识别生成的条目
+const ExceptionTag = "fakedns-direct"
+
+// DefaultInbound 需要识别 FakeDNS 地址的透明代理入站
+const DefaultInbound = "tproxy-in"
+
+// DefaultExceptionServer 02_dns.json 中没有 geosite:cn 服务器时使用的国内 DNS
+const DefaultExceptionServer = "223.5.5.5"
+
+// DefaultExceptions 保留真实 IP 的域名: 局域网/内网域名和国内域名
+var DefaultExceptions = []string{"geosite:private", "geosite:cn"}
+
+// Pool FakeDNS 地址池
+type Pool struct {
+	IPPool   string `json:"ipPool"`
+	PoolSize int    `json:"poolSize"`
+}
+
+// Options FakeDNS 片段的生成选项
+type Options struct {
+	Pools           []Pool
+	Exceptions      []string // 返回真实 IP 的域名 (Xray 域名写法)
+	ExceptionServer string   // 解析例外域名的 DNS，为空时沿用 02_dns.json 中 geosite:cn 的服务器
+	Inbound         string
+}
+
+// ValidatePool 校验地址池: 必须是网段，poolSize 不超过网段大小
+func ValidatePool(p Pool) error {
+	_, ipnet, err := net.ParseCIDR(p.IPPool)
+	if err != nil {
+		return fmt.Errorf("无效的地址池 %q", p.IPPool)
+	}
+	ones, bits := ipnet.Mask.Size()
+	if p.PoolSize <= 0 {
+		return fmt.Errorf("%s: poolSize 必须大于 0", p.IPPool)
+	}
+	if host := bits - ones; host < 31 && p.PoolSize > 1<<host {
+		return fmt.Errorf("%s: poolSize %d 超过网段大小 %d", p.IPPool, p.PoolSize, 1<<host)
+	}
+	return nil
+}
+
+// BuildDNS 在 base (02_dns.json) 的基础上生成 FakeDNS 的 dns 片段:
+// 例外域名由真实 DNS 解析 (排在最前，skipFallback)，其次是 fakedns，
+// 其余没有被域名规则命中的查询回落到 fakedns；已有按域名分流的服务器保持不变
+func BuildDNS(base []byte, opts Options) ([]byte, error) {
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(bytes.TrimPrefix(base, []byte("\xef\xbb\xbf")), &config); err != nil {
+		return nil, err
+	}
+	dns := map[string]json.RawMessage{}
+	if raw, ok := config["dns"]; ok {
+		if err := json.Unmarshal(raw, &dns); err != nil {
+			return nil, fmt.Errorf("dns: %v", err)
+		}
+	}
+	var servers []json.RawMessage
+	if raw, ok := dns["servers"]; ok {
+		if err := json.Unmarshal(raw, &servers); err != nil {
+			return nil, fmt.Errorf("dns.servers: %v", err)
+		}
+	}
+
+	server := opts.ExceptionServer
+	if server == "" {
+		server = domesticServer(servers)
+	}
+	generated := []interface{}{}
+	if len(opts.Exceptions) > 0 {
+		generated = append(generated, map[string]interface{}{
+			"address":      server,
+			"domains":      opts.Exceptions,
+			"skipFallback": true,
+			"tag":          ExceptionTag,
+		})
+	}
+	generated = append(generated, "fakedns")
+
+	var merged []interface{}
+	merged = append(merged, generated...)
+	for _, s := range servers {
+		if !isGenerated(s) {
+			merged = append(merged, s)
+		}
+	}
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	dns["servers"] = raw
+
+	return marshal(map[string]interface{}{"fakedns": opts.Pools, "dns": dns})
+}
+
+// BuildInbound 复制 base (03_inbounds.json) 中的入站，开启嗅探并在 destOverride 中加入 fakedns
+func BuildInbound(base []byte, tag string) ([]byte, error) {
+	var config struct {
+		Inbounds []json.RawMessage `json:"inbounds"`
+	}
+	if err := json.Unmarshal(bytes.TrimPrefix(base, []byte("\xef\xbb\xbf")), &config); err != nil {
+		return nil, err
+	}
+
+	for _, raw := range config.Inbounds {
+		var in map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &in); err != nil {
+			return nil, err
+		}
+		var inTag string
+		json.Unmarshal(in["tag"], &inTag)
+		if inTag != tag {
+			continue
+		}
+
+		sniffing := map[string]json.RawMessage{}
+		if raw, ok := in["sniffing"]; ok {
+			if err := json.Unmarshal(raw, &sniffing); err != nil {
+				return nil, fmt.Errorf("%s.sniffing: %v", tag, err)
+			}
+		}
+		var destOverride []string
+		if raw, ok := sniffing["destOverride"]; ok {
+			if err := json.Unmarshal(raw, &destOverride); err != nil {
+				return nil, fmt.Errorf("%s.sniffing.destOverride: %v", tag, err)
+			}
+		}
+		if !hasFakeDNS(destOverride) {
+			destOverride = append(destOverride, "fakedns")
+		}
+		sniffing["enabled"] = json.RawMessage("true")
+		sniffing["destOverride"], _ = json.Marshal(destOverride)
+		in["sniffing"] = util.OrderedObject(sniffing, in["sniffing"])
+
+		return marshal(map[string]interface{}{"inbounds": []interface{}{util.OrderedObject(in, raw)}})
+	}
+	return nil, fmt.Errorf("没有 tag 为 %s 的入站", tag)
+}
+
+// ReadOptions 从已生成的片段中读取选项，便于重新生成时沿用
+func ReadOptions(dnsFragment, inboundFragment []byte) (*Options, error) {
+	var config struct {
+		FakeDNS []Pool `json:"fakedns"`
+		DNS     struct {
+			Servers []json.RawMessage `json:"servers"`
+		} `json:"dns"`
+	}
+	if err := json.Unmarshal(bytes.TrimPrefix(dnsFragment, []byte("\xef\xbb\xbf")), &config); err != nil {
+		return nil, err
+	}
+	opts := &Options{Pools: config.FakeDNS, Inbound: DefaultInbound}
+	for _, raw := range config.DNS.Servers {
+		var s struct {
+			Address string   `json:"address"`
+			Domains []string `json:"domains"`
+			Tag     string   `json:"tag"`
+		}
+		if json.Unmarshal(raw, &s) == nil && s.Tag == ExceptionTag {
+			opts.Exceptions = s.Domains
+			opts.ExceptionServer = s.Address
+		}
+	}
+
+	var inbounds struct {
+		Inbounds []struct {
+			Tag string `json:"tag"`
+		} `json:"inbounds"`
+	}
+	if json.Unmarshal(bytes.TrimPrefix(inboundFragment, []byte("\xef\xbb\xbf")), &inbounds) == nil && len(inbounds.Inbounds) > 0 {
+		opts.Inbound = inbounds.Inbounds[0].Tag
+	}
+	return opts, nil
+}
+
+// domesticServer 返回 02_dns.json 中解析 geosite:cn 的服务器地址
+func domesticServer(servers []json.RawMessage) string {
+	for _, raw := range servers {
+		var s struct {
+			Address string   `json:"address"`
+			Domains []string `json:"domains"`
+		}
+		if json.Unmarshal(raw, &s) != nil {
+			continue
+		}
+		for _, d := range s.Domains {
+			if strings.EqualFold(d, "geosite:cn") {
+				return s.Address
+			}
+		}
+	}
+	return DefaultExceptionServer
+}
+
+// isGenerated 判断服务器条目是否为本包生成 (fakedns 或例外服务器)
+func isGenerated(raw json.RawMessage) bool {
+	var addr string
+	if json.Unmarshal(raw, &addr) == nil {
+		return addr == "fakedns"
+	}
+	var s struct {
+		Address string `json:"address"`
+		Tag     string `json:"tag"`
+	}
+	json.Unmarshal(raw, &s)
+	return s.Address == "fakedns" || s.Tag == ExceptionTag
+}
+
+func hasFakeDNS(destOverride []string) bool {
+	for _, d := range destOverride {
+		if d == "fakedns" || d == "fakedns+others" {
+			return true
+		}
+	}
+	return false
+}
+
+// marshal 以 4 空格缩进输出 (与 confdir 中的文件一致)
+func marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,204 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// DefaultBalancerTag 负载均衡器 tag，同时作为 loopback 入站 tag
+	DefaultBalancerTag = "proxy-balancer"
+	// DefaultProbeURL 默认探测地址
+	DefaultProbeURL = "https://www.gstatic.com/generate_204"
+	// DefaultProbeInterval 默认探测间隔
+	DefaultProbeInterval = "1m"
+)
+
+// BalancerOptions 负载均衡生成选项
+type BalancerOptions struct {
+	Strategy      string // leastPing/leastLoad/random/roundRobin
+	FallbackTag   string // 全部节点不可用时的出站，为空时使用第一个节点
+	ProbeURL      string // 探测地址
+	ProbeInterval string // 探测间隔
+}
+
+// XrayBalancerConfig 负载均衡 confdir 片段
+type XrayBalancerConfig struct {
+	Outbounds        []*XrayOutbound         `json:"outbounds"`
+	Routing          *RoutingConfig          `json:"routing"`
+	Observatory      *ObservatoryConfig      `json:"observatory,omitempty"`
+	BurstObservatory *BurstObservatoryConfig `json:"burstObservatory,omitempty"`
+}
+
+// RoutingConfig 路由配置，rules 保留原始 JSON 以免丢失字段
+type RoutingConfig struct {
+	DomainStrategy string            `json:"domainStrategy,omitempty"`
+	Rules          []json.RawMessage `json:"rules"`
+	Balancers      []*BalancerBean   `json:"balancers,omitempty"`
+}
+
+// BalancerBean 负载均衡器
+type BalancerBean struct {
+	Tag         string        `json:"tag"`
+	Selector    []string      `json:"selector"`
+	Strategy    *StrategyBean `json:"strategy,omitempty"`
+	FallbackTag string        `json:"fallbackTag,omitempty"`
+}
+
+// StrategyBean 负载均衡策略
+type StrategyBean struct {
+	Type string `json:"type"`
+}
+
+// ObservatoryConfig 连接观测
+type ObservatoryConfig struct {
+	SubjectSelector   []string `json:"subjectSelector"`
+	ProbeURL          string   `json:"probeUrl,omitempty"`
+	ProbeInterval     string   `json:"probeInterval,omitempty"`
+	EnableConcurrency bool     `json:"enableConcurrency"`
+}
+
+// BurstObservatoryConfig 突发连接观测 (leastLoad 使用)
+type BurstObservatoryConfig struct {
+	SubjectSelector []string        `json:"subjectSelector"`
+	PingConfig      *PingConfigBean `json:"pingConfig"`
+}
+
+// PingConfigBean burstObservatory 探测配置
+type PingConfigBean struct {
+	Destination string `json:"destination"`
+	Interval    string `json:"interval"`
+	Sampling    int    `json:"sampling"`
+	Timeout     string `json:"timeout"`
+}
+
+// routingFile routing/*.json 文件结构
+type routingFile struct {
+	Routing *RoutingConfig `json:"routing"`
+}
+
+// ParseRoutingFile 解析 confdir/routing/*.json 文件内容
+func ParseRoutingFile(data []byte) (*RoutingConfig, error) {
+	var file routingFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Routing == nil {
+		return nil, fmt.Errorf("missing routing object")
+	}
+	return file.Routing, nil
+}
+
+// GenerateBalancerConfig 生成负载均衡 confdir 片段
+// 节点出站使用各自的唯一 tag，另生成 tag 为 proxy 的 loopback 出站，
+// 将路由到 proxy 的流量转入负载均衡器，从而兼容现有 routing/*.json
+func GenerateBalancerConfig(outbounds []*XrayOutbound, base *RoutingConfig, opts BalancerOptions) (*XrayBalancerConfig, error) {
+	if len(outbounds) == 0 {
+		return nil, fmt.Errorf("balancer: no outbounds")
+	}
+
+	var tags []string
+	for _, outbound := range outbounds {
+		if outbound.Tag == DefaultTag {
+			return nil, fmt.Errorf("balancer: node tag %q is reserved for the loopback outbound", DefaultTag)
+		}
+		tags = append(tags, outbound.Tag)
+	}
+
+	strategy := opts.Strategy
+	if strategy == "" {
+		strategy = "leastPing"
+	}
+	switch strategy {
+	case "leastPing", "leastLoad", "random", "roundRobin":
+	default:
+		return nil, fmt.Errorf("balancer: unknown strategy %q", strategy)
+	}
+
+	fallback := opts.FallbackTag
+	if fallback == "" {
+		fallback = tags[0]
+	} else if !containsString(tags, fallback) && !containsString(BuiltinOutboundTags, fallback) {
+		return nil, fmt.Errorf("balancer: fallback tag %q is not a generated or builtin outbound", fallback)
+	}
+
+	probeURL := opts.ProbeURL
+	if probeURL == "" {
+		probeURL = DefaultProbeURL
+	}
+	probeInterval := opts.ProbeInterval
+	if probeInterval == "" {
+		probeInterval = DefaultProbeInterval
+	}
+
+	// loopback 出站: proxy -> 负载均衡器
+	loopback := &XrayOutbound{
+		Protocol: "loopback",
+		Settings: &OutSettings{InboundTag: DefaultBalancerTag},
+		Tag:      DefaultTag,
+	}
+
+	balancerRule, _ := json.Marshal(map[string]interface{}{
+		"type":        "field",
+		"inboundTag":  []string{DefaultBalancerTag},
+		"balancerTag": DefaultBalancerTag,
+	})
+
+	routing := &RoutingConfig{
+		DomainStrategy: "AsIs",
+		Rules:          []json.RawMessage{balancerRule},
+	}
+	if base != nil {
+		if base.DomainStrategy != "" {
+			routing.DomainStrategy = base.DomainStrategy
+		}
+		routing.Rules = append(routing.Rules, base.Rules...)
+		for _, b := range base.Balancers {
+			if b.Tag != DefaultBalancerTag {
+				routing.Balancers = append(routing.Balancers, b)
+			}
+		}
+	}
+	routing.Balancers = append(routing.Balancers, &BalancerBean{
+		Tag:         DefaultBalancerTag,
+		Selector:    tags,
+		Strategy:    &StrategyBean{Type: strategy},
+		FallbackTag: fallback,
+	})
+
+	config := &XrayBalancerConfig{
+		Outbounds: append(append([]*XrayOutbound{}, outbounds...), loopback),
+		Routing:   routing,
+	}
+
+	// leastLoad 依赖 burstObservatory，其余策略使用 observatory
+	if strategy == "leastLoad" {
+		config.BurstObservatory = &BurstObservatoryConfig{
+			SubjectSelector: tags,
+			PingConfig: &PingConfigBean{
+				Destination: probeURL,
+				Interval:    probeInterval,
+				Sampling:    2,
+				Timeout:     "5s",
+			},
+		}
+	} else {
+		config.Observatory = &ObservatoryConfig{
+			SubjectSelector:   tags,
+			ProbeURL:          probeURL,
+			ProbeInterval:     probeInterval,
+			EnableConcurrency: true,
+		}
+	}
+
+	return config, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
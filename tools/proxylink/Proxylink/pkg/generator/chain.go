@@ -0,0 +1,99 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+
+	"proxylink/pkg/model"
+)
+
+// ChainTagPrefix 链式代理中间节点 tag 前缀
+const ChainTagPrefix = "chain-"
+
+// GenerateChainConfig 生成链式代理配置
+// profiles 按 前置 → … → 落地 顺序排列，每一跳通过 sockopt.dialerProxy
+// 经由上一跳拨号，落地节点 tag 为 proxy 以兼容热切换
+func GenerateChainConfig(profiles []*model.ProfileItem) (*XrayConfig, error) {
+	if len(profiles) < 2 {
+		return nil, fmt.Errorf("chain: need at least 2 hops, got %d", len(profiles))
+	}
+
+	seen := make(map[string]int)
+	var outbounds []*XrayOutbound
+
+	for i, p := range profiles {
+		hop := i + 1
+
+		// 检测环路: 同一节点出现两次
+		id := ProfileID(p)
+		if prev, ok := seen[id]; ok {
+			return nil, fmt.Errorf("chain: hop %d (%s) repeats hop %d, chain would loop", hop, p.Remarks, prev)
+		}
+		seen[id] = hop
+
+		if err := checkChainable(p); err != nil {
+			return nil, fmt.Errorf("chain: hop %d (%s): %v", hop, p.Remarks, err)
+		}
+		if i > 0 && needsUDP(p) && !carriesUDP(profiles[i-1]) {
+			return nil, fmt.Errorf("chain: hop %d (%s) needs UDP but hop %d (%s) is %s and cannot relay UDP",
+				hop, p.Remarks, i, profiles[i-1].Remarks, profiles[i-1].ConfigType)
+		}
+
+		outbound, err := GenerateXrayOutbound(p)
+		if err != nil {
+			return nil, fmt.Errorf("chain: hop %d (%s): %v", hop, p.Remarks, err)
+		}
+
+		if hop == len(profiles) {
+			outbound.Tag = DefaultTag
+		} else {
+			outbound.Tag = ChainTagPrefix + strconv.Itoa(hop)
+		}
+		if i > 0 {
+			setDialerProxy(outbound, outbounds[i-1].Tag)
+		}
+		outbounds = append(outbounds, outbound)
+	}
+
+	return &XrayConfig{Outbounds: outbounds}, nil
+}
+
+// checkChainable 检查协议能否作为链式代理的一跳
+func checkChainable(p *model.ProfileItem) error {
+	switch p.ConfigType {
+	case model.HYSTERIA2:
+		return fmt.Errorf("hysteria2 runs as a separate client and cannot be dialed through dialerProxy")
+	case model.VLESS, model.VMESS, model.SHADOWSOCKS, model.TROJAN, model.SOCKS, model.HTTP, model.WIREGUARD:
+		return nil
+	default:
+		return fmt.Errorf("protocol %s cannot be chained", p.ConfigType)
+	}
+}
+
+// needsUDP 判断节点的底层连接是否为 UDP
+func needsUDP(p *model.ProfileItem) bool {
+	if p.ConfigType == model.WIREGUARD {
+		return true
+	}
+	switch p.Network {
+	case "kcp", "quic":
+		return true
+	}
+	return false
+}
+
+// carriesUDP 判断节点能否转发 UDP
+func carriesUDP(p *model.ProfileItem) bool {
+	return p.ConfigType != model.HTTP
+}
+
+// setDialerProxy 设置出站经由指定 tag 拨号
+func setDialerProxy(outbound *XrayOutbound, tag string) {
+	if outbound.StreamSettings == nil {
+		outbound.StreamSettings = &StreamSettings{}
+	}
+	if outbound.StreamSettings.Sockopt == nil {
+		outbound.StreamSettings.Sockopt = &SockoptBean{}
+	}
+	outbound.StreamSettings.Sockopt.DialerProxy = tag
+}
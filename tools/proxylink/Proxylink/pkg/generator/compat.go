@@ -0,0 +1,125 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"proxylink/pkg/model"
+)
+
+// XrayTarget 目标 Xray 内核版本 (-target-xray)
+type XrayTarget struct {
+	Major int
+	Minor int
+}
+
+// 兼容性处理动作
+const (
+	CompatRewrite = "rewrite" // 改写为等价配置
+	CompatWarn    = "warn"    // 保留节点并给出警告
+	CompatReject  = "reject"  // 目标内核无法运行，丢弃节点
+)
+
+// CompatDecision 单条兼容性处理结果
+type CompatDecision struct {
+	Rule    string `json:"rule"`
+	Action  string `json:"action"`
+	Message string `json:"message"`
+}
+
+// 各特性的版本边界
+var (
+	xrayQuicRemoved        = XrayTarget{24, 9}  // QUIC 传输移除
+	xrayXhttpAdded         = XrayTarget{24, 11} // splithttp 更名为 XHTTP
+	xrayH2Removed          = XrayTarget{24, 12} // HTTP/2 传输移除，由 XHTTP 取代
+	xrayInsecureDeprecated = XrayTarget{25, 0}  // allowInsecure 计划移除
+	xrayVLessEncryption    = XrayTarget{25, 9}  // VLESS 后量子加密
+)
+
+// ParseXrayTarget 解析版本号，支持 v25.3 / 25.3.6 / 1.8
+func ParseXrayTarget(s string) (*XrayTarget, error) {
+	v := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if v == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(v, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("target-xray: want vMAJOR.MINOR, got %q", s)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("target-xray: invalid version %q", s)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("target-xray: invalid version %q", s)
+	}
+	return &XrayTarget{Major: major, Minor: minor}, nil
+}
+
+// String 返回 vMAJOR.MINOR 形式
+func (t XrayTarget) String() string {
+	return fmt.Sprintf("v%d.%d", t.Major, t.Minor)
+}
+
+// AtLeast 判断目标版本是否不低于 o
+func (t XrayTarget) AtLeast(o XrayTarget) bool {
+	if t.Major != o.Major {
+		return t.Major > o.Major
+	}
+	return t.Minor >= o.Minor
+}
+
+// ApplyXrayTarget 按目标内核调整节点，返回处理记录
+// 出现 reject 时节点不可用，调用方应丢弃该节点
+func ApplyXrayTarget(p *model.ProfileItem, t XrayTarget) []CompatDecision {
+	var decisions []CompatDecision
+	add := func(rule, action, format string, args ...interface{}) {
+		decisions = append(decisions, CompatDecision{Rule: rule, Action: action, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch p.Network {
+	case "quic":
+		if t.AtLeast(xrayQuicRemoved) {
+			add("quic", CompatReject, "QUIC 传输已在 %s 移除，且没有等价替代", xrayQuicRemoved)
+		}
+	case "h2", "http":
+		if t.AtLeast(xrayH2Removed) {
+			p.Network = "xhttp"
+			p.XhttpMode = "stream-one"
+			p.HeaderType = ""
+			add("h2", CompatRewrite, "HTTP/2 传输已在 %s 移除，改写为 xhttp (stream-one)，服务端需同时支持 XHTTP", xrayH2Removed)
+		}
+	case "xhttp":
+		if !t.AtLeast(xrayXhttpAdded) {
+			p.Network = "splithttp"
+			add("xhttp", CompatRewrite, "%s 之前 XHTTP 名为 splithttp", xrayXhttpAdded)
+		}
+	}
+
+	if p.ConfigType == model.VMESS && p.AlterId > 0 {
+		add("vmess-alterid", CompatReject, "VMess alterId=%d (MD5 认证) 已被禁用，仅支持 alterId=0 (AEAD)", p.AlterId)
+	}
+
+	if p.Insecure && p.Security == "tls" && p.PinnedPeerCertSha256 == "" && t.AtLeast(xrayInsecureDeprecated) {
+		add("allow-insecure", CompatWarn, "allowInsecure 已弃用，建议改用证书固定 (pcs)")
+	}
+
+	if p.ConfigType == model.VLESS && p.VLessEncryption != nil && !t.AtLeast(xrayVLessEncryption) {
+		add("vless-encryption", CompatReject, "VLESS encryption 需要 %s 及以上", xrayVLessEncryption)
+	}
+
+	return decisions
+}
+
+// CompatRejected 判断处理记录中是否有 reject
+func CompatRejected(decisions []CompatDecision) bool {
+	for _, d := range decisions {
+		if d.Action == CompatReject {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"proxylink/pkg/model"
+)
+
+// LoadMux 从 JSON 文件读取 mux 设置 (格式同 Xray mux)
+// 文件可以是 mux 对象本身，也可以是包含 "mux" 字段的订阅 _meta.json
+func LoadMux(filename string) (*MuxBean, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var wrapper struct {
+		Mux *MuxBean `json:"mux"`
+	}
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return nil, fmt.Errorf("mux: %s: %v", filename, err)
+	}
+	if wrapper.Mux != nil {
+		return wrapper.Mux, nil
+	}
+
+	var mux MuxBean
+	if err := json.Unmarshal(data, &mux); err != nil {
+		return nil, fmt.Errorf("mux: %s: %v", filename, err)
+	}
+	return &mux, nil
+}
+
+// ValidateMux 校验 mux 取值范围
+func ValidateMux(m *MuxBean) error {
+	if m == nil {
+		return nil
+	}
+	if m.Concurrency != -1 && (m.Concurrency < 0 || m.Concurrency > 1024) {
+		return fmt.Errorf("mux: concurrency must be -1 or 0-1024, got %d", m.Concurrency)
+	}
+	if m.XudpConcurrency != -1 && (m.XudpConcurrency < 0 || m.XudpConcurrency > 1024) {
+		return fmt.Errorf("mux: xudpConcurrency must be -1 or 0-1024, got %d", m.XudpConcurrency)
+	}
+	switch m.XudpProxyUDP443 {
+	case "", "reject", "allow", "skip":
+	default:
+		return fmt.Errorf("mux: xudpProxyUDP443 must be reject, allow or skip, got %q", m.XudpProxyUDP443)
+	}
+	return nil
+}
+
+// ApplyMux 将 mux 设置应用到出站，返回因 Xray 限制而做出的调整说明
+// - WireGuard/loopback 不支持 mux，跳过
+// - VLESS xtls-rprx-vision 禁止 TCP 多路复用，仅保留 XUDP (concurrency = -1)
+func ApplyMux(outbound *XrayOutbound, p *model.ProfileItem, m *MuxBean) string {
+	if outbound == nil || m == nil || !m.Enabled {
+		return ""
+	}
+
+	switch outbound.Protocol {
+	case "wireguard", "loopback":
+		return ""
+	}
+
+	mux := *m
+
+	if p != nil && p.ConfigType == model.VLESS && strings.HasPrefix(p.Flow, "xtls-rprx-vision") {
+		if mux.XudpConcurrency <= 0 {
+			outbound.Mux = &MuxBean{Enabled: false, Concurrency: -1}
+			return fmt.Sprintf("%s: flow %s 不支持 mux，已禁用", p.Remarks, p.Flow)
+		}
+		mux.Concurrency = -1
+		outbound.Mux = &mux
+		return fmt.Sprintf("%s: flow %s 不支持 TCP mux，仅启用 XUDP", p.Remarks, p.Flow)
+	}
+
+	outbound.Mux = &mux
+	return ""
+}
@@ -0,0 +1,127 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+)
+
+// validDomainStrategies sockopt.domainStrategy 可选值
+var validDomainStrategies = map[string]bool{
+	"AsIs":        true,
+	"UseIP":       true,
+	"UseIPv4":     true,
+	"UseIPv6":     true,
+	"UseIPv4v6":   true,
+	"UseIPv6v4":   true,
+	"ForceIP":     true,
+	"ForceIPv4":   true,
+	"ForceIPv6":   true,
+	"ForceIPv4v6": true,
+	"ForceIPv6v4": true,
+}
+
+// LoadSockopt 从 JSON 文件读取默认 sockopt (格式同 Xray streamSettings.sockopt)
+func LoadSockopt(filename string) (*SockoptBean, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var sockopt SockoptBean
+	if err := json.Unmarshal(data, &sockopt); err != nil {
+		return nil, fmt.Errorf("sockopt: %s: %v", filename, err)
+	}
+	return &sockopt, nil
+}
+
+// ValidateSockopt 校验 sockopt 取值
+func ValidateSockopt(s *SockoptBean) error {
+	if s == nil {
+		return nil
+	}
+	if s.Mark < 0 {
+		return fmt.Errorf("sockopt: mark must be >= 0, got %d", s.Mark)
+	}
+	if s.TcpKeepAliveInterval < 0 {
+		return fmt.Errorf("sockopt: tcpKeepAliveInterval must be >= 0, got %d", s.TcpKeepAliveInterval)
+	}
+	if s.DomainStrategy != "" && !validDomainStrategies[s.DomainStrategy] {
+		return fmt.Errorf("sockopt: unknown domainStrategy %q", s.DomainStrategy)
+	}
+	return nil
+}
+
+// IsEmpty 判断 sockopt 是否未设置任何字段
+func (s *SockoptBean) IsEmpty() bool {
+	return s == nil || *s == SockoptBean{}
+}
+
+// ApplySockopt 将 sockopt 合并到出站，已有字段 (如链式代理的 dialerProxy) 不会被覆盖
+// loopback 出站和连接本机的出站 (如 Hysteria2 的本地 SOCKS 桥接) 不经过外部网卡，跳过;
+// 对它们绑定网卡或打标记会使本机连接失败
+func ApplySockopt(outbound *XrayOutbound, s *SockoptBean) {
+	if outbound == nil || s.IsEmpty() || outbound.Protocol == "loopback" || isLocalOutbound(outbound) {
+		return
+	}
+
+	if outbound.StreamSettings == nil {
+		outbound.StreamSettings = &StreamSettings{}
+	}
+	if outbound.StreamSettings.Sockopt == nil {
+		outbound.StreamSettings.Sockopt = &SockoptBean{}
+	}
+	dst := outbound.StreamSettings.Sockopt
+
+	if dst.Mark == 0 {
+		dst.Mark = s.Mark
+	}
+	if dst.Interface == "" {
+		dst.Interface = s.Interface
+	}
+	if !dst.TcpFastOpen {
+		dst.TcpFastOpen = s.TcpFastOpen
+	}
+	if dst.TcpKeepAliveInterval == 0 {
+		dst.TcpKeepAliveInterval = s.TcpKeepAliveInterval
+	}
+	if dst.DomainStrategy == "" {
+		dst.DomainStrategy = s.DomainStrategy
+	}
+	if dst.DialerProxy == "" {
+		dst.DialerProxy = s.DialerProxy
+	}
+}
+
+// isLocalOutbound 判断出站的服务器是否全部为本机地址
+func isLocalOutbound(outbound *XrayOutbound) bool {
+	if outbound.Settings == nil {
+		return false
+	}
+	var addrs []string
+	for _, v := range outbound.Settings.Vnext {
+		addrs = append(addrs, v.Address)
+	}
+	for _, s := range outbound.Settings.Servers {
+		addrs = append(addrs, s.Address)
+	}
+	for _, p := range outbound.Settings.Peers {
+		host, _, err := net.SplitHostPort(p.Endpoint)
+		if err != nil {
+			host = p.Endpoint
+		}
+		addrs = append(addrs, host)
+	}
+	if len(addrs) == 0 {
+		return false
+	}
+	for _, addr := range addrs {
+		if addr == "localhost" {
+			continue
+		}
+		if ip := net.ParseIP(addr); ip == nil || !ip.IsLoopback() {
+			return false
+		}
+	}
+	return true
+}
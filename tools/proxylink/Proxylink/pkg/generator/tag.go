@@ -0,0 +1,114 @@
+package generator
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"proxylink/pkg/model"
+)
+
+const (
+	// DefaultTag 单节点出站 tag，switch-config.sh 热切换依赖此名称
+	DefaultTag = "proxy"
+	// DefaultTagTemplate 多节点出站默认 tag 模板
+	DefaultTagTemplate = "{sub}-{index}"
+)
+
+// BuiltinOutboundTags confdir/04_outbounds.json 中的内置出站 tag
+var BuiltinOutboundTags = []string{"direct", "block", "api", "dns-out"}
+
+// TagAllocator 多节点出站 tag 分配器，保证 tag 唯一
+// 模板占位符:
+//
+//	{sub}     订阅 ID (为空时为 proxy)
+//	{index}   节点序号 (从 1 开始)
+//	{id}      由协议/地址/凭据计算的稳定短 ID
+//	{remarks} 清理后的节点备注
+type TagAllocator struct {
+	template string
+	used     map[string]bool
+}
+
+// NewTagAllocator 创建 tag 分配器，模板为空时使用默认模板
+func NewTagAllocator(template string) *TagAllocator {
+	if template == "" {
+		template = DefaultTagTemplate
+	}
+	a := &TagAllocator{
+		template: template,
+		used:     make(map[string]bool),
+	}
+	a.Reserve(DefaultTag)
+	a.Reserve(BuiltinOutboundTags...)
+	return a
+}
+
+// Reserve 预留 tag，避免与已有出站冲突
+func (a *TagAllocator) Reserve(tags ...string) {
+	for _, tag := range tags {
+		a.used[tag] = true
+	}
+}
+
+// Next 为节点分配唯一 tag，index 从 1 开始
+func (a *TagAllocator) Next(p *model.ProfileItem, index int) string {
+	tag := RenderTag(a.template, p, index)
+	if tag == "" {
+		tag = DefaultTag + "-" + strconv.Itoa(index)
+	}
+
+	unique := tag
+	for n := 2; a.used[unique]; n++ {
+		unique = tag + "-" + strconv.Itoa(n)
+	}
+	a.used[unique] = true
+	return unique
+}
+
+// RenderTag 按模板渲染 tag
+func RenderTag(template string, p *model.ProfileItem, index int) string {
+	sub := sanitizeTag(p.SubscriptionID)
+	if sub == "" {
+		sub = DefaultTag
+	}
+
+	replacer := strings.NewReplacer(
+		"{sub}", sub,
+		"{index}", strconv.Itoa(index),
+		"{id}", ProfileID(p),
+		"{remarks}", sanitizeTag(p.Remarks),
+	)
+	return strings.Trim(replacer.Replace(template), "-_.")
+}
+
+// ProfileID 计算节点的稳定短 ID (8 位十六进制)
+func ProfileID(p *model.ProfileItem) string {
+	sum := sha1.Sum([]byte(strings.Join([]string{
+		p.ConfigType.String(),
+		p.Server,
+		p.ServerPort,
+		p.Password,
+		p.Username,
+		p.SecretKey,
+	}, "|")))
+	return hex.EncodeToString(sum[:4])
+}
+
+// sanitizeTag 清理 tag 中的字符，仅保留字母、数字、'-'、'_' 和 '.'
+func sanitizeTag(s string) string {
+	var sb strings.Builder
+	lastUnderscore := false
+	for _, r := range strings.TrimSpace(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '.' {
+			sb.WriteRune(r)
+			lastUnderscore = false
+		} else if !lastUnderscore {
+			sb.WriteRune('_')
+			lastUnderscore = true
+		}
+	}
+	return strings.Trim(sb.String(), "_")
+}
@@ -0,0 +1,86 @@
+package generator
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"proxylink/pkg/model"
+	"proxylink/pkg/util"
+)
+
+// GenerateWireGuardConf 生成 wg-quick 格式的 WireGuard 配置文件
+func GenerateWireGuardConf(p *model.ProfileItem) (string, error) {
+	if p.ConfigType != model.WIREGUARD {
+		return "", errors.New("wgconf: only wireguard profiles can be exported")
+	}
+	if p.SecretKey == "" {
+		return "", errors.New("wgconf: missing private key")
+	}
+
+	reserved, err := util.ParseReserved(p.Reserved)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+
+	// [Interface]
+	sb.WriteString("[Interface]\n")
+	writeConfLine(&sb, "PrivateKey", p.SecretKey)
+	writeConfLine(&sb, "Address", joinList(p.LocalAddress))
+	writeConfLine(&sb, "DNS", joinList(p.DNS))
+	if p.MTU > 0 {
+		writeConfLine(&sb, "MTU", strconv.Itoa(p.MTU))
+	}
+	// 非 wg-quick 标准字段，WARP 客户端需要
+	if len(reserved) == 3 && (reserved[0] != 0 || reserved[1] != 0 || reserved[2] != 0) {
+		writeConfLine(&sb, "Reserved", strconv.Itoa(reserved[0])+", "+strconv.Itoa(reserved[1])+", "+strconv.Itoa(reserved[2]))
+	}
+
+	// [Peer]
+	peers := p.Peers
+	if len(peers) == 0 {
+		peers = []model.WireGuardPeer{{
+			PublicKey:    p.PublicKey,
+			PreSharedKey: p.PreSharedKey,
+			Endpoint:     net.JoinHostPort(p.Server, p.ServerPort),
+		}}
+	}
+
+	for _, peer := range peers {
+		if peer.PublicKey == "" {
+			return "", errors.New("wgconf: missing peer public key")
+		}
+
+		allowedIPs := joinList(peer.AllowedIPs)
+		if allowedIPs == "" {
+			allowedIPs = "0.0.0.0/0, ::/0"
+		}
+
+		sb.WriteString("\n[Peer]\n")
+		writeConfLine(&sb, "PublicKey", peer.PublicKey)
+		writeConfLine(&sb, "PresharedKey", peer.PreSharedKey)
+		writeConfLine(&sb, "AllowedIPs", allowedIPs)
+		writeConfLine(&sb, "Endpoint", peer.Endpoint)
+		if peer.KeepAlive > 0 {
+			writeConfLine(&sb, "PersistentKeepalive", strconv.Itoa(peer.KeepAlive))
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// writeConfLine 写入 key = value 行，空值跳过
+func writeConfLine(sb *strings.Builder, key, value string) {
+	if value == "" {
+		return
+	}
+	sb.WriteString(key + " = " + value + "\n")
+}
+
+// joinList 将逗号分隔的列表规范化为 "a, b" 格式
+func joinList(s string) string {
+	return strings.Join(splitAndTrim(s, ","), ", ")
+}
@@ -1,11 +1,16 @@
 package generator
 
 import (
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
 	"strconv"
 	"strings"
 
 	"proxylink/pkg/model"
+	"proxylink/pkg/util"
 )
 
 const DEFAULT_LEVEL = 8
@@ -34,6 +39,8 @@ type OutSettings struct {
 	Peers     []WireGuardPeer `json:"peers,omitempty"`
 	Reserved  []int           `json:"reserved,omitempty"`
 	Mtu       int             `json:"mtu,omitempty"`
+	// Loopback
+	InboundTag string `json:"inboundTag,omitempty"`
 }
 
 type VnextBean struct {
@@ -68,9 +75,11 @@ type SocksUsersBean struct {
 }
 
 type WireGuardPeer struct {
-	PublicKey    string `json:"publicKey"`
-	PreSharedKey string `json:"preSharedKey,omitempty"`
-	Endpoint     string `json:"endpoint"`
+	PublicKey    string   `json:"publicKey"`
+	PreSharedKey string   `json:"preSharedKey,omitempty"`
+	Endpoint     string   `json:"endpoint"`
+	KeepAlive    int      `json:"keepAlive,omitempty"`
+	AllowedIPs   []string `json:"allowedIPs,omitempty"`
 }
 
 // StreamSettings 传输层配置
@@ -82,10 +91,12 @@ type StreamSettings struct {
 	WsSettings          *WsSettingsBean          `json:"wsSettings,omitempty"`
 	HttpupgradeSettings *HttpupgradeSettingsBean `json:"httpupgradeSettings,omitempty"`
 	XhttpSettings       *XhttpSettingsBean       `json:"xhttpSettings,omitempty"`
+	SplithttpSettings   *XhttpSettingsBean       `json:"splithttpSettings,omitempty"`
 	HttpSettings        *HttpSettingsBean        `json:"httpSettings,omitempty"`
 	TlsSettings         *TlsSettingsBean         `json:"tlsSettings,omitempty"`
 	RealitySettings     *TlsSettingsBean         `json:"realitySettings,omitempty"`
 	GrpcSettings        *GrpcSettingsBean        `json:"grpcSettings,omitempty"`
+	Sockopt             *SockoptBean             `json:"sockopt,omitempty"`
 }
 
 type TcpSettingsBean struct {
@@ -138,10 +149,10 @@ type HttpupgradeSettingsBean struct {
 }
 
 type XhttpSettingsBean struct {
-	Host  string      `json:"host"`
-	Mode  string      `json:"mode"`
-	Path  string      `json:"path"`
-	Extra interface{} `json:"extra,omitempty"`
+	Host  string            `json:"host"`
+	Mode  string            `json:"mode"`
+	Path  string            `json:"path"`
+	Extra *model.XhttpExtra `json:"extra,omitempty"`
 }
 
 type HttpSettingsBean struct {
@@ -150,7 +161,7 @@ type HttpSettingsBean struct {
 }
 
 type TlsSettingsBean struct {
-	AllowInsecure bool     `json:"allowInsecure"`
+	AllowInsecure bool     `json:"allowInsecure,omitempty"`
 	Fingerprint   string   `json:"fingerprint,omitempty"`
 	PublicKey     string   `json:"publicKey,omitempty"`
 	ServerName    string   `json:"serverName,omitempty"`
@@ -159,6 +170,13 @@ type TlsSettingsBean struct {
 	SpiderX       string   `json:"spiderX,omitempty"`
 	Alpn          []string `json:"alpn,omitempty"`
 	Mldsa65Verify string   `json:"mldsa65Verify,omitempty"`
+
+	EchConfigList                    string   `json:"echConfigList,omitempty"`
+	PinnedPeerCertificateChainSha256 []string `json:"pinnedPeerCertificateChainSha256,omitempty"`
+	VerifyPeerCertByName             string   `json:"verifyPeerCertByName,omitempty"`
+	MinVersion                       string   `json:"minVersion,omitempty"`
+	MaxVersion                       string   `json:"maxVersion,omitempty"`
+	DisableSystemRoot                bool     `json:"disableSystemRoot,omitempty"`
 }
 
 type GrpcSettingsBean struct {
@@ -169,49 +187,72 @@ type GrpcSettingsBean struct {
 	HealthCheckTimeout int    `json:"health_check_timeout,omitempty"`
 }
 
+type SockoptBean struct {
+	Mark                 int    `json:"mark,omitempty"`
+	Interface            string `json:"interface,omitempty"`
+	TcpFastOpen          bool   `json:"tcpFastOpen,omitempty"`
+	TcpKeepAliveInterval int    `json:"tcpKeepAliveInterval,omitempty"`
+	DomainStrategy       string `json:"domainStrategy,omitempty"`
+	DialerProxy          string `json:"dialerProxy,omitempty"`
+}
+
 type MuxBean struct {
-	Enabled     bool `json:"enabled"`
-	Concurrency int  `json:"concurrency,omitempty"`
+	Enabled         bool   `json:"enabled"`
+	Concurrency     int    `json:"concurrency,omitempty"`
+	XudpConcurrency int    `json:"xudpConcurrency,omitempty"`
+	XudpProxyUDP443 string `json:"xudpProxyUDP443,omitempty"`
 }
 
 // GenerateXrayOutbound 生成 Xray 出站配置
-func GenerateXrayOutbound(profile *model.ProfileItem) *XrayOutbound {
+func GenerateXrayOutbound(profile *model.ProfileItem) (*XrayOutbound, error) {
+	if profile.Network == "xhttp" || profile.Network == "splithttp" {
+		if _, err := model.ParseXhttpExtra(profile.XhttpExtra); err != nil {
+			return nil, err
+		}
+	}
+
 	switch profile.ConfigType {
 	case model.VLESS:
-		return generateVLessOutbound(profile)
+		return generateVLessOutbound(profile), nil
 	case model.VMESS:
-		return generateVMessOutbound(profile)
+		return generateVMessOutbound(profile), nil
 	case model.SHADOWSOCKS:
-		return generateShadowsocksOutbound(profile)
+		return generateShadowsocksOutbound(profile), nil
 	case model.TROJAN:
-		return generateTrojanOutbound(profile)
+		return generateTrojanOutbound(profile), nil
 	case model.SOCKS:
-		return generateSocksOutbound(profile.Server, profile.ServerPort, profile.Username, profile.Password)
+		return generateSocksOutbound(profile.Server, profile.ServerPort, profile.Username, profile.Password), nil
 	case model.HTTP:
-		return generateHTTPOutbound(profile)
+		return generateHTTPOutbound(profile), nil
 	case model.WIREGUARD:
 		return generateWireGuardOutbound(profile)
 	case model.HYSTERIA2:
-		return generateSocksOutbound("127.0.0.1", "1234", "", "")
+		return generateSocksOutbound("127.0.0.1", "1234", "", ""), nil
 	default:
-		return nil
+		return nil, fmt.Errorf("unsupported config type: %s", profile.ConfigType)
 	}
 }
 
 // GenerateXrayConfig 生成带 outbounds 包装的完整 Xray 配置
-func GenerateXrayConfig(profile *model.ProfileItem) *XrayConfig {
-	outbound := GenerateXrayOutbound(profile)
-	if outbound == nil {
-		return nil
+func GenerateXrayConfig(profile *model.ProfileItem) (*XrayConfig, error) {
+	outbound, err := GenerateXrayOutbound(profile)
+	if err != nil {
+		return nil, err
 	}
 	return &XrayConfig{
 		Outbounds: []*XrayOutbound{outbound},
-	}
+	}, nil
 }
 
 func generateVLessOutbound(p *model.ProfileItem) *XrayOutbound {
 	port, _ := strconv.Atoi(p.ServerPort)
 
+	// encryption 属于 users 级别，VLESS 必须显式声明
+	encryption := p.Method
+	if encryption == "" {
+		encryption = "none"
+	}
+
 	return &XrayOutbound{
 		Mux:      &MuxBean{Enabled: false, Concurrency: -1},
 		Protocol: "vless",
@@ -221,7 +262,7 @@ func generateVLessOutbound(p *model.ProfileItem) *XrayOutbound {
 				Port:    port,
 				Users: []UsersBean{{
 					ID:         p.Password,
-					Encryption: p.Method,
+					Encryption: encryption,
 					Flow:       &p.Flow,
 					Level:      DEFAULT_LEVEL,
 				}},
@@ -349,7 +390,7 @@ func generateHTTPOutbound(p *model.ProfileItem) *XrayOutbound {
 		}}
 	}
 
-	return &XrayOutbound{
+	outbound := &XrayOutbound{
 		Mux:      &MuxBean{Enabled: false, Concurrency: -1},
 		Protocol: "http",
 		Settings: &OutSettings{
@@ -357,19 +398,26 @@ func generateHTTPOutbound(p *model.ProfileItem) *XrayOutbound {
 		},
 		Tag: "proxy",
 	}
+
+	// HTTPS 代理
+	if p.Security == "tls" {
+		ss := &StreamSettings{
+			Network:  "tcp",
+			Security: "tls",
+		}
+		populateTlsSettings(ss, p, "")
+		outbound.StreamSettings = ss
+	}
+
+	return outbound
 }
 
-func generateWireGuardOutbound(p *model.ProfileItem) *XrayOutbound {
+func generateWireGuardOutbound(p *model.ProfileItem) (*XrayOutbound, error) {
 	port, _ := strconv.Atoi(p.ServerPort)
 
-	var reserved []int
-	if p.Reserved != "" {
-		for _, s := range strings.Split(p.Reserved, ",") {
-			s = strings.TrimSpace(s)
-			if v, err := strconv.Atoi(s); err == nil {
-				reserved = append(reserved, v)
-			}
-		}
+	reserved, err := util.ParseReserved(p.Reserved)
+	if err != nil {
+		return nil, err
 	}
 
 	var address []string
@@ -381,23 +429,36 @@ func generateWireGuardOutbound(p *model.ProfileItem) *XrayOutbound {
 		address = []string{"10.0.0.2/32"}
 	}
 
-	endpoint := p.Server + ":" + strconv.Itoa(port)
+	var peers []WireGuardPeer
+	if len(p.Peers) > 0 {
+		for _, peer := range p.Peers {
+			peers = append(peers, WireGuardPeer{
+				PublicKey:    peer.PublicKey,
+				PreSharedKey: peer.PreSharedKey,
+				Endpoint:     peer.Endpoint,
+				KeepAlive:    peer.KeepAlive,
+				AllowedIPs:   splitAndTrim(peer.AllowedIPs, ","),
+			})
+		}
+	} else {
+		peers = []WireGuardPeer{{
+			PublicKey:    p.PublicKey,
+			PreSharedKey: p.PreSharedKey,
+			Endpoint:     net.JoinHostPort(p.Server, strconv.Itoa(port)),
+		}}
+	}
 
 	return &XrayOutbound{
 		Protocol: "wireguard",
 		Settings: &OutSettings{
 			SecretKey: p.SecretKey,
 			Address:   address,
-			Peers: []WireGuardPeer{{
-				PublicKey:    p.PublicKey,
-				PreSharedKey: p.PreSharedKey,
-				Endpoint:     endpoint,
-			}},
-			Reserved: reserved,
-			Mtu:      p.MTU,
+			Peers:     peers,
+			Reserved:  reserved,
+			Mtu:       p.MTU,
 		},
 		Tag: "proxy",
-	}
+	}, nil
 }
 
 func buildStreamSettings(p *model.ProfileItem) *StreamSettings {
@@ -500,7 +561,7 @@ func populateTransportSettings(ss *StreamSettings, p *model.ProfileItem) string
 		sni = p.Host
 		ss.HttpupgradeSettings = httpupgradeSetting
 
-	case "xhttp":
+	case "xhttp", "splithttp":
 		xhttpSetting := &XhttpSettingsBean{
 			Host: p.Host,
 			Path: p.Path,
@@ -512,15 +573,14 @@ func populateTransportSettings(ss *StreamSettings, p *model.ProfileItem) string
 		if xhttpSetting.Mode == "" {
 			xhttpSetting.Mode = "auto"
 		}
-		// 解析 extra
-		if p.XhttpExtra != "" {
-			var extra interface{}
-			if err := json.Unmarshal([]byte(p.XhttpExtra), &extra); err == nil {
-				xhttpSetting.Extra = extra
-			}
-		}
+		// extra 已在 GenerateXrayOutbound 中校验
+		xhttpSetting.Extra, _ = model.ParseXhttpExtra(p.XhttpExtra)
 		sni = p.Host
-		ss.XhttpSettings = xhttpSetting
+		if ss.Network == "splithttp" {
+			ss.SplithttpSettings = xhttpSetting
+		} else {
+			ss.XhttpSettings = xhttpSetting
+		}
 
 	case "h2", "http":
 		ss.Network = "h2"
@@ -594,11 +654,32 @@ func populateTlsSettings(ss *StreamSettings, p *model.ProfileItem, sniExt string
 		ss.RealitySettings = tlsSetting
 		ss.TlsSettings = nil
 	} else if p.Security == "tls" {
+		tlsSetting.EchConfigList = p.ECHConfigList
+		tlsSetting.PinnedPeerCertificateChainSha256 = certSha256ToBase64(p.PinnedPeerCertSha256)
+		tlsSetting.VerifyPeerCertByName = p.VerifyPeerCertByName
+		tlsSetting.MinVersion = p.MinVersion
+		tlsSetting.MaxVersion = p.MaxVersion
+		tlsSetting.DisableSystemRoot = p.DisableSystemRoot
+		// 固定证书后不再需要 allowInsecure
+		if len(tlsSetting.PinnedPeerCertificateChainSha256) > 0 {
+			tlsSetting.AllowInsecure = false
+		}
 		ss.TlsSettings = tlsSetting
 		ss.RealitySettings = nil
 	}
 }
 
+// certSha256ToBase64 将十六进制证书哈希列表转换为 Xray 使用的 Base64 列表
+func certSha256ToBase64(pins string) []string {
+	var result []string
+	for _, item := range splitAndTrim(pins, ",") {
+		if b, err := hex.DecodeString(item); err == nil && len(b) == sha256.Size {
+			result = append(result, base64.StdEncoding.EncodeToString(b))
+		}
+	}
+	return result
+}
+
 // 辅助函数
 func splitAndTrim(s, sep string) []string {
 	parts := strings.Split(s, sep)
@@ -0,0 +1,396 @@
+package geo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// hostsIgnored hosts 文件中不作为域名收录的主机名
+var hostsIgnored = map[string]bool{
+	"localhost": true, "localhost.localdomain": true, "local": true, "broadcasthost": true,
+	"ip6-localhost": true, "ip6-loopback": true, "ip6-localnet": true, "ip6-mcastprefix": true,
+	"ip6-allnodes": true, "ip6-allrouters": true, "ip6-allhosts": true, "0.0.0.0": true,
+}
+
+// domainLabel 域名中允许的字符 (含 _，部分广告列表会出现)
+var domainLabel = regexp.MustCompile(`^[a-z0-9_]([a-z0-9_-]*[a-z0-9_])?$`)
+
+// Builder 从文本列表构建 dat 文件，按分类去重
+type Builder struct {
+	sites    map[string]map[domainKey]*Domain
+	ips      map[string][]CIDR
+	Warnings []string // 跳过的行
+}
+
+type domainKey struct {
+	Type  DomainType
+	Value string
+}
+
+// NewBuilder 创建构建器
+func NewBuilder() *Builder {
+	return &Builder{sites: map[string]map[domainKey]*Domain{}, ips: map[string][]CIDR{}}
+}
+
+// Add 读取一个列表加入分类 code，attrs 附加到该列表的每个域名
+// 支持的行格式:
+//   - 域名列表: example.com、full:/domain:/keyword:/regexp: 前缀，可附带 @attr
+//   - hosts: 0.0.0.0 example.com [more.example.com ...]
+//   - AdGuard: ||example.com^
+//   - IP 与 CIDR: 1.2.3.4、10.0.0.0/8、2001:db8::/32
+//
+// 无法识别的行记入 Warnings 并跳过
+func (b *Builder) Add(code string, attrs []string, name string, r io.Reader) error {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return fmt.Errorf("%s: 分类名为空", name)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if lineNo == 1 {
+			line = string(bytes.TrimPrefix([]byte(line), []byte("\xef\xbb\xbf")))
+		}
+		domains, cidrs, err := parseListLine(line)
+		if err != nil {
+			b.Warnings = append(b.Warnings, fmt.Sprintf("%s:%d: %v: %s", name, lineNo, err, strings.TrimSpace(line)))
+			continue
+		}
+		for _, d := range domains {
+			for _, a := range attrs {
+				d.Attrs = appendAttr(d.Attrs, a)
+			}
+			b.addDomain(code, d)
+		}
+		if len(cidrs) > 0 {
+			b.ips[code] = append(b.ips[code], cidrs...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if _, ok := b.sites[code]; !ok && len(b.ips[code]) == 0 {
+		b.Warnings = append(b.Warnings, fmt.Sprintf("%s: 没有可用的条目", name))
+	}
+	return nil
+}
+
+func (b *Builder) addDomain(code string, d Domain) {
+	set := b.sites[code]
+	if set == nil {
+		set = map[domainKey]*Domain{}
+		b.sites[code] = set
+	}
+	key := domainKey{d.Type, d.Value}
+	if existing := set[key]; existing != nil {
+		for _, a := range d.Attrs {
+			existing.Attrs = appendAttr(existing.Attrs, a.Key)
+		}
+		return
+	}
+	set[key] = &d
+}
+
+// Kind 根据条目判断输出 geosite 还是 geoip，二者混合时报错
+func (b *Builder) Kind() (string, error) {
+	hasSite, hasIP := len(b.sites) > 0, len(b.ips) > 0
+	switch {
+	case hasSite && hasIP:
+		return "", fmt.Errorf("输入同时包含域名和 IP，请分别构建 geosite 和 geoip 文件 (或用 -type 指定)")
+	case hasIP:
+		return KindIP, nil
+	case hasSite:
+		return KindSite, nil
+	}
+	return "", fmt.Errorf("没有可用的条目")
+}
+
+// Sites 返回去重后的 geosite 分类，按分类名排序
+// 已被同分类 domain: 覆盖且属性相同的子域名条目会被去掉
+func (b *Builder) Sites() []*Site {
+	codes := make([]string, 0, len(b.sites))
+	for code := range b.sites {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var sites []*Site
+	for _, code := range codes {
+		set := b.sites[code]
+		var domains []Domain
+		for key, d := range set {
+			if key.Type == DomainFull || key.Type == DomainRoot {
+				if coveredByParent(set, key, d) {
+					continue
+				}
+			}
+			sortAttrs(d.Attrs)
+			domains = append(domains, *d)
+		}
+		sort.Slice(domains, func(i, j int) bool {
+			if domains[i].Type != domains[j].Type {
+				return domains[i].Type < domains[j].Type
+			}
+			return domains[i].Value < domains[j].Value
+		})
+		sites = append(sites, &Site{Code: code, Domains: domains})
+	}
+	return sites
+}
+
+// IPSets 返回去重后的 geoip 分类，被其他网段包含的网段会被去掉
+func (b *Builder) IPSets() []*IPSet {
+	codes := make([]string, 0, len(b.ips))
+	for code := range b.ips {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var sets []*IPSet
+	for _, code := range codes {
+		sets = append(sets, &IPSet{Code: code, CIDRs: dedupeCIDRs(b.ips[code])})
+	}
+	return sets
+}
+
+// parseListLine 解析一行，返回域名或网段；空行和注释返回空结果
+func parseListLine(line string) ([]Domain, []CIDR, error) {
+	line = strings.TrimSpace(stripComment(line))
+	if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "[") {
+		return nil, nil, nil
+	}
+
+	// AdGuard
+	if strings.HasPrefix(line, "@@") {
+		return nil, nil, fmt.Errorf("不支持 AdGuard 例外规则")
+	}
+	if strings.Contains(line, "##") || strings.Contains(line, "#@#") || strings.Contains(line, "#$#") {
+		return nil, nil, fmt.Errorf("不支持 AdGuard 元素隐藏规则")
+	}
+	if strings.HasPrefix(line, "||") {
+		d, err := parseAdGuard(line)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []Domain{d}, nil, nil
+	}
+
+	fields := strings.Fields(line)
+
+	// hosts: IP 后跟一个或多个主机名
+	if len(fields) > 1 && net.ParseIP(fields[0]) != nil {
+		var domains []Domain
+		for _, host := range fields[1:] {
+			host = strings.ToLower(strings.TrimSuffix(host, "."))
+			if hostsIgnored[host] {
+				continue
+			}
+			if !validDomain(host) {
+				return nil, nil, fmt.Errorf("无效的主机名 %q", host)
+			}
+			domains = append(domains, Domain{Type: DomainFull, Value: host})
+		}
+		return domains, nil, nil
+	}
+
+	// IP / CIDR
+	if c, ok := parseCIDR(fields[0]); ok {
+		if len(fields) > 1 {
+			return nil, nil, fmt.Errorf("IP 条目不支持属性")
+		}
+		return nil, []CIDR{c}, nil
+	}
+
+	// 域名列表
+	d, err := parseDomainEntry(fields[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, f := range fields[1:] {
+		if !strings.HasPrefix(f, "@") || len(f) == 1 {
+			return nil, nil, fmt.Errorf("无法识别 %q，属性应以 @ 开头", f)
+		}
+		d.Attrs = appendAttr(d.Attrs, f[1:])
+	}
+	return []Domain{d}, nil, nil
+}
+
+// stripComment 去掉行首或空白后的 # 注释 (保留 AdGuard 的 ## 元素隐藏规则以便报告)
+func stripComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseAdGuard 解析 ||example.com^，带修饰符的规则不支持
+func parseAdGuard(line string) (Domain, error) {
+	rest := strings.TrimPrefix(line, "||")
+	i := strings.IndexByte(rest, '^')
+	if i < 0 {
+		return Domain{}, fmt.Errorf("AdGuard 规则缺少 ^")
+	}
+	host, tail := strings.ToLower(rest[:i]), rest[i+1:]
+	if tail != "" && tail != "|" {
+		return Domain{}, fmt.Errorf("不支持 AdGuard 修饰符 %q", tail)
+	}
+	if !validDomain(host) {
+		return Domain{}, fmt.Errorf("无效的域名 %q", host)
+	}
+	return Domain{Type: DomainRoot, Value: host}, nil
+}
+
+// parseDomainEntry 解析带前缀的域名，无前缀时视为 domain: (与 domain-list-community 一致)
+func parseDomainEntry(s string) (Domain, error) {
+	typ, value := DomainRoot, s
+	for t, prefix := range domainPrefixes {
+		if strings.HasPrefix(s, prefix) {
+			typ, value = t, s[len(prefix):]
+			break
+		}
+	}
+	if typ == DomainRoot && strings.Contains(value, ":") {
+		return Domain{}, fmt.Errorf("不支持的前缀 %q", value[:strings.IndexByte(value, ':')+1])
+	}
+	if value == "" {
+		return Domain{}, fmt.Errorf("值为空")
+	}
+
+	switch typ {
+	case DomainRegex:
+		if _, err := regexp.Compile(value); err != nil {
+			return Domain{}, fmt.Errorf("正则无效: %v", err)
+		}
+	case DomainPlain:
+		value = strings.ToLower(value)
+	default:
+		value = strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(value, "."), "."))
+		if !validDomain(value) {
+			return Domain{}, fmt.Errorf("无效的域名 %q", value)
+		}
+	}
+	return Domain{Type: typ, Value: value}, nil
+}
+
+// parseCIDR 解析 CIDR 或单个 IP (视为 /32、/128)
+func parseCIDR(s string) (CIDR, bool) {
+	if _, n, err := net.ParseCIDR(s); err == nil {
+		ones, _ := n.Mask.Size()
+		ip := n.IP
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+		}
+		return CIDR{IP: ip, Prefix: ones}, true
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		if v4 := ip.To4(); v4 != nil {
+			return CIDR{IP: v4, Prefix: 32}, true
+		}
+		return CIDR{IP: ip, Prefix: 128}, true
+	}
+	return CIDR{}, false
+}
+
+func validDomain(s string) bool {
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if len(label) > 63 || !domainLabel.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+func appendAttr(attrs []Attribute, key string) []Attribute {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if key == "" {
+		return attrs
+	}
+	for _, a := range attrs {
+		if a.Key == key {
+			return attrs
+		}
+	}
+	return append(attrs, Attribute{Key: key, BoolValue: true})
+}
+
+func sortAttrs(attrs []Attribute) {
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+}
+
+// coveredByParent 判断条目是否已被上级 domain: 覆盖 (且属性是其子集，否则属性过滤结果会变化)
+func coveredByParent(set map[domainKey]*Domain, key domainKey, d *Domain) bool {
+	value := key.Value
+	if key.Type == DomainFull {
+		if parent := set[domainKey{DomainRoot, value}]; parent != nil && attrsSubset(d.Attrs, parent.Attrs) {
+			return true
+		}
+	}
+	for {
+		i := strings.IndexByte(value, '.')
+		if i < 0 {
+			return false
+		}
+		value = value[i+1:]
+		if parent := set[domainKey{DomainRoot, value}]; parent != nil && attrsSubset(d.Attrs, parent.Attrs) {
+			return true
+		}
+	}
+}
+
+func attrsSubset(attrs, of []Attribute) bool {
+	for _, a := range attrs {
+		found := false
+		for _, b := range of {
+			if a.Key == b.Key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// dedupeCIDRs 排序并去掉重复或被包含的网段
+func dedupeCIDRs(cidrs []CIDR) []CIDR {
+	nets := make([]CIDR, 0, len(cidrs))
+	for _, c := range cidrs {
+		n := c.IPNet()
+		nets = append(nets, CIDR{IP: n.IP, Prefix: c.Prefix})
+	}
+	sort.Slice(nets, func(i, j int) bool {
+		if len(nets[i].IP) != len(nets[j].IP) {
+			return len(nets[i].IP) < len(nets[j].IP)
+		}
+		if c := bytes.Compare(nets[i].IP, nets[j].IP); c != 0 {
+			return c < 0
+		}
+		return nets[i].Prefix < nets[j].Prefix
+	})
+
+	var result []CIDR
+	var last *net.IPNet
+	for _, c := range nets {
+		if last != nil && len(last.IP) == len(c.IP) && last.Contains(c.IP) {
+			continue
+		}
+		result = append(result, c)
+		last = c.IPNet()
+	}
+	return result
+}
@@ -0,0 +1,199 @@
+package geo
+
+import (
+	"fmt"
+	"net"
+)
+
+// CIDR geoip 中的一个网段
+type CIDR struct {
+	IP     net.IP `json:"ip"`
+	Prefix int    `json:"prefix"`
+}
+
+// String 以 CIDR 写法输出
+func (c CIDR) String() string {
+	return fmt.Sprintf("%s/%d", c.IP, c.Prefix)
+}
+
+// IPNet 转换为 net.IPNet
+func (c CIDR) IPNet() *net.IPNet {
+	bits := 8 * len(c.IP)
+	return &net.IPNet{IP: c.IP.Mask(net.CIDRMask(c.Prefix, bits)), Mask: net.CIDRMask(c.Prefix, bits)}
+}
+
+// IPSet geoip 中的一个分类
+type IPSet struct {
+	Code         string `json:"code"`
+	CIDRs        []CIDR `json:"cidrs"`
+	ReverseMatch bool   `json:"reverseMatch,omitempty"`
+}
+
+// DecodeIPList 解析 GeoIPList
+func DecodeIPList(data []byte) ([]*IPSet, error) {
+	var sets []*IPSet
+	r := &pbReader{data: data}
+	for !r.done() {
+		field, wire, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if field != 1 {
+			if err := r.skip(wire); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		b, err := readBytes(r, field, wire)
+		if err != nil {
+			return nil, err
+		}
+		set, err := decodeIPSet(b)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个分类: %v", len(sets)+1, err)
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+func decodeIPSet(data []byte) (*IPSet, error) {
+	set := &IPSet{}
+	r := &pbReader{data: data}
+	for !r.done() {
+		field, wire, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		switch field {
+		case 1:
+			b, err := readBytes(r, field, wire)
+			if err != nil {
+				return nil, err
+			}
+			set.Code = string(b)
+		case 2:
+			b, err := readBytes(r, field, wire)
+			if err != nil {
+				return nil, err
+			}
+			c, err := decodeCIDR(b)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", set.Code, err)
+			}
+			set.CIDRs = append(set.CIDRs, c)
+		case 3:
+			if err := expect(field, wire, wireVarint); err != nil {
+				return nil, err
+			}
+			v, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			set.ReverseMatch = v != 0
+		default:
+			if err := r.skip(wire); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return set, nil
+}
+
+func decodeCIDR(data []byte) (CIDR, error) {
+	var c CIDR
+	r := &pbReader{data: data}
+	for !r.done() {
+		field, wire, err := r.next()
+		if err != nil {
+			return c, err
+		}
+		switch field {
+		case 1:
+			b, err := readBytes(r, field, wire)
+			if err != nil {
+				return c, err
+			}
+			if len(b) != net.IPv4len && len(b) != net.IPv6len {
+				return c, fmt.Errorf("无效的 IP 长度 %d", len(b))
+			}
+			c.IP = append(net.IP{}, b...)
+		case 2:
+			if err := expect(field, wire, wireVarint); err != nil {
+				return c, err
+			}
+			v, err := r.varint()
+			if err != nil {
+				return c, err
+			}
+			c.Prefix = int(v)
+		default:
+			if err := r.skip(wire); err != nil {
+				return c, err
+			}
+		}
+	}
+	if c.IP == nil {
+		return c, fmt.Errorf("缺少 IP")
+	}
+	if c.Prefix > 8*len(c.IP) {
+		return c, fmt.Errorf("%s: 前缀长度 %d 超出范围", c.IP, c.Prefix)
+	}
+	return c, nil
+}
+
+// IPMatcher 编译后的网段匹配器
+type IPMatcher struct {
+	nets    []*net.IPNet
+	cidrs   []CIDR
+	reverse bool
+}
+
+// NewIPMatcher 编译网段列表
+func NewIPMatcher(set *IPSet) *IPMatcher {
+	m := &IPMatcher{cidrs: set.CIDRs, reverse: set.ReverseMatch}
+	for _, c := range set.CIDRs {
+		m.nets = append(m.nets, c.IPNet())
+	}
+	return m
+}
+
+// Match 返回命中的网段；reverseMatch 的分类在未命中任何网段时视为命中
+func (m *IPMatcher) Match(ip net.IP) (*CIDR, bool) {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	for i, n := range m.nets {
+		if len(n.IP) == len(ip) && n.Contains(ip) {
+			if m.reverse {
+				return nil, false
+			}
+			return &m.cidrs[i], true
+		}
+	}
+	return nil, m.reverse
+}
+
+// EncodeIPList 编码为 GeoIPList
+func EncodeIPList(sets []*IPSet) []byte {
+	list := &pbWriter{}
+	for _, s := range sets {
+		set := &pbWriter{}
+		set.stringField(1, s.Code)
+		for _, c := range s.CIDRs {
+			cidr := &pbWriter{}
+			ip := c.IP
+			if v4 := ip.To4(); v4 != nil {
+				ip = v4
+			}
+			cidr.bytesField(1, ip)
+			cidr.uintField(2, uint64(c.Prefix))
+			set.bytesField(2, cidr.buf)
+		}
+		if s.ReverseMatch {
+			set.uintField(3, 1)
+		}
+		list.bytesField(1, set.buf)
+	}
+	return list.buf
+}
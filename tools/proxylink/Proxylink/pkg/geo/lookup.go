@@ -0,0 +1,43 @@
+package geo
+
+import (
+	"net"
+)
+
+// Hit 反查结果: 包含目标的分类及命中的条目
+type Hit struct {
+	Code  string `json:"code"`
+	Entry string `json:"entry"`
+}
+
+// LookupDomain 返回包含该域名的全部分类
+func (f *File) LookupDomain(domain string) ([]Hit, error) {
+	var hits []Hit
+	for _, s := range f.Sites {
+		m, err := NewSiteMatcher(s.Domains)
+		if err != nil {
+			return nil, err
+		}
+		if d := m.Match(domain); d != nil {
+			hits = append(hits, Hit{Code: s.Code, Entry: d.String()})
+		}
+	}
+	return hits, nil
+}
+
+// LookupIP 返回包含该 IP 的全部分类 (reverseMatch 的分类按反向结果)
+func (f *File) LookupIP(ip net.IP) []Hit {
+	var hits []Hit
+	for _, s := range f.IPs {
+		c, ok := NewIPMatcher(s).Match(ip)
+		if !ok {
+			continue
+		}
+		entry := "reverseMatch"
+		if c != nil {
+			entry = c.String()
+		}
+		hits = append(hits, Hit{Code: s.Code, Entry: entry})
+	}
+	return hits
+}
@@ -0,0 +1,139 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// protobuf wire type
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("数据被截断")
+
+// pbReader 最小的 protobuf 读取器，只支持 dat 文件用到的 wire type
+type pbReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *pbReader) done() bool {
+	return r.pos >= len(r.data)
+}
+
+func (r *pbReader) varint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if r.pos >= len(r.data) {
+			return 0, errTruncated
+		}
+		b := r.data[r.pos]
+		r.pos++
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, errors.New("varint 过长")
+}
+
+// next 读取字段号和 wire type
+func (r *pbReader) next() (field int, wire int, err error) {
+	key, err := r.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	field, wire = int(key>>3), int(key&7)
+	if field == 0 {
+		return 0, 0, fmt.Errorf("偏移 %d: 无效的字段号 0", r.pos)
+	}
+	return field, wire, nil
+}
+
+func (r *pbReader) bytes() ([]byte, error) {
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errTruncated
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+// skip 跳过未知字段
+func (r *pbReader) skip(wire int) error {
+	var n int
+	switch wire {
+	case wireVarint:
+		_, err := r.varint()
+		return err
+	case wireBytes:
+		_, err := r.bytes()
+		return err
+	case wireFixed64:
+		n = 8
+	case wireFixed32:
+		n = 4
+	default:
+		return fmt.Errorf("偏移 %d: 不支持的 wire type %d", r.pos, wire)
+	}
+	if r.pos+n > len(r.data) {
+		return errTruncated
+	}
+	r.pos += n
+	return nil
+}
+
+// expect 校验字段的 wire type
+func expect(field, wire, want int) error {
+	if wire != want {
+		return fmt.Errorf("字段 %d 的 wire type 为 %d，应为 %d", field, wire, want)
+	}
+	return nil
+}
+
+// pbWriter 最小的 protobuf 写入器
+type pbWriter struct {
+	buf []byte
+}
+
+func (w *pbWriter) varint(v uint64) {
+	for v >= 0x80 {
+		w.buf = append(w.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	w.buf = append(w.buf, byte(v))
+}
+
+func (w *pbWriter) key(field, wire int) {
+	w.varint(uint64(field)<<3 | uint64(wire))
+}
+
+// uintField 写入 varint 字段，零值按 proto3 省略
+func (w *pbWriter) uintField(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	w.key(field, wireVarint)
+	w.varint(v)
+}
+
+func (w *pbWriter) bytesField(field int, b []byte) {
+	w.key(field, wireBytes)
+	w.varint(uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *pbWriter) stringField(field int, s string) {
+	if s == "" {
+		return
+	}
+	w.bytesField(field, []byte(s))
+}
@@ -0,0 +1,364 @@
+package geo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DomainType 域名匹配方式 (与 v2ray router.Domain.Type 一致)
+type DomainType int
+
+const (
+	DomainPlain DomainType = 0 // 子串匹配 (keyword:)
+	DomainRegex DomainType = 1 // 正则 (regexp:)
+	DomainRoot  DomainType = 2 // 域名及其子域名 (domain:)
+	DomainFull  DomainType = 3 // 完整匹配 (full:)
+)
+
+// domainPrefixes 各匹配方式在路由规则中的前缀
+var domainPrefixes = map[DomainType]string{
+	DomainPlain: "keyword:",
+	DomainRegex: "regexp:",
+	DomainRoot:  "domain:",
+	DomainFull:  "full:",
+}
+
+// Attribute 域名属性 (如 @ads、@cn)
+type Attribute struct {
+	Key       string `json:"key"`
+	BoolValue bool   `json:"bool,omitempty"`
+	IntValue  int64  `json:"int,omitempty"`
+}
+
+// Domain geosite 中的一条域名
+type Domain struct {
+	Type  DomainType  `json:"type"`
+	Value string      `json:"value"`
+	Attrs []Attribute `json:"attrs,omitempty"`
+}
+
+// String 以路由规则的写法输出，如 domain:google.com@ads
+func (d Domain) String() string {
+	s := domainPrefixes[d.Type] + d.Value
+	for _, a := range d.Attrs {
+		s += "@" + a.Key
+	}
+	return s
+}
+
+// HasAttr 判断是否带有指定属性
+func (d Domain) HasAttr(key string) bool {
+	for _, a := range d.Attrs {
+		if strings.EqualFold(a.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Site geosite 中的一个分类
+type Site struct {
+	Code    string   `json:"code"`
+	Domains []Domain `json:"domains"`
+}
+
+// DecodeSiteList 解析 GeoSiteList
+func DecodeSiteList(data []byte) ([]*Site, error) {
+	var sites []*Site
+	r := &pbReader{data: data}
+	for !r.done() {
+		field, wire, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if field != 1 {
+			if err := r.skip(wire); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := expect(field, wire, wireBytes); err != nil {
+			return nil, err
+		}
+		b, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		site, err := decodeSite(b)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个分类: %v", len(sites)+1, err)
+		}
+		sites = append(sites, site)
+	}
+	return sites, nil
+}
+
+func decodeSite(data []byte) (*Site, error) {
+	site := &Site{}
+	r := &pbReader{data: data}
+	for !r.done() {
+		field, wire, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		switch field {
+		case 1:
+			b, err := readBytes(r, field, wire)
+			if err != nil {
+				return nil, err
+			}
+			site.Code = string(b)
+		case 2:
+			b, err := readBytes(r, field, wire)
+			if err != nil {
+				return nil, err
+			}
+			d, err := decodeDomain(b)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", site.Code, err)
+			}
+			site.Domains = append(site.Domains, d)
+		default:
+			if err := r.skip(wire); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return site, nil
+}
+
+func decodeDomain(data []byte) (Domain, error) {
+	var d Domain
+	r := &pbReader{data: data}
+	for !r.done() {
+		field, wire, err := r.next()
+		if err != nil {
+			return d, err
+		}
+		switch field {
+		case 1:
+			if err := expect(field, wire, wireVarint); err != nil {
+				return d, err
+			}
+			v, err := r.varint()
+			if err != nil {
+				return d, err
+			}
+			d.Type = DomainType(v)
+		case 2:
+			b, err := readBytes(r, field, wire)
+			if err != nil {
+				return d, err
+			}
+			d.Value = string(b)
+		case 3:
+			b, err := readBytes(r, field, wire)
+			if err != nil {
+				return d, err
+			}
+			a, err := decodeAttribute(b)
+			if err != nil {
+				return d, err
+			}
+			d.Attrs = append(d.Attrs, a)
+		default:
+			if err := r.skip(wire); err != nil {
+				return d, err
+			}
+		}
+	}
+	return d, nil
+}
+
+func decodeAttribute(data []byte) (Attribute, error) {
+	var a Attribute
+	r := &pbReader{data: data}
+	for !r.done() {
+		field, wire, err := r.next()
+		if err != nil {
+			return a, err
+		}
+		switch field {
+		case 1:
+			b, err := readBytes(r, field, wire)
+			if err != nil {
+				return a, err
+			}
+			a.Key = string(b)
+		case 2, 3:
+			if err := expect(field, wire, wireVarint); err != nil {
+				return a, err
+			}
+			v, err := r.varint()
+			if err != nil {
+				return a, err
+			}
+			if field == 2 {
+				a.BoolValue = v != 0
+			} else {
+				a.IntValue = int64(v)
+			}
+		default:
+			if err := r.skip(wire); err != nil {
+				return a, err
+			}
+		}
+	}
+	return a, nil
+}
+
+// readBytes 读取 length-delimited 字段
+func readBytes(r *pbReader, field, wire int) ([]byte, error) {
+	if err := expect(field, wire, wireBytes); err != nil {
+		return nil, err
+	}
+	return r.bytes()
+}
+
+// attrFilter code 中的 @attr / @!attr 过滤条件
+type attrFilter struct {
+	key    string
+	negate bool
+}
+
+// splitCode 拆分 "google@ads@!cn" 为分类名和属性过滤条件
+func splitCode(code string) (string, []attrFilter) {
+	parts := strings.Split(code, "@")
+	var filters []attrFilter
+	for _, p := range parts[1:] {
+		if p == "" {
+			continue
+		}
+		filters = append(filters, attrFilter{key: strings.TrimPrefix(p, "!"), negate: strings.HasPrefix(p, "!")})
+	}
+	return strings.TrimSpace(parts[0]), filters
+}
+
+// filterDomains 按属性过滤，多个条件同时满足
+func filterDomains(domains []Domain, filters []attrFilter) []Domain {
+	if len(filters) == 0 {
+		return domains
+	}
+	var result []Domain
+	for _, d := range domains {
+		ok := true
+		for _, f := range filters {
+			if d.HasAttr(f.key) == f.negate {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// SiteMatcher 编译后的域名匹配器
+type SiteMatcher struct {
+	full  map[string]*Domain
+	root  map[string]*Domain
+	plain []*Domain
+	regex []regexMatcher
+}
+
+type regexMatcher struct {
+	re     *regexp.Regexp
+	domain *Domain
+}
+
+// NewSiteMatcher 编译域名列表
+func NewSiteMatcher(domains []Domain) (*SiteMatcher, error) {
+	m := &SiteMatcher{full: map[string]*Domain{}, root: map[string]*Domain{}}
+	for i := range domains {
+		d := &domains[i]
+		value := strings.ToLower(d.Value)
+		switch d.Type {
+		case DomainFull:
+			if m.full[value] == nil {
+				m.full[value] = d
+			}
+		case DomainRoot:
+			if m.root[value] == nil {
+				m.root[value] = d
+			}
+		case DomainPlain:
+			m.plain = append(m.plain, d)
+		case DomainRegex:
+			re, err := regexp.Compile(d.Value)
+			if err != nil {
+				return nil, fmt.Errorf("regexp:%s: %v", d.Value, err)
+			}
+			m.regex = append(m.regex, regexMatcher{re, d})
+		default:
+			return nil, fmt.Errorf("%s: 未知的域名类型 %d", d.Value, d.Type)
+		}
+	}
+	return m, nil
+}
+
+// Match 返回第一条命中的域名，未命中返回 nil
+func (m *SiteMatcher) Match(domain string) *Domain {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if d := m.full[domain]; d != nil {
+		return d
+	}
+	for suffix := domain; ; {
+		if d := m.root[suffix]; d != nil {
+			return d
+		}
+		i := strings.IndexByte(suffix, '.')
+		if i < 0 {
+			break
+		}
+		suffix = suffix[i+1:]
+	}
+	for _, d := range m.plain {
+		if strings.Contains(domain, strings.ToLower(d.Value)) {
+			return d
+		}
+	}
+	for _, r := range m.regex {
+		if r.re.MatchString(domain) {
+			return r.domain
+		}
+	}
+	return nil
+}
+
+// EncodeSiteList 编码为 GeoSiteList
+func EncodeSiteList(sites []*Site) []byte {
+	list := &pbWriter{}
+	for _, s := range sites {
+		site := &pbWriter{}
+		site.stringField(1, s.Code)
+		for _, d := range s.Domains {
+			domain := &pbWriter{}
+			domain.uintField(1, uint64(d.Type))
+			domain.stringField(2, d.Value)
+			for _, a := range d.Attrs {
+				attr := &pbWriter{}
+				attr.stringField(1, a.Key)
+				if a.IntValue != 0 {
+					attr.uintField(3, uint64(a.IntValue))
+				} else {
+					attr.key(2, wireVarint)
+					attr.varint(boolToUint(a.BoolValue))
+				}
+				domain.bytesField(3, attr.buf)
+			}
+			site.bytesField(2, domain.buf)
+		}
+		list.bytesField(1, site.buf)
+	}
+	return list.buf
+}
+
+func boolToUint(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,243 @@
+package geo
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// 文件类型
+const (
+	KindSite = "geosite"
+	KindIP   = "geoip"
+)
+
+// File 解析后的 dat 文件
+type File struct {
+	Path  string
+	Kind  string
+	Sites []*Site
+	IPs   []*IPSet
+}
+
+// Load 读取 dat 文件并按内容识别 GeoSiteList / GeoIPList
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &File{Path: path, Kind: detectKind(data, path)}
+	if file.Kind == KindIP {
+		file.IPs, err = DecodeIPList(data)
+	} else {
+		file.Sites, err = DecodeSiteList(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return file, nil
+}
+
+// detectKind 查看第一个分类的第一条记录：CIDR 以 bytes 类型的字段 1 (IP) 开头，
+// Domain 以 varint 类型的字段 1 (type) 或字段 2 (value) 开头；无法判断时按文件名
+func detectKind(data []byte, path string) string {
+	fallback := KindSite
+	if strings.Contains(strings.ToLower(filepath.Base(path)), "ip") {
+		fallback = KindIP
+	}
+
+	r := &pbReader{data: data}
+	for !r.done() {
+		field, wire, err := r.next()
+		if err != nil || field != 1 || wire != wireBytes {
+			return fallback
+		}
+		entry, err := r.bytes()
+		if err != nil {
+			return fallback
+		}
+		er := &pbReader{data: entry}
+		for !er.done() {
+			field, wire, err := er.next()
+			if err != nil {
+				return fallback
+			}
+			if field != 2 || wire != wireBytes {
+				if er.skip(wire) != nil {
+					return fallback
+				}
+				continue
+			}
+			item, err := er.bytes()
+			if err != nil {
+				return fallback
+			}
+			ir := &pbReader{data: item}
+			if ir.done() {
+				continue
+			}
+			field, wire, err = ir.next()
+			if err != nil {
+				return fallback
+			}
+			if field == 1 && wire == wireBytes {
+				return KindIP
+			}
+			return KindSite
+		}
+	}
+	return fallback
+}
+
+// Codes 返回全部分类名
+func (f *File) Codes() []string {
+	var codes []string
+	for _, s := range f.Sites {
+		codes = append(codes, s.Code)
+	}
+	for _, s := range f.IPs {
+		codes = append(codes, s.Code)
+	}
+	return codes
+}
+
+// Site 按分类名 (不区分大小写) 查找，code 可带 @attr 过滤
+func (f *File) Site(code string) (*Site, error) {
+	if f.Kind != KindSite {
+		return nil, fmt.Errorf("%s 不是 geosite 文件", filepath.Base(f.Path))
+	}
+	name, filters := splitCode(code)
+	for _, s := range f.Sites {
+		if strings.EqualFold(s.Code, name) {
+			return &Site{Code: s.Code, Domains: filterDomains(s.Domains, filters)}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s 中没有分类 %s", filepath.Base(f.Path), name)
+}
+
+// IPSet 按分类名 (不区分大小写) 查找
+func (f *File) IPSet(code string) (*IPSet, error) {
+	if f.Kind != KindIP {
+		return nil, fmt.Errorf("%s 不是 geoip 文件", filepath.Base(f.Path))
+	}
+	for _, s := range f.IPs {
+		if strings.EqualFold(s.Code, code) {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("%s 中没有分类 %s", filepath.Base(f.Path), code)
+}
+
+// Store 按需加载 Xray 资源目录中的 dat 文件并缓存匹配器，
+// 实现 routing.GeoData 供路由模拟使用
+type Store struct {
+	Dir string
+
+	mu    sync.Mutex
+	files map[string]*File
+	sites map[string]*SiteMatcher
+	ips   map[string]*IPMatcher
+}
+
+// NewStore 创建资源目录 (Xray 可执行文件所在目录或 XRAY_LOCATION_ASSET) 的数据源
+func NewStore(dir string) *Store {
+	return &Store{
+		Dir:   dir,
+		files: map[string]*File{},
+		sites: map[string]*SiteMatcher{},
+		ips:   map[string]*IPMatcher{},
+	}
+}
+
+// Open 加载文件，name 为文件名时在资源目录中查找
+func (s *Store) Open(name string) (*File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.open(name)
+}
+
+func (s *Store) open(name string) (*File, error) {
+	if f, ok := s.files[name]; ok {
+		return f, nil
+	}
+	path := name
+	if !strings.ContainsRune(name, filepath.Separator) && !strings.Contains(name, "/") {
+		path = filepath.Join(s.Dir, name)
+	}
+	f, err := Load(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("找不到 %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.files[name] = f
+	return f, nil
+}
+
+// MatchSite 判断域名是否属于 file 中的分类 code
+func (s *Store) MatchSite(file, code, domain string) (bool, error) {
+	m, err := s.siteMatcher(file, code)
+	if err != nil {
+		return false, err
+	}
+	return m.Match(domain) != nil, nil
+}
+
+// MatchIP 判断 IP 是否属于 file 中的分类 code
+func (s *Store) MatchIP(file, code string, ip net.IP) (bool, error) {
+	m, err := s.ipMatcher(file, code)
+	if err != nil {
+		return false, err
+	}
+	_, ok := m.Match(ip)
+	return ok, nil
+}
+
+func (s *Store) siteMatcher(file, code string) (*SiteMatcher, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := file + ":" + strings.ToLower(code)
+	if m, ok := s.sites[key]; ok {
+		return m, nil
+	}
+	f, err := s.open(file)
+	if err != nil {
+		return nil, err
+	}
+	site, err := f.Site(code)
+	if err != nil {
+		return nil, err
+	}
+	m, err := NewSiteMatcher(site.Domains)
+	if err != nil {
+		return nil, err
+	}
+	s.sites[key] = m
+	return m, nil
+}
+
+func (s *Store) ipMatcher(file, code string) (*IPMatcher, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := file + ":" + strings.ToLower(code)
+	if m, ok := s.ips[key]; ok {
+		return m, nil
+	}
+	f, err := s.open(file)
+	if err != nil {
+		return nil, err
+	}
+	set, err := f.IPSet(code)
+	if err != nil {
+		return nil, err
+	}
+	m := NewIPMatcher(set)
+	s.ips[key] = m
+	return m, nil
+}
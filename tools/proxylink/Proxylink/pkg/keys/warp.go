@@ -0,0 +1,38 @@
+package keys
+
+import (
+	"net"
+	"strings"
+)
+
+// WarpPublicKey Cloudflare WARP 服务端公钥 (所有 WARP 端点相同)
+const WarpPublicKey = "bmXOC+F1FxEMF9dyiK2H5/1SUtzH0JuVo51h2wPfgyo="
+
+// warpCIDRs Cloudflare WARP 端点地址段
+var warpCIDRs = []string{
+	"162.159.192.0/24",
+	"162.159.193.0/24",
+	"162.159.195.0/24",
+	"188.114.96.0/22",
+	"2606:4700:d0::/48",
+	"2606:4700:d1::/48",
+}
+
+// IsWarpEndpoint 判断服务器是否为 Cloudflare WARP 端点
+func IsWarpEndpoint(server string) bool {
+	server = strings.ToLower(strings.Trim(server, "[]"))
+	if server == "engage.cloudflareclient.com" || strings.HasSuffix(server, ".cloudflareclient.com") {
+		return true
+	}
+
+	ip := net.ParseIP(server)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range warpCIDRs {
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package keys
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// KeySize x25519 密钥长度
+const KeySize = 32
+
+// KeyPair x25519 密钥对
+type KeyPair struct {
+	PrivateKey []byte
+	PublicKey  []byte
+}
+
+// Generate 生成新的 x25519 密钥对
+func Generate() (*KeyPair, error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{
+		PrivateKey: priv.Bytes(),
+		PublicKey:  priv.PublicKey().Bytes(),
+	}, nil
+}
+
+// DerivePublicKey 由私钥计算公钥
+func DerivePublicKey(privateKey []byte) ([]byte, error) {
+	priv, err := ecdh.X25519().NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid x25519 private key: %v", err)
+	}
+	return priv.PublicKey().Bytes(), nil
+}
+
+// Decode 解码 Base64 密钥，兼容标准/URL 安全、有无 padding 四种编码
+// 查询参数中被解码为空格的 '+' 会被还原
+func Decode(s string) ([]byte, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), " ", "+")
+	if s == "" {
+		return nil, fmt.Errorf("empty key")
+	}
+
+	encodings := []*base64.Encoding{
+		base64.StdEncoding,
+		base64.URLEncoding,
+		base64.RawStdEncoding,
+		base64.RawURLEncoding,
+	}
+	for _, enc := range encodings {
+		b, err := enc.DecodeString(s)
+		if err != nil {
+			continue
+		}
+		if len(b) != KeySize {
+			return nil, fmt.Errorf("invalid key length %d, want %d bytes", len(b), KeySize)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("invalid key encoding %q", s)
+}
+
+// EncodeWireGuard 按 WireGuard 惯例编码 (标准 Base64，带 padding)
+func EncodeWireGuard(b []byte) string {
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// EncodeReality 按 Xray Reality 惯例编码 (URL 安全 Base64，无 padding)
+func EncodeReality(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// IsRealityEncoding 判断密钥字符串是否为 Reality 风格编码
+func IsRealityEncoding(s string) bool {
+	return !strings.ContainsAny(s, "+/=")
+}
+
+// NormalizeWireGuard 校验并规范化 WireGuard 密钥
+func NormalizeWireGuard(s string) (string, error) {
+	b, err := Decode(s)
+	if err != nil {
+		return "", err
+	}
+	return EncodeWireGuard(b), nil
+}
+
+// NormalizeReality 校验并规范化 Reality 公钥
+func NormalizeReality(s string) (string, error) {
+	b, err := Decode(s)
+	if err != nil {
+		return "", err
+	}
+	return EncodeReality(b), nil
+}
@@ -17,6 +17,8 @@ type ProfileItem struct {
 	Username string `json:"username,omitempty"` // 用户名 (Socks/HTTP)
 	AlterId  int    `json:"alterId,omitempty"`  // VMess alterId
 
+	VLessEncryption *VLessEncryption `json:"vlessEncryption,omitempty"` // VLESS encryption 结构化解析结果
+
 	// 传输层配置
 	Network      string `json:"network,omitempty"`      // tcp/ws/grpc/h2/kcp/quic/httpupgrade/xhttp
 	HeaderType   string `json:"headerType,omitempty"`   // 伪装类型
@@ -39,6 +41,13 @@ type ProfileItem struct {
 	Insecure      bool   `json:"insecure,omitempty"`      // 跳过证书验证
 	Mldsa65Verify string `json:"mldsa65Verify,omitempty"` // MLDSA65 验证 (pqv)
 
+	ECHConfigList        string `json:"echConfigList,omitempty"`        // ECH 配置 (ech)
+	PinnedPeerCertSha256 string `json:"pinnedPeerCertSha256,omitempty"` // 证书链 SHA256, 十六进制逗号分隔 (pcs)
+	VerifyPeerCertByName string `json:"verifyPeerCertByName,omitempty"` // 按名称校验证书 (vcn)
+	MinVersion           string `json:"minVersion,omitempty"`           // 最低 TLS 版本
+	MaxVersion           string `json:"maxVersion,omitempty"`           // 最高 TLS 版本
+	DisableSystemRoot    bool   `json:"disableSystemRoot,omitempty"`    // 不使用系统根证书 (dsr)
+
 	// Reality 配置
 	PublicKey string `json:"publicKey,omitempty"` // Reality 公钥
 	ShortID   string `json:"shortId,omitempty"`   // Reality shortId
@@ -50,6 +59,9 @@ type ProfileItem struct {
 	LocalAddress string `json:"localAddress,omitempty"` // 本地地址
 	Reserved     string `json:"reserved,omitempty"`     // 保留字段
 	MTU          int    `json:"mtu,omitempty"`          // MTU
+	DNS          string `json:"dns,omitempty"`          // DNS 服务器 (逗号分隔)
+
+	Peers []WireGuardPeer `json:"peers,omitempty"` // 多 Peer 配置 (来自 .conf 文件)
 
 	// Hysteria2 配置
 	ObfsPassword        string `json:"obfsPassword,omitempty"`        // 混淆密码
@@ -60,6 +72,30 @@ type ProfileItem struct {
 	BandwidthUp         string `json:"bandwidthUp,omitempty"`         // 上行带宽
 }
 
+// VLessEncryption VLESS 后量子加密描述 (mlkem768x25519plus.<mode>.<rtt>[.padding...].<key>...)
+type VLessEncryption struct {
+	Method  string               `json:"method"`            // mlkem768x25519plus
+	Mode    string               `json:"mode"`              // native/xorpub/random
+	RTT     string               `json:"rtt"`               // 0rtt/1rtt
+	Padding []string             `json:"padding,omitempty"` // 填充参数块
+	Keys    []VLessEncryptionKey `json:"keys"`              // 客户端公钥
+}
+
+// VLessEncryptionKey VLESS encryption 中的单个密钥
+type VLessEncryptionKey struct {
+	Type string `json:"type"` // x25519/mlkem768
+	Size int    `json:"size"` // 解码后字节数
+}
+
+// WireGuardPeer WireGuard Peer 配置
+type WireGuardPeer struct {
+	PublicKey    string `json:"publicKey"`
+	PreSharedKey string `json:"preSharedKey,omitempty"`
+	Endpoint     string `json:"endpoint"`             // host:port，IPv6 为 [addr]:port
+	AllowedIPs   string `json:"allowedIPs,omitempty"` // 允许的 IP (逗号分隔)
+	KeepAlive    int    `json:"keepAlive,omitempty"`  // PersistentKeepalive (秒)
+}
+
 // GetServerAddressAndPort 返回 server:port 格式的地址
 func (p *ProfileItem) GetServerAddressAndPort() string {
 	return fmt.Sprintf("%s:%s", p.Server, p.ServerPort)
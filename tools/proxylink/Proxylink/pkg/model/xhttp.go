@@ -0,0 +1,348 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// XhttpExtra XHTTP extra 配置 (链接中的 extra 参数)
+// 未识别的字段保存在 Unknown 中并原样输出，保证往返无损
+type XhttpExtra struct {
+	Headers              map[string]string      `json:"headers,omitempty"`
+	XPaddingBytes        *Range                 `json:"xPaddingBytes,omitempty"`
+	NoGRPCHeader         bool                   `json:"noGRPCHeader,omitempty"`
+	NoSSEHeader          bool                   `json:"noSSEHeader,omitempty"`
+	ScMaxEachPostBytes   *Range                 `json:"scMaxEachPostBytes,omitempty"`
+	ScMinPostsIntervalMs *Range                 `json:"scMinPostsIntervalMs,omitempty"`
+	ScMaxBufferedPosts   int64                  `json:"scMaxBufferedPosts,omitempty"`
+	ScStreamUpServerSecs *Range                 `json:"scStreamUpServerSecs,omitempty"`
+	Xmux                 *XmuxConfig            `json:"xmux,omitempty"`
+	DownloadSettings     *XhttpDownloadSettings `json:"downloadSettings,omitempty"`
+
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// XmuxConfig XHTTP 连接复用配置
+type XmuxConfig struct {
+	MaxConcurrency   *Range `json:"maxConcurrency,omitempty"`
+	MaxConnections   *Range `json:"maxConnections,omitempty"`
+	CMaxReuseTimes   *Range `json:"cMaxReuseTimes,omitempty"`
+	HMaxRequestTimes *Range `json:"hMaxRequestTimes,omitempty"`
+	HMaxReusableSecs *Range `json:"hMaxReusableSecs,omitempty"`
+	HKeepAlivePeriod int64  `json:"hKeepAlivePeriod,omitempty"`
+
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// XhttpDownloadSettings XHTTP 上下行分离时的下行连接配置
+// tlsSettings/realitySettings/sockopt 结构与出站一致，保留原始 JSON
+type XhttpDownloadSettings struct {
+	Address         string                  `json:"address"`
+	Port            int                     `json:"port"`
+	Network         string                  `json:"network,omitempty"`
+	Security        string                  `json:"security,omitempty"`
+	TlsSettings     json.RawMessage         `json:"tlsSettings,omitempty"`
+	RealitySettings json.RawMessage         `json:"realitySettings,omitempty"`
+	XhttpSettings   *XhttpDownloadTransport `json:"xhttpSettings,omitempty"`
+	Sockopt         json.RawMessage         `json:"sockopt,omitempty"`
+
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// XhttpDownloadTransport 下行连接的 xhttpSettings
+type XhttpDownloadTransport struct {
+	Host  string      `json:"host,omitempty"`
+	Path  string      `json:"path,omitempty"`
+	Mode  string      `json:"mode,omitempty"`
+	Extra *XhttpExtra `json:"extra,omitempty"`
+
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// Range 数值或 "min-max" 区间，保留原始写法
+type Range struct {
+	From     int64
+	To       int64
+	IsString bool
+}
+
+// UnmarshalJSON 支持 100 和 "100-1000" 两种写法
+func (r *Range) UnmarshalJSON(data []byte) error {
+	var n int64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*r = Range{From: n, To: n}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("want number or \"min-max\" string, got %s", data)
+	}
+	parts := strings.SplitN(strings.TrimSpace(s), "-", 2)
+	from, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid range %q", s)
+	}
+	to := from
+	if len(parts) == 2 {
+		if to, err = strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64); err != nil {
+			return fmt.Errorf("invalid range %q", s)
+		}
+	}
+	*r = Range{From: from, To: to, IsString: true}
+	return nil
+}
+
+// MarshalJSON 按原始写法输出
+func (r Range) MarshalJSON() ([]byte, error) {
+	if !r.IsString {
+		return json.Marshal(r.From)
+	}
+	if r.From == r.To {
+		return json.Marshal(strconv.FormatInt(r.From, 10))
+	}
+	return json.Marshal(fmt.Sprintf("%d-%d", r.From, r.To))
+}
+
+// String 返回区间的文本形式
+func (r *Range) String() string {
+	if r.From == r.To {
+		return strconv.FormatInt(r.From, 10)
+	}
+	return fmt.Sprintf("%d-%d", r.From, r.To)
+}
+
+func (e *XhttpExtra) UnmarshalJSON(data []byte) error {
+	type alias XhttpExtra
+	return unmarshalWithUnknown(data, (*alias)(e), &e.Unknown)
+}
+
+func (e XhttpExtra) MarshalJSON() ([]byte, error) {
+	type alias XhttpExtra
+	return marshalWithUnknown(alias(e), e.Unknown)
+}
+
+func (x *XmuxConfig) UnmarshalJSON(data []byte) error {
+	type alias XmuxConfig
+	return unmarshalWithUnknown(data, (*alias)(x), &x.Unknown)
+}
+
+func (x XmuxConfig) MarshalJSON() ([]byte, error) {
+	type alias XmuxConfig
+	return marshalWithUnknown(alias(x), x.Unknown)
+}
+
+func (d *XhttpDownloadSettings) UnmarshalJSON(data []byte) error {
+	type alias XhttpDownloadSettings
+	return unmarshalWithUnknown(data, (*alias)(d), &d.Unknown)
+}
+
+func (d XhttpDownloadSettings) MarshalJSON() ([]byte, error) {
+	type alias XhttpDownloadSettings
+	return marshalWithUnknown(alias(d), d.Unknown)
+}
+
+func (t *XhttpDownloadTransport) UnmarshalJSON(data []byte) error {
+	type alias XhttpDownloadTransport
+	return unmarshalWithUnknown(data, (*alias)(t), &t.Unknown)
+}
+
+func (t XhttpDownloadTransport) MarshalJSON() ([]byte, error) {
+	type alias XhttpDownloadTransport
+	return marshalWithUnknown(alias(t), t.Unknown)
+}
+
+// ParseXhttpExtra 解析并校验 XHTTP extra JSON
+func ParseXhttpExtra(s string) (*XhttpExtra, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(s))
+	var extra XhttpExtra
+	if err := dec.Decode(&extra); err != nil {
+		return nil, fmt.Errorf("xhttp extra: %v", describeJSONError(s, err))
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("xhttp extra: trailing data after JSON object")
+	}
+	if err := extra.validate("extra"); err != nil {
+		return nil, fmt.Errorf("xhttp %v", err)
+	}
+	return &extra, nil
+}
+
+// validate 校验 extra 取值
+func (e *XhttpExtra) validate(path string) error {
+	for k := range e.Headers {
+		if strings.EqualFold(k, "host") {
+			return fmt.Errorf("%s.headers: Host is not allowed, use the host parameter", path)
+		}
+	}
+
+	ranges := []struct {
+		name string
+		r    *Range
+	}{
+		{"xPaddingBytes", e.XPaddingBytes},
+		{"scMaxEachPostBytes", e.ScMaxEachPostBytes},
+		{"scMinPostsIntervalMs", e.ScMinPostsIntervalMs},
+		{"scStreamUpServerSecs", e.ScStreamUpServerSecs},
+	}
+	for _, item := range ranges {
+		if err := item.r.validate(path + "." + item.name); err != nil {
+			return err
+		}
+	}
+	if e.ScMaxBufferedPosts < 0 {
+		return fmt.Errorf("%s.scMaxBufferedPosts: must be >= 0", path)
+	}
+
+	if x := e.Xmux; x != nil {
+		xranges := []struct {
+			name string
+			r    *Range
+		}{
+			{"maxConcurrency", x.MaxConcurrency},
+			{"maxConnections", x.MaxConnections},
+			{"cMaxReuseTimes", x.CMaxReuseTimes},
+			{"hMaxRequestTimes", x.HMaxRequestTimes},
+			{"hMaxReusableSecs", x.HMaxReusableSecs},
+		}
+		for _, item := range xranges {
+			if err := item.r.validate(path + ".xmux." + item.name); err != nil {
+				return err
+			}
+		}
+		if x.MaxConcurrency != nil && x.MaxConnections != nil && x.MaxConcurrency.To > 0 && x.MaxConnections.To > 0 {
+			return fmt.Errorf("%s.xmux: maxConcurrency and maxConnections are mutually exclusive", path)
+		}
+	}
+
+	if d := e.DownloadSettings; d != nil {
+		dpath := path + ".downloadSettings"
+		if d.Address == "" {
+			return fmt.Errorf("%s: missing address", dpath)
+		}
+		if d.Port < 1 || d.Port > 65535 {
+			return fmt.Errorf("%s: invalid port %d", dpath, d.Port)
+		}
+		if d.Network != "" && d.Network != "xhttp" && d.Network != "splithttp" {
+			return fmt.Errorf("%s: network must be xhttp, got %q", dpath, d.Network)
+		}
+		switch d.Security {
+		case "", "none", "tls":
+		case "reality":
+			if len(d.RealitySettings) == 0 {
+				return fmt.Errorf("%s: security reality requires realitySettings", dpath)
+			}
+		default:
+			return fmt.Errorf("%s: unknown security %q", dpath, d.Security)
+		}
+		if t := d.XhttpSettings; t != nil && t.Extra != nil {
+			if t.Extra.DownloadSettings != nil {
+				return fmt.Errorf("%s.xhttpSettings.extra: nested downloadSettings is not allowed", dpath)
+			}
+			if err := t.Extra.validate(dpath + ".xhttpSettings.extra"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validate 校验区间
+func (r *Range) validate(name string) error {
+	if r == nil {
+		return nil
+	}
+	if r.From < 0 || r.To < 0 {
+		return fmt.Errorf("%s: negative value %s", name, r.String())
+	}
+	if r.From > r.To {
+		return fmt.Errorf("%s: min %d greater than max %d", name, r.From, r.To)
+	}
+	return nil
+}
+
+// describeJSONError 为 JSON 语法错误附加出错位置
+func describeJSONError(s string, err error) error {
+	if syntaxErr, ok := err.(*json.SyntaxError); ok {
+		return fmt.Errorf("invalid JSON at offset %d: %v", syntaxErr.Offset, err)
+	}
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+		return fmt.Errorf("field %s: want %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+	}
+	return err
+}
+
+// unmarshalWithUnknown 解析已知字段，其余字段保存到 unknown
+func unmarshalWithUnknown(data []byte, v interface{}, unknown *map[string]json.RawMessage) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	for _, name := range jsonFieldNames(v) {
+		delete(all, name)
+	}
+	if len(all) > 0 {
+		*unknown = all
+	} else {
+		*unknown = nil
+	}
+	return nil
+}
+
+// marshalWithUnknown 输出已知字段并合并 unknown 字段
+func marshalWithUnknown(v interface{}, unknown map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(unknown) == 0 {
+		return data, err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	for k, raw := range unknown {
+		if _, ok := all[k]; !ok {
+			all[k] = raw
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(all); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// jsonFieldNames 返回结构体的 JSON 字段名
+func jsonFieldNames(v interface{}) []string {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,236 @@
+package packages
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultPath 系统记录已安装应用及其 UID 的文件
+const DefaultPath = "/data/system/packages.list"
+
+// DefaultUsersDir 系统记录 Android 用户的目录 (0.xml、10.xml ...)
+const DefaultUsersDir = "/data/system/users"
+
+// PerUserRange 每个 Android 用户占用的 UID 区间，UID = userId*PerUserRange + appId
+const PerUserRange = 100000
+
+// Package packages.list 中的一行:
+// 包名 appId 可调试 数据目录 seinfo gids [profileable 版本号 ...]
+// 使用 sharedUserId 的应用共享同一个 appId
+type Package struct {
+	Name        string `json:"name"`
+	AppID       int    `json:"appId"`
+	Debuggable  bool   `json:"debuggable"`
+	DataDir     string `json:"dataDir,omitempty"`
+	SEInfo      string `json:"seinfo,omitempty"`
+	GIDs        []int  `json:"gids,omitempty"`
+	VersionCode int64  `json:"versionCode,omitempty"` // Android 10 起才有
+}
+
+// List 解析后的 packages.list，保持文件中的顺序
+type List struct {
+	Packages []*Package
+	byName   map[string]*Package
+	byAppID  map[int][]*Package
+}
+
+// Read 读取 packages.list
+func Read(path string) (*List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return list, nil
+}
+
+// Parse 解析 packages.list 内容，只要求包名和 appId，其余字段缺失时留空
+func Parse(r io.Reader) (*List, error) {
+	list := &List{byName: map[string]*Package{}, byAppID: map[int][]*Package{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("第 %d 行: 缺少 appId", line)
+		}
+		appID, err := strconv.Atoi(fields[1])
+		if err != nil || appID < 0 || appID >= PerUserRange {
+			return nil, fmt.Errorf("第 %d 行: 无效的 appId %q", line, fields[1])
+		}
+
+		p := &Package{Name: fields[0], AppID: appID}
+		if len(fields) > 2 {
+			p.Debuggable = fields[2] == "1"
+		}
+		if len(fields) > 3 {
+			p.DataDir = fields[3]
+		}
+		if len(fields) > 4 {
+			p.SEInfo = fields[4]
+		}
+		if len(fields) > 5 && fields[5] != "none" {
+			for _, g := range strings.Split(fields[5], ",") {
+				if gid, err := strconv.Atoi(g); err == nil {
+					p.GIDs = append(p.GIDs, gid)
+				}
+			}
+		}
+		if len(fields) > 7 {
+			p.VersionCode, _ = strconv.ParseInt(fields[7], 10, 64)
+		}
+
+		if _, ok := list.byName[p.Name]; ok {
+			continue
+		}
+		list.Packages = append(list.Packages, p)
+		list.byName[p.Name] = p
+		list.byAppID[appID] = append(list.byAppID[appID], p)
+	}
+	return list, scanner.Err()
+}
+
+// Lookup 按包名查找
+func (l *List) Lookup(name string) *Package {
+	return l.byName[name]
+}
+
+// ByAppID 返回使用该 appId 的全部应用 (共享 UID 时多于一个)
+func (l *List) ByAppID(appID int) []*Package {
+	return l.byAppID[appID]
+}
+
+// Shared 返回与 p 共享 UID 的其他应用
+func (l *List) Shared(p *Package) []string {
+	var names []string
+	for _, other := range l.byAppID[p.AppID] {
+		if other != p {
+			names = append(names, other.Name)
+		}
+	}
+	return names
+}
+
+// UID 计算用户 userID 下的 UID
+func UID(userID, appID int) int {
+	return userID*PerUserRange + appID
+}
+
+// SplitUID 将 UID 拆为用户 ID 和 appId
+func SplitUID(uid int) (userID, appID int) {
+	return uid / PerUserRange, uid % PerUserRange
+}
+
+// Resolution 一个应用写法的解析结果
+type Resolution struct {
+	Token      string   `json:"token"`
+	Package    string   `json:"package,omitempty"`
+	User       int      `json:"user"`
+	AppID      int      `json:"appId"`
+	UID        int      `json:"uid"`
+	SharedWith []string `json:"sharedWith,omitempty"` // 共享同一 UID 的其他应用，规则对它们同样生效
+	Error      string   `json:"error,omitempty"`
+}
+
+// ParseToken 拆分应用写法，写法与 tproxy.conf 的应用列表一致:
+// "包名" (用户 0)、"用户ID:包名" 或直接写 UID (此时 name 为空)
+func ParseToken(token string) (userID int, name string, uid int, err error) {
+	token = strings.TrimSpace(token)
+	if n, err := strconv.Atoi(token); err == nil {
+		if n < 0 {
+			return 0, "", 0, fmt.Errorf("无效的 UID: %s", token)
+		}
+		userID, _ = SplitUID(n)
+		return userID, "", n, nil
+	}
+	name = token
+	if i := strings.Index(token, ":"); i >= 0 {
+		u, err := strconv.Atoi(token[:i])
+		if err != nil || u < 0 {
+			return 0, "", 0, fmt.Errorf("无效的用户 ID: %s", token)
+		}
+		userID, name = u, token[i+1:]
+	}
+	if name == "" {
+		return 0, "", 0, fmt.Errorf("缺少包名: %s", token)
+	}
+	return userID, name, 0, nil
+}
+
+// ResolveToken 解析应用写法; 直接写 UID 时反查使用该 UID 的应用
+func (l *List) ResolveToken(token string) Resolution {
+	r := Resolution{Token: token}
+	userID, name, uid, err := ParseToken(token)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	if name == "" {
+		r.User, r.AppID = SplitUID(uid)
+		r.UID = uid
+		if owners := l.byAppID[r.AppID]; len(owners) > 0 {
+			r.Package = owners[0].Name
+			r.SharedWith = l.Shared(owners[0])
+		}
+		return r
+	}
+
+	p := l.byName[name]
+	if p == nil {
+		r.Package = name
+		r.Error = fmt.Sprintf("未安装的应用: %s", name)
+		return r
+	}
+	r.Package, r.User, r.AppID = p.Name, userID, p.AppID
+	r.UID = UID(userID, p.AppID)
+	r.SharedWith = l.Shared(p)
+	return r
+}
+
+// Resolve 将应用写法解析为 UID
+func (l *List) Resolve(token string) (int, error) {
+	r := l.ResolveToken(token)
+	if r.Error != "" {
+		return 0, errors.New(r.Error)
+	}
+	return r.UID, nil
+}
+
+// ReadUsers 列出设备上的 Android 用户 ID (dir 下以数字命名的 .xml 文件或目录)
+// 目录不存在时只返回用户 0
+func ReadUsers(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []int{0}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	seen := map[int]bool{0: true}
+	users := []int{0}
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".xml")
+		if id, err := strconv.Atoi(name); err == nil && id >= 0 && !seen[id] {
+			seen[id] = true
+			users = append(users, id)
+		}
+	}
+	sort.Ints(users)
+	return users, nil
+}
@@ -0,0 +1,123 @@
+package packages
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T) *List {
+	t.Helper()
+	list, err := Read("testdata/packages.list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestParseFixture(t *testing.T) {
+	list := readFixture(t)
+	if got := len(list.Packages); got != 6 {
+		t.Fatalf("len(Packages) = %d, want 6", got)
+	}
+
+	termux := list.Lookup("com.termux")
+	if termux == nil {
+		t.Fatal("com.termux not found")
+	}
+	want := &Package{
+		Name:        "com.termux",
+		AppID:       10245,
+		Debuggable:  true,
+		DataDir:     "/data/user/0/com.termux",
+		SEInfo:      "default:targetSdkVersion=28",
+		VersionCode: 118,
+	}
+	if !reflect.DeepEqual(termux, want) {
+		t.Errorf("com.termux = %+v, want %+v", termux, want)
+	}
+	if got := list.Lookup("com.android.systemui").GIDs; !reflect.DeepEqual(got, []int{1065, 3002, 1023, 3003}) {
+		t.Errorf("systemui GIDs = %v", got)
+	}
+
+	// 只有包名和 appId 的旧格式
+	legacy := list.Lookup("com.example.legacy")
+	if legacy == nil || legacy.AppID != 10300 || legacy.DataDir != "" || legacy.VersionCode != 0 {
+		t.Errorf("com.example.legacy = %+v", legacy)
+	}
+}
+
+func TestSharedUID(t *testing.T) {
+	list := readFixture(t)
+	if got := len(list.ByAppID(1000)); got != 2 {
+		t.Errorf("len(ByAppID(1000)) = %d, want 2", got)
+	}
+	if got := list.Shared(list.Lookup("com.android.settings")); !reflect.DeepEqual(got, []string{"com.android.systemui"}) {
+		t.Errorf("Shared(settings) = %v", got)
+	}
+	if got := list.Shared(list.Lookup("com.termux")); got != nil {
+		t.Errorf("Shared(termux) = %v, want none", got)
+	}
+}
+
+func TestResolveToken(t *testing.T) {
+	list := readFixture(t)
+	tests := []struct {
+		token string
+		want  Resolution
+	}{
+		{"org.telegram.messenger", Resolution{Package: "org.telegram.messenger", AppID: 10210, UID: 10210}},
+		{"10:org.telegram.messenger", Resolution{Package: "org.telegram.messenger", User: 10, AppID: 10210, UID: 1010210}},
+		{"com.android.settings", Resolution{Package: "com.android.settings", AppID: 1000, UID: 1000, SharedWith: []string{"com.android.systemui"}}},
+		{"1010245", Resolution{Package: "com.termux", User: 10, AppID: 10245, UID: 1010245}},
+		{"1000", Resolution{Package: "com.android.systemui", AppID: 1000, UID: 1000, SharedWith: []string{"com.android.settings"}}},
+		{"10999", Resolution{AppID: 10999, UID: 10999}},
+		{"com.missing", Resolution{Package: "com.missing", Error: "未安装的应用: com.missing"}},
+		{"x:com.termux", Resolution{Error: "无效的用户 ID: x:com.termux"}},
+		{"10:", Resolution{Error: "缺少包名: 10:"}},
+		{"-1", Resolution{Error: "无效的 UID: -1"}},
+	}
+	for _, tt := range tests {
+		tt.want.Token = tt.token
+		if got := list.ResolveToken(tt.token); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ResolveToken(%q) = %+v, want %+v", tt.token, got, tt.want)
+		}
+	}
+
+	if uid, err := list.Resolve("10:com.termux"); err != nil || uid != 1010245 {
+		t.Errorf("Resolve(10:com.termux) = %d, %v", uid, err)
+	}
+	if _, err := list.Resolve("com.missing"); err == nil {
+		t.Error("Resolve(com.missing) succeeded")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"com.example\n", "第 1 行: 缺少 appId"},
+		{"com.a 10001\n\ncom.b\n", "第 3 行: 缺少 appId"},
+		{"com.example 100000\n", `第 1 行: 无效的 appId "100000"`},
+		{"com.example 1010245\n", `第 1 行: 无效的 appId "1010245"`},
+		{"com.example -1\n", `第 1 行: 无效的 appId "-1"`},
+		{"com.example abc\n", `第 1 行: 无效的 appId "abc"`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.input))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Parse(%q) error = %v, want %s", tt.input, err, tt.want)
+		}
+	}
+}
+
+func TestParseDuplicate(t *testing.T) {
+	list, err := Parse(strings.NewReader("com.example 10001\ncom.example 10002\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Packages) != 1 || list.Lookup("com.example").AppID != 10001 {
+		t.Errorf("duplicate package: %+v", list.Packages)
+	}
+}
@@ -1,14 +1,62 @@
 package parser
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"proxylink/pkg/keys"
 	"proxylink/pkg/model"
 	"proxylink/pkg/util"
 )
 
+// parseUserInfo 解析 userinfo 中的用户名和密码
+// 支持 user:pass 明文格式和 v2rayN 风格的 base64(user:pass) 格式
+func parseUserInfo(u *url.URL) (username, password string) {
+	if u.User == nil {
+		return "", ""
+	}
+
+	username = u.User.Username()
+	if pwd, ok := u.User.Password(); ok {
+		return username, pwd
+	}
+
+	// 整体编码的 user:pass (如 ToSocksURI 生成的链接)
+	if parts := strings.SplitN(username, ":", 2); len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+
+	// 无密码时尝试 Base64 解码
+	if decoded, err := util.Base64Decode(username); err == nil && isPrintable(decoded) {
+		if parts := strings.SplitN(decoded, ":", 2); len(parts) == 2 {
+			return parts[0], parts[1]
+		}
+	}
+
+	return username, ""
+}
+
+// isPrintable 检查字符串是否为可打印的 UTF-8 文本
+func isPrintable(s string) bool {
+	if s == "" || !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
 // parseQueryParams 解析通用的查询参数到 ProfileItem
-func parseQueryParams(config *model.ProfileItem, query url.Values) {
+// defaultSecurity 为没有 security 参数时使用的安全层 (Trojan 为 tls)，在解析 TLS 扩展参数之前生效
+func parseQueryParams(config *model.ProfileItem, query url.Values, defaultSecurity string) error {
 	// 传输层
 	config.Network = query.Get("type")
 	if config.Network == "" {
@@ -26,9 +74,15 @@ func parseQueryParams(config *model.ProfileItem, query url.Values) {
 	config.Authority = query.Get("authority")
 	config.XhttpMode = query.Get("mode")
 	config.XhttpExtra = query.Get("extra")
+	if err := normalizeXhttp(config); err != nil {
+		return err
+	}
 
 	// TLS
 	config.Security = query.Get("security")
+	if config.Security == "" {
+		config.Security = defaultSecurity
+	}
 	if config.Security != "tls" && config.Security != "reality" {
 		config.Security = ""
 	}
@@ -37,7 +91,6 @@ func parseQueryParams(config *model.ProfileItem, query url.Values) {
 	config.ALPN = query.Get("alpn")
 	config.Fingerprint = query.Get("fp")
 	config.Flow = query.Get("flow")
-	config.Mldsa65Verify = query.Get("pqv")
 
 	// Insecure - 支持多种参数名
 	insecure := query.Get("insecure")
@@ -49,10 +102,58 @@ func parseQueryParams(config *model.ProfileItem, query url.Values) {
 	}
 	config.Insecure = insecure == "1"
 
+	if err := parseTLSOptions(config, query); err != nil {
+		return err
+	}
+
 	// Reality
 	config.PublicKey = query.Get("pbk")
 	config.ShortID = query.Get("sid")
 	config.SpiderX = query.Get("spx")
+
+	if config.Security == "reality" {
+		if config.PublicKey == "" {
+			return errors.New("reality: missing public key (pbk)")
+		}
+		pbk, err := keys.NormalizeReality(config.PublicKey)
+		if err != nil {
+			return fmt.Errorf("reality: public key: %v", err)
+		}
+		config.PublicKey = pbk
+
+		pqv, err := NormalizeMldsa65Verify(query.Get("pqv"))
+		if err != nil {
+			return fmt.Errorf("reality: %v", err)
+		}
+		config.Mldsa65Verify = pqv
+	}
+
+	return nil
+}
+
+// normalizeXhttp 统一 splithttp 别名并校验、压缩 XHTTP extra
+func normalizeXhttp(config *model.ProfileItem) error {
+	if config.Network == "splithttp" {
+		config.Network = "xhttp"
+	}
+	if config.Network != "xhttp" || config.XhttpExtra == "" {
+		return nil
+	}
+
+	extra, err := model.ParseXhttpExtra(config.XhttpExtra)
+	if err != nil {
+		return err
+	}
+	if extra == nil {
+		config.XhttpExtra = ""
+		return nil
+	}
+	data, err := json.Marshal(extra)
+	if err != nil {
+		return fmt.Errorf("xhttp extra: %v", err)
+	}
+	config.XhttpExtra = string(data)
+	return nil
 }
 
 // buildQueryParams 从 ProfileItem 构建查询参数
@@ -83,13 +184,10 @@ func buildQueryParams(config *model.ProfileItem) url.Values {
 		query.Set("pqv", config.Mldsa65Verify)
 	}
 
-	// Insecure
+	// Insecure 与 TLS 扩展参数
 	if config.Security == "tls" {
-		if config.Insecure {
-			query.Set("allowInsecure", "1")
-		} else {
-			query.Set("allowInsecure", "0")
-		}
+		setInsecure(config, query)
+		setTLSOptions(config, query)
 	}
 
 	// Reality
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"net/url"
+	"strings"
 
 	"proxylink/pkg/model"
 	"proxylink/pkg/util"
@@ -9,6 +10,7 @@ import (
 
 // ParseHTTP 解析 HTTP 代理链接
 // 格式: http://[user:pass@]server:port#remarks
+// 或: https://[user:pass@]server:port?sni=xxx&allowInsecure=1#remarks (TLS)
 func ParseHTTP(uri string) (*model.ProfileItem, error) {
 	u, err := url.Parse(util.FixIllegalURL(uri))
 	if err != nil {
@@ -26,10 +28,28 @@ func ParseHTTP(uri string) (*model.ProfileItem, error) {
 	config.ServerPort = u.Port()
 
 	// 认证信息
-	if u.User != nil {
-		config.Username = u.User.Username()
-		if pwd, ok := u.User.Password(); ok {
-			config.Password = pwd
+	config.Username, config.Password = parseUserInfo(u)
+
+	// HTTPS 代理
+	if strings.EqualFold(u.Scheme, "https") {
+		config.Security = "tls"
+		if config.ServerPort == "" {
+			config.ServerPort = "443"
+		}
+
+		query := u.Query()
+		config.SNI = query.Get("sni")
+		config.ALPN = query.Get("alpn")
+		config.Fingerprint = query.Get("fp")
+
+		insecure := query.Get("insecure")
+		if insecure == "" {
+			insecure = query.Get("allowInsecure")
+		}
+		config.Insecure = insecure == "1"
+
+		if err := parseTLSOptions(config, query); err != nil {
+			return nil, err
 		}
 	}
 
@@ -46,15 +66,39 @@ func ToHTTPURI(config *model.ProfileItem) string {
 		}
 	}
 
+	scheme := "http://"
+	query := url.Values{}
+	if config.Security == "tls" {
+		scheme = "https://"
+		if config.SNI != "" {
+			query.Set("sni", config.SNI)
+		}
+		if config.ALPN != "" {
+			query.Set("alpn", config.ALPN)
+		}
+		if config.Fingerprint != "" {
+			query.Set("fp", config.Fingerprint)
+		}
+		if config.Insecure && config.PinnedPeerCertSha256 == "" {
+			query.Set("allowInsecure", "1")
+		}
+		setTLSOptions(config, query)
+	}
+
 	host := util.GetIPv6Address(config.Server) + ":" + config.ServerPort
 
+	queryStr := ""
+	if len(query) > 0 {
+		queryStr = "?" + query.Encode()
+	}
+
 	remarks := ""
 	if config.Remarks != "" {
 		remarks = "#" + util.URLEncode(config.Remarks)
 	}
 
 	if userInfo != "" {
-		return "http://" + util.URLEncode(userInfo) + "@" + host + remarks
+		return scheme + util.URLEncode(userInfo) + "@" + host + queryStr + remarks
 	}
-	return "http://" + host + remarks
+	return scheme + host + queryStr + remarks
 }
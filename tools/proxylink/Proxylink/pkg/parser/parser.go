@@ -23,9 +23,9 @@ func Parse(uri string) (*model.ProfileItem, error) {
 		return ParseShadowsocks(uri)
 	case strings.HasPrefix(uri, "trojan://"):
 		return ParseTrojan(uri)
-	case strings.HasPrefix(uri, "socks://"):
+	case strings.HasPrefix(uri, "socks://"), strings.HasPrefix(uri, "socks5://"), strings.HasPrefix(uri, "socks5h://"):
 		return ParseSocks(uri)
-	case strings.HasPrefix(uri, "http://"):
+	case strings.HasPrefix(uri, "http://"), strings.HasPrefix(uri, "https://"):
 		return ParseHTTP(uri)
 	case strings.HasPrefix(uri, "wireguard://"), strings.HasPrefix(uri, "wg://"):
 		return ParseWireGuard(uri)
@@ -0,0 +1,182 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"proxylink/pkg/model"
+)
+
+// TLS 扩展参数名
+// ech: ECHConfigList (Base64) 或 "域名+DNS 服务器" 形式的查询
+// pcs: 证书链 SHA256 (十六进制或 Base64，逗号分隔)
+// vcn: verifyPeerCertByName
+// minVersion/maxVersion: TLS 版本 (1.0-1.3)
+// dsr: disableSystemRoot
+const (
+	paramECH               = "ech"
+	paramPinnedCertSha256  = "pcs"
+	paramVerifyCertByName  = "vcn"
+	paramMinVersion        = "minVersion"
+	paramMaxVersion        = "maxVersion"
+	paramDisableSystemRoot = "dsr"
+)
+
+// tlsVersions 支持的 TLS 版本，按先后排列
+var tlsVersions = []string{"1.0", "1.1", "1.2", "1.3"}
+
+// parseTLSOptions 解析 TLS 扩展参数
+func parseTLSOptions(config *model.ProfileItem, query url.Values) error {
+	if config.Security != "tls" {
+		return nil
+	}
+
+	ech := strings.TrimSpace(query.Get(paramECH))
+	if ech != "" {
+		if err := validateECHConfigList(ech); err != nil {
+			return err
+		}
+		config.ECHConfigList = ech
+	}
+
+	pins, err := NormalizeCertSha256List(query.Get(paramPinnedCertSha256))
+	if err != nil {
+		return err
+	}
+	config.PinnedPeerCertSha256 = pins
+
+	config.VerifyPeerCertByName = strings.TrimSpace(query.Get(paramVerifyCertByName))
+
+	if config.MinVersion, err = normalizeTLSVersion(query.Get(paramMinVersion)); err != nil {
+		return fmt.Errorf("tls: minVersion: %v", err)
+	}
+	if config.MaxVersion, err = normalizeTLSVersion(query.Get(paramMaxVersion)); err != nil {
+		return fmt.Errorf("tls: maxVersion: %v", err)
+	}
+	if config.MinVersion != "" && config.MaxVersion != "" && tlsVersionIndex(config.MinVersion) > tlsVersionIndex(config.MaxVersion) {
+		return fmt.Errorf("tls: minVersion %s greater than maxVersion %s", config.MinVersion, config.MaxVersion)
+	}
+
+	config.DisableSystemRoot = query.Get(paramDisableSystemRoot) == "1"
+	if config.DisableSystemRoot && config.PinnedPeerCertSha256 == "" {
+		return fmt.Errorf("tls: disableSystemRoot requires pinned certificates (pcs)")
+	}
+
+	return nil
+}
+
+// setTLSOptions 写入 TLS 扩展参数
+func setTLSOptions(config *model.ProfileItem, query url.Values) {
+	if config.Security != "tls" {
+		return
+	}
+	if config.ECHConfigList != "" {
+		query.Set(paramECH, config.ECHConfigList)
+	}
+	if config.PinnedPeerCertSha256 != "" {
+		query.Set(paramPinnedCertSha256, config.PinnedPeerCertSha256)
+	}
+	if config.VerifyPeerCertByName != "" {
+		query.Set(paramVerifyCertByName, config.VerifyPeerCertByName)
+	}
+	if config.MinVersion != "" {
+		query.Set(paramMinVersion, config.MinVersion)
+	}
+	if config.MaxVersion != "" {
+		query.Set(paramMaxVersion, config.MaxVersion)
+	}
+	if config.DisableSystemRoot {
+		query.Set(paramDisableSystemRoot, "1")
+	}
+}
+
+// setInsecure 写入 allowInsecure
+// 已固定证书时证书校验由 pcs 完成，不再输出 allowInsecure
+func setInsecure(config *model.ProfileItem, query url.Values) {
+	if config.PinnedPeerCertSha256 != "" {
+		return
+	}
+	if config.Insecure {
+		query.Set("allowInsecure", "1")
+	} else {
+		query.Set("allowInsecure", "0")
+	}
+}
+
+// NormalizeCertSha256List 规范化证书 SHA256 列表
+// 接受十六进制 (可带冒号) 或 Base64，统一输出十六进制小写，逗号分隔
+func NormalizeCertSha256List(s string) (string, error) {
+	var pins []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		sum, err := decodeCertSha256(item)
+		if err != nil {
+			return "", fmt.Errorf("tls: pinned certificate %q: %v", item, err)
+		}
+		pins = append(pins, hex.EncodeToString(sum))
+	}
+	return strings.Join(pins, ","), nil
+}
+
+// decodeCertSha256 解码单个证书哈希
+func decodeCertSha256(s string) ([]byte, error) {
+	h := strings.ReplaceAll(s, ":", "")
+	if len(h) == sha256.Size*2 {
+		if b, err := hex.DecodeString(h); err == nil {
+			return b, nil
+		}
+	}
+	if b, err := decodeBase64Blob(s); err == nil {
+		if len(b) != sha256.Size {
+			return nil, fmt.Errorf("want %d bytes, got %d", sha256.Size, len(b))
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("not a hex or base64 SHA256 digest")
+}
+
+// validateECHConfigList 校验 ech 参数
+// DNS 查询形式 (含 "://") 原样保留，其余必须是 Base64 ECHConfigList
+func validateECHConfigList(s string) error {
+	if strings.Contains(s, "://") {
+		return nil
+	}
+	b, err := decodeBase64Blob(s)
+	if err != nil {
+		return fmt.Errorf("tls: ech: invalid base64 ECHConfigList")
+	}
+	// ECHConfigList 以 2 字节长度开头
+	if len(b) < 2 || int(b[0])<<8|int(b[1]) != len(b)-2 {
+		return fmt.Errorf("tls: ech: malformed ECHConfigList (length mismatch)")
+	}
+	return nil
+}
+
+// normalizeTLSVersion 规范化 TLS 版本号 (1.2 / tls1.2 / TLSv1.2)
+func normalizeTLSVersion(s string) (string, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	if v == "" {
+		return "", nil
+	}
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "tls"), "v")
+	if tlsVersionIndex(v) < 0 {
+		return "", fmt.Errorf("unsupported TLS version %q", s)
+	}
+	return v, nil
+}
+
+// tlsVersionIndex 返回版本在 tlsVersions 中的位置
+func tlsVersionIndex(v string) int {
+	for i, known := range tlsVersions {
+		if known == v {
+			return i
+		}
+	}
+	return -1
+}
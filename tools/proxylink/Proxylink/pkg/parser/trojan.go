@@ -33,14 +33,12 @@ func ParseTrojan(uri string) (*model.ProfileItem, error) {
 	// 解析查询参数
 	if u.RawQuery != "" {
 		query := u.Query()
-		parseQueryParams(config, query)
-
-		// Trojan 特殊处理：如果没有 security 参数，默认 tls
-		security := query.Get("security")
-		if security != "" {
+		// Trojan 特殊处理：如果没有 security 参数，默认 tls (TLS 扩展参数同样生效)
+		if err := parseQueryParams(config, query, "tls"); err != nil {
+			return nil, err
+		}
+		if security := query.Get("security"); security != "" {
 			config.Security = security
-		} else {
-			config.Security = "tls"
 		}
 	}
 
@@ -0,0 +1,30 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTrojanDefaultTLSOptions(t *testing.T) {
+	pin := strings.Repeat("ab", 32)
+	for _, query := range []string{"", "security=tls&"} {
+		uri := "trojan://pass@example.com:443?" + query + "sni=example.com&pcs=" + pin + "&vcn=cert.example.com&minVersion=1.2&maxVersion=1.3&dsr=1#node"
+		config, err := ParseTrojan(uri)
+		if err != nil {
+			t.Fatalf("ParseTrojan(%q): %v", uri, err)
+		}
+		if config.Security != "tls" {
+			t.Errorf("%q: Security = %q, want tls", uri, config.Security)
+		}
+		if config.PinnedPeerCertSha256 != pin || config.VerifyPeerCertByName != "cert.example.com" ||
+			config.MinVersion != "1.2" || config.MaxVersion != "1.3" || !config.DisableSystemRoot {
+			t.Errorf("%q: TLS options dropped: pcs=%q vcn=%q min=%q max=%q dsr=%v", uri,
+				config.PinnedPeerCertSha256, config.VerifyPeerCertByName, config.MinVersion, config.MaxVersion, config.DisableSystemRoot)
+		}
+	}
+
+	// 不带 security 时同样校验 TLS 参数
+	if _, err := ParseTrojan("trojan://pass@example.com:443?minVersion=1.3&maxVersion=1.2#node"); err == nil {
+		t.Error("minVersion > maxVersion accepted without security=")
+	}
+}
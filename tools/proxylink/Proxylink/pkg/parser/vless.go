@@ -31,13 +31,15 @@ func ParseVLess(uri string) (*model.ProfileItem, error) {
 
 	// 加密方式
 	query := u.Query()
-	config.Method = query.Get("encryption")
-	if config.Method == "" {
-		config.Method = "none"
+	config.Method, config.VLessEncryption, err = ParseVLessEncryption(query.Get("encryption"))
+	if err != nil {
+		return nil, err
 	}
 
 	// 解析通用参数
-	parseQueryParams(config, query)
+	if err := parseQueryParams(config, query, ""); err != nil {
+		return nil, err
+	}
 
 	return config, nil
 }
@@ -71,6 +73,11 @@ type VmessQRCode struct {
 	Alpn     string `json:"alpn"`
 	Fp       string `json:"fp"`
 	Insecure string `json:"insecure"`
+	ECH      string `json:"ech,omitempty"`
+	PCS      string `json:"pcs,omitempty"`
+	VCN      string `json:"vcn,omitempty"`
+	// Extra XHTTP extra，兼容字符串和对象两种写法
+	Extra json.RawMessage `json:"extra,omitempty"`
 }
 
 // ParseVMess 解析 VMess 链接
@@ -139,6 +146,13 @@ func parseVMessJSON(jsonStr string) (*model.ProfileItem, error) {
 		config.Mode = qr.Type
 		config.ServiceName = qr.Path
 		config.Authority = qr.Host
+	case "xhttp", "splithttp":
+		config.HeaderType = ""
+		config.XhttpMode = qr.Type
+		config.XhttpExtra = vmessExtraString(qr.Extra)
+	}
+	if err := normalizeXhttp(config); err != nil {
+		return nil, err
 	}
 
 	// TLS
@@ -150,9 +164,30 @@ func parseVMessJSON(jsonStr string) (*model.ProfileItem, error) {
 	config.ALPN = qr.Alpn
 	config.Insecure = qr.Insecure == "1"
 
+	// TLS 扩展参数复用 URI 参数解析
+	tlsQuery := url.Values{}
+	tlsQuery.Set(paramECH, qr.ECH)
+	tlsQuery.Set(paramPinnedCertSha256, qr.PCS)
+	tlsQuery.Set(paramVerifyCertByName, qr.VCN)
+	if err := parseTLSOptions(config, tlsQuery); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
+// vmessExtraString 将 extra 字段统一为 JSON 文本
+func vmessExtraString(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
 // parseVMessJSONManual 手动解析 VMess JSON (兼容格式)
 func parseVMessJSONManual(jsonStr string) (*model.ProfileItem, error) {
 	config := model.NewProfileItem(model.VMESS)
@@ -208,7 +243,9 @@ func parseVMessStd(uri string) (*model.ProfileItem, error) {
 	config.Method = "auto"
 
 	query := u.Query()
-	parseQueryParams(config, query)
+	if err := parseQueryParams(config, query, ""); err != nil {
+		return nil, err
+	}
 
 	return config, nil
 }
@@ -272,9 +309,14 @@ func ToVMessURI(config *model.ProfileItem) string {
 	if config.Security == "tls" {
 		qr.TLS = "tls"
 	}
-	if config.Insecure {
+	if config.Insecure && config.PinnedPeerCertSha256 == "" {
 		qr.Insecure = "1"
 	}
+	if config.Security == "tls" {
+		qr.ECH = config.ECHConfigList
+		qr.PCS = config.PinnedPeerCertSha256
+		qr.VCN = config.VerifyPeerCertByName
+	}
 
 	// 特殊网络处理
 	switch config.Network {
@@ -284,6 +326,11 @@ func ToVMessURI(config *model.ProfileItem) string {
 		qr.Type = config.Mode
 		qr.Path = config.ServiceName
 		qr.Host = config.Authority
+	case "xhttp":
+		qr.Type = config.XhttpMode
+		if config.XhttpExtra != "" {
+			qr.Extra, _ = json.Marshal(config.XhttpExtra)
+		}
 	}
 
 	jsonBytes, _ := json.Marshal(qr)
@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"proxylink/pkg/model"
+)
+
+const (
+	vlessEncMethod = "mlkem768x25519plus"
+
+	x25519KeySize        = 32   // X25519 公钥
+	mlkem768ClientSize   = 1184 // ML-KEM-768 封装密钥 (客户端)
+	mlkem768SeedSize     = 64   // ML-KEM-768 种子 (服务端私钥)
+	mldsa65PublicKeySize = 1952 // ML-DSA-65 公钥
+	mldsa65SeedSize      = 32   // ML-DSA-65 种子 (服务端私钥)
+)
+
+var (
+	vlessEncPaddingRegex = regexp.MustCompile(`^\d+(-\d+){1,2}$`)
+	vlessEncTicketRegex  = regexp.MustCompile(`^\d+(-\d+)?s$`)
+)
+
+// ParseVLessEncryption 解析并校验 VLESS encryption 描述
+// 格式: mlkem768x25519plus.<native|xorpub|random>.<0rtt|1rtt>[.padding...].<key>[.key...]
+// 返回规范化后的描述字符串 (密钥统一为 URL 安全无 padding 的 Base64)
+func ParseVLessEncryption(s string) (string, *model.VLessEncryption, error) {
+	if t := strings.TrimSpace(s); t == "" || t == "none" {
+		return "none", nil, nil
+	}
+
+	// 清理被换行打断的长字符串 (空格可能是被解码的 '+'，由 decodeBase64Blob 还原)
+	s = strings.TrimLeft(stripLineBreaks(s), " ")
+
+	parts := strings.Split(s, ".")
+	if parts[0] != vlessEncMethod {
+		return "", nil, fmt.Errorf("vless encryption: unsupported method %q", parts[0])
+	}
+	if len(parts) < 4 {
+		return "", nil, fmt.Errorf("vless encryption: need at least method.mode.rtt.key, got %d parts", len(parts))
+	}
+
+	enc := &model.VLessEncryption{
+		Method: parts[0],
+		Mode:   parts[1],
+		RTT:    parts[2],
+	}
+
+	switch enc.Mode {
+	case "native", "xorpub", "random":
+	default:
+		return "", nil, fmt.Errorf("vless encryption: unknown mode %q (want native, xorpub or random)", enc.Mode)
+	}
+
+	switch {
+	case enc.RTT == "0rtt" || enc.RTT == "1rtt":
+	case vlessEncTicketRegex.MatchString(enc.RTT):
+		return "", nil, fmt.Errorf("vless encryption: %q is a server-side ticket lifetime, not a client descriptor", enc.RTT)
+	default:
+		return "", nil, fmt.Errorf("vless encryption: unknown rtt %q (want 0rtt or 1rtt)", enc.RTT)
+	}
+
+	normalized := []string{enc.Method, enc.Mode, enc.RTT}
+
+	for i, part := range parts[3:] {
+		// 填充块必须位于密钥之前
+		if vlessEncPaddingRegex.MatchString(part) {
+			if len(enc.Keys) > 0 {
+				return "", nil, fmt.Errorf("vless encryption: padding %q after keys", part)
+			}
+			enc.Padding = append(enc.Padding, part)
+			normalized = append(normalized, part)
+			continue
+		}
+
+		key, err := decodeBase64Blob(part)
+		if err != nil {
+			return "", nil, fmt.Errorf("vless encryption: segment %d is neither padding nor base64 key", i+4)
+		}
+
+		switch len(key) {
+		case x25519KeySize:
+			enc.Keys = append(enc.Keys, model.VLessEncryptionKey{Type: "x25519", Size: len(key)})
+		case mlkem768ClientSize:
+			enc.Keys = append(enc.Keys, model.VLessEncryptionKey{Type: "mlkem768", Size: len(key)})
+		case mlkem768SeedSize:
+			return "", nil, fmt.Errorf("vless encryption: key %d is a 64-byte ML-KEM-768 seed (server private key)", len(enc.Keys)+1)
+		default:
+			return "", nil, fmt.Errorf("vless encryption: key %d has invalid length %d bytes (want %d or %d)",
+				len(enc.Keys)+1, len(key), x25519KeySize, mlkem768ClientSize)
+		}
+		normalized = append(normalized, base64.RawURLEncoding.EncodeToString(key))
+	}
+
+	if len(enc.Keys) == 0 {
+		return "", nil, fmt.Errorf("vless encryption: missing client key")
+	}
+
+	return strings.Join(normalized, "."), enc, nil
+}
+
+// NormalizeMldsa65Verify 校验并规范化 Reality pqv (ML-DSA-65 公钥)
+func NormalizeMldsa65Verify(s string) (string, error) {
+	s = stripLineBreaks(s)
+	if strings.TrimSpace(s) == "" {
+		return "", nil
+	}
+
+	key, err := decodeBase64Blob(s)
+	if err != nil {
+		return "", fmt.Errorf("pqv: invalid base64")
+	}
+
+	switch len(key) {
+	case mldsa65PublicKeySize:
+		return base64.RawURLEncoding.EncodeToString(key), nil
+	case mldsa65SeedSize:
+		return "", fmt.Errorf("pqv: got a 32-byte ML-DSA-65 seed (server private key), want the %d-byte verify key", mldsa65PublicKeySize)
+	default:
+		return "", fmt.Errorf("pqv: invalid length %d bytes, want %d", len(key), mldsa65PublicKeySize)
+	}
+}
+
+// lineBreakStripper 删除换行和制表符，保留空格
+var lineBreakStripper = strings.NewReplacer("\r", "", "\n", "", "\t", "")
+
+// stripLineBreaks 删除长字符串中的换行和制表符
+// 不能删除空格: 查询参数会把 Base64 中的 '+' 解码为空格
+func stripLineBreaks(s string) string {
+	return lineBreakStripper.Replace(s)
+}
+
+// decodeBase64Blob 解码任意 Base64 变体，查询参数中被解码为空格的 '+' 会被还原
+func decodeBase64Blob(s string) ([]byte, error) {
+	s = strings.ReplaceAll(s, " ", "+")
+	encodings := []*base64.Encoding{
+		base64.RawURLEncoding,
+		base64.URLEncoding,
+		base64.RawStdEncoding,
+		base64.StdEncoding,
+	}
+	var err error
+	for _, enc := range encodings {
+		var b []byte
+		if b, err = enc.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	return nil, err
+}
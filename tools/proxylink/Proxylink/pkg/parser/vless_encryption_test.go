@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// queryDecoded 模拟 URL 查询参数解码: 未转义的 '+' 变成空格
+func queryDecoded(t *testing.T, value string) string {
+	t.Helper()
+	query, err := url.ParseQuery("v=" + value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return query.Get("v")
+}
+
+func TestParseVLessEncryptionPlus(t *testing.T) {
+	key := bytes.Repeat([]byte{0xfb, 0xef, 0xbe}, 11)[:x25519KeySize]
+	std := base64.StdEncoding.EncodeToString(key)
+	if !strings.Contains(std, "+") {
+		t.Fatalf("test key %s has no '+'", std)
+	}
+	want := "mlkem768x25519plus.native.0rtt." + base64.RawURLEncoding.EncodeToString(key)
+
+	for _, input := range []string{
+		"mlkem768x25519plus.native.0rtt." + std,
+		queryDecoded(t, "mlkem768x25519plus.native.0rtt."+std),
+		"mlkem768x25519plus.native.0rtt." + std[:20] + "\r\n" + std[20:],
+	} {
+		got, enc, err := ParseVLessEncryption(input)
+		if err != nil {
+			t.Errorf("ParseVLessEncryption(%q): %v", input, err)
+			continue
+		}
+		if got != want || len(enc.Keys) != 1 || enc.Keys[0].Type != "x25519" {
+			t.Errorf("ParseVLessEncryption(%q) = %q, %+v; want %q", input, got, enc, want)
+		}
+	}
+}
+
+func TestNormalizeMldsa65VerifyPlus(t *testing.T) {
+	key := bytes.Repeat([]byte{0xfb, 0xef, 0xbe}, mldsa65PublicKeySize/3+1)[:mldsa65PublicKeySize]
+	std := base64.StdEncoding.EncodeToString(key)
+	want := base64.RawURLEncoding.EncodeToString(key)
+
+	for _, input := range []string{std, queryDecoded(t, std), std[:64] + "\n" + std[64:]} {
+		got, err := NormalizeMldsa65Verify(input)
+		if err != nil || got != want {
+			t.Errorf("NormalizeMldsa65Verify(%.20q...) = %.20q..., %v", input, got, err)
+		}
+	}
+
+	if _, err := NormalizeMldsa65Verify(base64.StdEncoding.EncodeToString(key[:mldsa65SeedSize])); err == nil {
+		t.Error("32-byte seed accepted as pqv")
+	}
+}
@@ -1,11 +1,15 @@
 package parser
 
 import (
+	"errors"
+	"fmt"
+	"net"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"proxylink/pkg/keys"
 	"proxylink/pkg/model"
 	"proxylink/pkg/util"
 )
@@ -42,12 +46,19 @@ func ParseWireGuard(uri string) (*model.ProfileItem, error) {
 	if config.LocalAddress == "" {
 		config.LocalAddress = "10.0.0.2/32" // 默认值
 	}
-	config.Reserved = query.Get("reserved")
+	config.Reserved, err = util.NormalizeReserved(query.Get("reserved"))
+	if err != nil {
+		return nil, err
+	}
 	if config.Reserved == "" {
 		config.Reserved = "0,0,0" // 默认值
 	}
 	config.PreSharedKey = query.Get("presharedkey")
 
+	if err := normalizeWireGuardKeys(config); err != nil {
+		return nil, err
+	}
+
 	// MTU
 	if mtuStr := query.Get("mtu"); mtuStr != "" {
 		if mtu, err := strconv.Atoi(mtuStr); err == nil {
@@ -60,88 +71,146 @@ func ParseWireGuard(uri string) (*model.ProfileItem, error) {
 	return config, nil
 }
 
-// ParseWireGuardConf 解析 WireGuard 配置文件格式
+// IsWireGuardConf 判断内容是否为 WireGuard 配置文件格式
+func IsWireGuardConf(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return strings.EqualFold(line, "[interface]") || strings.EqualFold(line, "[peer]")
+	}
+	return false
+}
+
+// ParseWireGuardConf 解析 WireGuard 配置文件格式 (wg-quick)
+// 支持多个 [Peer]、多行 Address/AllowedIPs/DNS 以及 [IPv6]:port 形式的 Endpoint
 func ParseWireGuardConf(confContent string) (*model.ProfileItem, error) {
 	config := model.NewProfileItem(model.WIREGUARD)
 
-	interfaceParams := make(map[string]string)
-	peerParams := make(map[string]string)
+	interfaceParams := make(map[string][]string)
+	var peers []map[string][]string
 
 	var currentSection string
 
 	for _, line := range strings.Split(confContent, "\n") {
+		// 移除行内注释
+		if idx := strings.Index(line, "#"); idx != -1 {
+			line = line[:idx]
+		}
 		line = strings.TrimSpace(line)
 
-		// 跳过空行和注释
-		if line == "" || strings.HasPrefix(line, "#") {
+		// 跳过空行
+		if line == "" {
 			continue
 		}
 
 		// 检测 section
-		if strings.HasPrefix(strings.ToLower(line), "[interface]") {
-			currentSection = "Interface"
+		if strings.HasPrefix(line, "[") {
+			switch strings.ToLower(line) {
+			case "[interface]":
+				currentSection = "Interface"
+			case "[peer]":
+				currentSection = "Peer"
+				peers = append(peers, make(map[string][]string))
+			default:
+				currentSection = "" // 忽略未知 section
+			}
 			continue
 		}
-		if strings.HasPrefix(strings.ToLower(line), "[peer]") {
-			currentSection = "Peer"
+
+		// 解析键值对
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
 			continue
 		}
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
 
-		// 解析键值对
-		if currentSection != "" {
-			parts := strings.SplitN(line, "=", 2)
-			if len(parts) == 2 {
-				key := strings.ToLower(strings.TrimSpace(parts[0]))
-				value := strings.TrimSpace(parts[1])
-
-				switch currentSection {
-				case "Interface":
-					interfaceParams[key] = value
-				case "Peer":
-					peerParams[key] = value
-				}
-			}
+		switch currentSection {
+		case "Interface":
+			interfaceParams[key] = append(interfaceParams[key], value)
+		case "Peer":
+			peer := peers[len(peers)-1]
+			peer[key] = append(peer[key], value)
 		}
 	}
 
-	// 填充配置
-	config.SecretKey = interfaceParams["privatekey"]
+	// Interface 配置
+	config.SecretKey = lastValue(interfaceParams, "privatekey")
+	if config.SecretKey == "" {
+		return nil, errors.New("wireguard conf: missing PrivateKey in [Interface]")
+	}
 	config.Remarks = strconv.FormatInt(time.Now().UnixMilli(), 10) // 时间戳作为默认备注
-	config.LocalAddress = interfaceParams["address"]
+	config.LocalAddress = joinValues(interfaceParams["address"])
 	if config.LocalAddress == "" {
 		config.LocalAddress = "10.0.0.2/32"
 	}
+	config.DNS = joinValues(interfaceParams["dns"])
 
 	// MTU
-	if mtuStr := interfaceParams["mtu"]; mtuStr != "" {
-		if mtu, err := strconv.Atoi(mtuStr); err == nil {
-			config.MTU = mtu
+	if mtuStr := lastValue(interfaceParams, "mtu"); mtuStr != "" {
+		mtu, err := strconv.Atoi(mtuStr)
+		if err != nil {
+			return nil, fmt.Errorf("wireguard conf: invalid MTU %q", mtuStr)
 		}
+		config.MTU = mtu
 	} else {
 		config.MTU = 1420
 	}
 
 	// Peer 配置
-	config.PublicKey = peerParams["publickey"]
-	if psk := peerParams["presharedkey"]; psk != "" {
-		config.PreSharedKey = psk
-	}
+	for i, params := range peers {
+		peer := model.WireGuardPeer{
+			PublicKey:    lastValue(params, "publickey"),
+			PreSharedKey: lastValue(params, "presharedkey"),
+			Endpoint:     lastValue(params, "endpoint"),
+			AllowedIPs:   joinValues(params["allowedips"]),
+		}
+		if peer.PublicKey == "" {
+			if host, _ := splitEndpoint(peer.Endpoint); keys.IsWarpEndpoint(host) {
+				peer.PublicKey = keys.WarpPublicKey
+			} else {
+				return nil, fmt.Errorf("wireguard conf: missing PublicKey in [Peer] #%d", i+1)
+			}
+		}
+		if ka := lastValue(params, "persistentkeepalive"); ka != "" && !strings.EqualFold(ka, "off") {
+			keepAlive, err := strconv.Atoi(ka)
+			if err != nil {
+				return nil, fmt.Errorf("wireguard conf: invalid PersistentKeepalive %q", ka)
+			}
+			peer.KeepAlive = keepAlive
+		}
+		config.Peers = append(config.Peers, peer)
 
-	// Endpoint
-	endpoint := peerParams["endpoint"]
-	if endpoint != "" {
-		// 解析 server:port
-		lastColon := strings.LastIndex(endpoint, ":")
-		if lastColon != -1 {
-			config.Server = endpoint[:lastColon]
-			config.ServerPort = endpoint[lastColon+1:]
-		} else {
-			config.Server = endpoint
+		// Reserved (部分工具写在 Peer 中)
+		if reserved := lastValue(params, "reserved"); reserved != "" {
+			config.Reserved = reserved
 		}
 	}
+	if len(config.Peers) == 0 {
+		return nil, errors.New("wireguard conf: no [Peer] section")
+	}
+
+	// 第一个 Peer 同步到顶层字段，兼容单 Peer 的链接格式
+	first := config.Peers[0]
+	config.PublicKey = first.PublicKey
+	config.PreSharedKey = first.PreSharedKey
+	config.Server, config.ServerPort = splitEndpoint(first.Endpoint)
+
+	if err := normalizeWireGuardKeys(config); err != nil {
+		return nil, fmt.Errorf("wireguard conf: %v", err)
+	}
 
 	// Reserved
-	config.Reserved = peerParams["reserved"]
+	if reserved := lastValue(interfaceParams, "reserved"); reserved != "" {
+		config.Reserved = reserved
+	}
+	reserved, err := util.NormalizeReserved(config.Reserved)
+	if err != nil {
+		return nil, fmt.Errorf("wireguard conf: %v", err)
+	}
+	config.Reserved = reserved
 	if config.Reserved == "" {
 		config.Reserved = "0,0,0"
 	}
@@ -149,6 +218,83 @@ func ParseWireGuardConf(confContent string) (*model.ProfileItem, error) {
 	return config, nil
 }
 
+// normalizeWireGuardKeys 校验并规范化 WireGuard 密钥
+// 缺失 Peer 公钥时，若端点为 Cloudflare WARP 则补全 WARP 公钥
+func normalizeWireGuardKeys(config *model.ProfileItem) error {
+	var err error
+
+	if config.SecretKey == "" {
+		return errors.New("missing private key")
+	}
+	if config.SecretKey, err = keys.NormalizeWireGuard(config.SecretKey); err != nil {
+		return fmt.Errorf("private key: %v", err)
+	}
+
+	if config.PublicKey == "" && keys.IsWarpEndpoint(config.Server) {
+		config.PublicKey = keys.WarpPublicKey
+	}
+	if config.PublicKey == "" {
+		return errors.New("missing peer public key")
+	}
+	if config.PublicKey, err = keys.NormalizeWireGuard(config.PublicKey); err != nil {
+		return fmt.Errorf("public key: %v", err)
+	}
+
+	if config.PreSharedKey != "" {
+		if config.PreSharedKey, err = keys.NormalizeWireGuard(config.PreSharedKey); err != nil {
+			return fmt.Errorf("preshared key: %v", err)
+		}
+	}
+
+	for i := range config.Peers {
+		peer := &config.Peers[i]
+		if peer.PublicKey, err = keys.NormalizeWireGuard(peer.PublicKey); err != nil {
+			return fmt.Errorf("peer #%d public key: %v", i+1, err)
+		}
+		if peer.PreSharedKey != "" {
+			if peer.PreSharedKey, err = keys.NormalizeWireGuard(peer.PreSharedKey); err != nil {
+				return fmt.Errorf("peer #%d preshared key: %v", i+1, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// splitEndpoint 拆分 Endpoint 为主机和端口，支持 [IPv6]:port
+func splitEndpoint(endpoint string) (string, string) {
+	if endpoint == "" {
+		return "", ""
+	}
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return strings.Trim(endpoint, "[]"), ""
+	}
+	return host, port
+}
+
+// lastValue 返回键的最后一个值 (重复键以后者为准)
+func lastValue(params map[string][]string, key string) string {
+	values := params[key]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// joinValues 合并多行/逗号分隔的值为逗号分隔字符串
+func joinValues(values []string) string {
+	var result []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return strings.Join(result, ",")
+}
+
 // ToWireGuardURI 生成 WireGuard 链接
 func ToWireGuardURI(config *model.ProfileItem) string {
 	query := url.Values{}
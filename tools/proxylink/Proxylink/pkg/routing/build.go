@@ -0,0 +1,220 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultDomainStrategy rule.json 默认的 domainStrategy (与 WebUI 一致)
+const DefaultDomainStrategy = "AsIs"
+
+// internalRules 模块内置 DNS 使用的规则，追加在用户规则之后
+var internalRules = []Rule{
+	{Type: "field", InboundTag: StringList{"domestic-dns"}, OutboundTag: "direct"},
+	{Type: "field", InboundTag: StringList{"dns-module"}, OutboundTag: "proxy"},
+}
+
+// internalRuleNames 内置规则的显示名称，与 internalRules 一一对应
+var internalRuleNames = []string{"内置: 国内 DNS 直连", "内置: 模块 DNS 走代理"}
+
+// RuleError 单条规则的错误
+type RuleError struct {
+	Index int
+	Name  string
+	Err   error
+}
+
+func (e *RuleError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("规则 #%d: %v", e.Index+1, e.Err)
+	}
+	return fmt.Sprintf("规则 #%d (%s): %v", e.Index+1, e.Name, e.Err)
+}
+
+// Errors 多条规则的错误
+type Errors []*RuleError
+
+// Merge 合并两组错误并按规则序号排序
+func (e Errors) Merge(other error) Errors {
+	merged := append(Errors{}, e...)
+	if more, ok := other.(Errors); ok {
+		merged = append(merged, more...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Index < merged[j].Index })
+	return merged
+}
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Config rule.json 内容
+type Config struct {
+	Routing Section `json:"routing"`
+}
+
+// Section routing 字段
+type Section struct {
+	DomainStrategy string   `json:"domainStrategy"`
+	Rules          []Rule   `json:"rules"`
+	Names          []string `json:"-"` // 与 Rules 一一对应的规则名称
+}
+
+// ParseRules 解析 routing_rules.json，每条规则单独解析，错误按规则名称返回
+// 部分规则出错时仍返回其余规则，便于继续校验
+func ParseRules(data []byte) ([]*UIRule, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("routing_rules.json 必须是规则数组: %v", err)
+	}
+
+	var rules []*UIRule
+	var errs Errors
+	for i, item := range items {
+		var head struct {
+			Name string `json:"name"`
+		}
+		json.Unmarshal(item, &head)
+
+		dec := json.NewDecoder(bytes.NewReader(item))
+		dec.DisallowUnknownFields()
+		rule := UIRule{Index: i}
+		if err := dec.Decode(&rule); err != nil {
+			errs = append(errs, &RuleError{Index: i, Name: head.Name, Err: err})
+			continue
+		}
+		rules = append(rules, &rule)
+	}
+	if len(errs) > 0 {
+		return rules, errs
+	}
+	return rules, nil
+}
+
+// Compile 校验并编译规则：跳过禁用规则，去掉 WebUI 字段，
+// DNS 劫持和 API 规则排在最前，末尾追加内置 DNS 规则
+func Compile(rules []*UIRule, domainStrategy string) (*Config, error) {
+	if domainStrategy == "" {
+		domainStrategy = DefaultDomainStrategy
+	}
+
+	var first, api, rest []namedRule
+	var errs Errors
+	for _, ui := range rules {
+		if !ui.IsEnabled() {
+			continue
+		}
+		rule := ui.Rule
+		if rule.OutboundTag == "" && rule.BalancerTag == "" {
+			rule.OutboundTag = "proxy"
+		}
+		if err := rule.Validate(); err != nil {
+			errs = append(errs, &RuleError{Index: ui.Index, Name: ui.Name, Err: err})
+			continue
+		}
+
+		named := namedRule{rule, ui.Name}
+		switch {
+		case isDNSHijack(&rule):
+			first = append(first, named)
+		case isAPI(&rule):
+			api = append(api, named)
+		default:
+			rest = append(rest, named)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	section := Section{DomainStrategy: domainStrategy}
+	for _, named := range append(append(first, api...), rest...) {
+		section.Rules = append(section.Rules, named.Rule)
+		section.Names = append(section.Names, named.Name)
+	}
+	for i, internal := range internalRules {
+		if !containsRule(section.Rules, internal) {
+			section.Rules = append(section.Rules, internal)
+			section.Names = append(section.Names, internalRuleNames[i])
+		}
+	}
+
+	return &Config{section}, nil
+}
+
+// namedRule 编译过程中携带名称的规则
+type namedRule struct {
+	Rule
+	Name string
+}
+
+// isDNSHijack 透明代理入站的 DNS 请求交给 dns-out
+func isDNSHijack(r *Rule) bool {
+	return r.OutboundTag == "dns-out"
+}
+
+// isAPI api 入站转发到 api 出站
+func isAPI(r *Rule) bool {
+	return r.OutboundTag == "api"
+}
+
+// containsRule 判断是否已有相同入站和出站的规则
+func containsRule(rules []Rule, target Rule) bool {
+	for _, r := range rules {
+		if r.OutboundTag == target.OutboundTag && strings.Join(r.InboundTag, ",") == strings.Join(target.InboundTag, ",") &&
+			len(r.Domain) == 0 && len(r.IP) == 0 && r.Port == "" && r.Network == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Marshal 按 WebUI 的格式 (4 空格缩进) 输出
+func (c *Config) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFileAtomic 先写入同目录临时文件再重命名，避免 Xray 读到写了一半的文件
+func WriteFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
@@ -0,0 +1,606 @@
+package routing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 规则集格式
+const (
+	FormatClash   = "clash"
+	FormatSurge   = "surge"
+	FormatSingBox = "sing-box"
+)
+
+// builtinPolicies Clash/Surge 内置策略对应的出站
+var builtinPolicies = map[string]string{
+	"DIRECT":         "direct",
+	"REJECT":         "block",
+	"REJECT-DROP":    "block",
+	"REJECT-TINYGIF": "block",
+	"REJECT-NO-DROP": "block",
+	"REJECT-200":     "block",
+}
+
+// ruleOptions 规则末尾的选项，不是策略名
+var ruleOptions = map[string]bool{"no-resolve": true, "extended-matching": true, "pre-matching": true, "dns-failed": true}
+
+// ImportOptions 导入参数
+type ImportOptions struct {
+	Format   string            // 为空时自动识别
+	Name     string            // 生成规则的名称前缀
+	Outbound string            // 未指定策略 (规则集本身不带策略) 及未映射策略使用的出站
+	Policies map[string]string // 策略名 → outboundTag，不区分大小写
+}
+
+// ImportIssue 无法转换的规则
+type ImportIssue struct {
+	Line   int    `json:"line"` // 行号；sing-box 为规则序号
+	Text   string `json:"text"`
+	Reason string `json:"reason"`
+}
+
+func (i ImportIssue) String() string {
+	return fmt.Sprintf("%d: %s: %s", i.Line, i.Reason, i.Text)
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	Format   string            `json:"format"`
+	Rules    []*UIRule         `json:"rules"`
+	Skipped  []ImportIssue     `json:"skipped,omitempty"`
+	Policies map[string]string `json:"policies,omitempty"` // 实际使用的策略映射
+	Unmapped []string          `json:"unmapped,omitempty"` // 没有 -map 映射、回退到默认出站的策略
+}
+
+// condition 单个匹配条件
+type condition struct {
+	field string // domain, ip, port, network, source, sourcePort, localPort, final
+	value string
+}
+
+// fieldLabels 合并后规则名称中的条件说明
+var fieldLabels = map[string]string{
+	"domain":     "域名",
+	"ip":         "IP",
+	"port":       "端口",
+	"network":    "网络",
+	"source":     "来源 IP",
+	"sourcePort": "来源端口",
+	"localPort":  "入站端口",
+	"final":      "兜底",
+}
+
+// clashKeyRegex Clash YAML 规则集的顶层键
+var clashKeyRegex = regexp.MustCompile(`(?m)^(payload|rules)\s*:`)
+
+// clashDomainRegex Clash 文本域名集特有的 +. 写法
+var clashDomainRegex = regexp.MustCompile(`(?m)^\s*\+\.`)
+
+// DetectFormat 按内容结构识别规则集格式: JSON 为 sing-box，顶层有 payload:/rules: 键为 Clash，
+// 其余为 Surge；不带 YAML 结构却含 +. 条目的文本域名集无法可靠区分，返回空字符串
+func DetectFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return FormatSingBox
+	}
+	if clashKeyRegex.Match(trimmed) {
+		return FormatClash
+	}
+	if clashDomainRegex.Match(trimmed) {
+		return ""
+	}
+	return FormatSurge
+}
+
+// Import 将 Clash classical、Surge 规则/域名集或 sing-box 源规则集转换为 routing_rules.json 条目
+// 连续的、条件类型与出站相同的单一条件规则合并为一条，保持原列表的先后；无法转换的规则记入 Skipped
+func Import(data []byte, opts ImportOptions) (*ImportResult, error) {
+	format := opts.Format
+	if format == "" {
+		if format = DetectFormat(data); format == "" {
+			return nil, fmt.Errorf("无法识别规则集格式 (文本域名集含 +. 条目)，请用 -format 指定 clash 或 surge")
+		}
+	}
+	if opts.Outbound == "" {
+		opts.Outbound = "proxy"
+	}
+
+	imp := &importer{opts: opts, result: &ImportResult{Format: format, Policies: map[string]string{}}, names: map[string]int{}}
+	switch format {
+	case FormatClash, FormatSurge:
+		if err := imp.classical(data, format); err != nil {
+			return nil, err
+		}
+	case FormatSingBox:
+		if err := imp.singBox(data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("未知格式 %q，支持 clash、surge、sing-box", format)
+	}
+	return imp.result, nil
+}
+
+type groupKey struct {
+	field    string
+	outbound string
+}
+
+type importer struct {
+	opts   ImportOptions
+	result *ImportResult
+	last   *UIRule        // 可继续合并的上一条规则
+	key    groupKey       // last 的条件类型与出站
+	names  map[string]int // 各名称已使用的次数
+}
+
+func (imp *importer) skip(line int, text, format string, args ...interface{}) {
+	imp.result.Skipped = append(imp.result.Skipped, ImportIssue{Line: line, Text: text, Reason: fmt.Sprintf(format, args...)})
+}
+
+// outbound 将策略名映射为出站 tag
+func (imp *importer) outbound(policy string) string {
+	if policy == "" {
+		return imp.opts.Outbound
+	}
+	for name, tag := range imp.opts.Policies {
+		if strings.EqualFold(name, policy) {
+			imp.result.Policies[policy] = tag
+			return tag
+		}
+	}
+	tag, ok := builtinPolicies[strings.ToUpper(policy)]
+	if !ok {
+		tag = imp.opts.Outbound
+		if _, seen := imp.result.Policies[policy]; !seen {
+			imp.result.Unmapped = append(imp.result.Unmapped, policy)
+		}
+	}
+	imp.result.Policies[policy] = tag
+	return tag
+}
+
+// add 将单一条件并入上一条同类规则，条件类型或出站不同时新建一条
+// 只合并相邻的规则，first-match 的先后不变
+func (imp *importer) add(c condition, outbound string) {
+	key := groupKey{c.field, outbound}
+	if imp.last == nil || imp.key != key {
+		imp.last = imp.newRule(fieldLabels[c.field], outbound)
+		imp.key = key
+		imp.result.Rules = append(imp.result.Rules, imp.last)
+	}
+	addCondition(imp.last, c)
+}
+
+// addCondition 将条件并入规则 (同类条件为"或")
+func addCondition(rule *UIRule, c condition) {
+	switch c.field {
+	case "domain":
+		rule.Domain = appendUnique(rule.Domain, c.value)
+	case "ip":
+		rule.IP = appendUnique(rule.IP, c.value)
+	case "source":
+		rule.Source = appendUnique(rule.Source, c.value)
+	case "port":
+		rule.Port = PortSpec(joinUnique(string(rule.Port), c.value))
+	case "sourcePort":
+		rule.SourcePort = PortSpec(joinUnique(string(rule.SourcePort), c.value))
+	case "localPort":
+		rule.LocalPort = PortSpec(joinUnique(string(rule.LocalPort), c.value))
+	case "network":
+		rule.Network = joinUnique(rule.Network, c.value)
+	case "final":
+		rule.Port = "0-65535"
+	}
+}
+
+// newRule 新建规则，同名的规则依次加上 #2、#3 … 后缀
+func (imp *importer) newRule(label, outbound string) *UIRule {
+	name := label + " → " + outbound
+	if imp.opts.Name != "" {
+		name = imp.opts.Name + ": " + name
+	}
+	if imp.names[name]++; imp.names[name] > 1 {
+		name += fmt.Sprintf(" #%d", imp.names[name])
+	}
+	enabled, visible := true, true
+	return &UIRule{
+		Name:    name,
+		Enabled: &enabled,
+		Visible: &visible,
+		Rule:    Rule{Type: "field", OutboundTag: outbound},
+	}
+}
+
+// classical 解析 Clash classical / Surge 规则，每行 TYPE,VALUE[,POLICY][,options]；
+// 不含逗号的行按域名集 (或 ipcidr 规则集) 处理
+func (imp *importer) classical(data []byte, format string) error {
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		text := strings.TrimSpace(scanner.Text())
+		line := cleanClassicalLine(text)
+		if line == "" {
+			continue
+		}
+
+		if !strings.Contains(line, ",") {
+			if strings.HasSuffix(line, ":") || strings.Contains(line, ": ") {
+				continue // YAML 键 (payload:、rules:)
+			}
+			c, err := domainSetEntry(line, format)
+			if err != nil {
+				imp.skip(lineNo, text, "%v", err)
+				continue
+			}
+			imp.add(c, imp.opts.Outbound)
+			continue
+		}
+
+		parts := strings.Split(line, ",")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		typ := strings.ToUpper(parts[0])
+
+		var policy string
+		var c condition
+		var err error
+		if typ == "MATCH" || typ == "FINAL" {
+			c = condition{field: "final"}
+			if len(parts) > 1 {
+				policy = parts[1]
+			}
+		} else {
+			if len(parts) > 2 && !ruleOptions[strings.ToLower(parts[2])] {
+				policy = parts[2]
+			}
+			c, err = classicalCondition(typ, parts[1])
+		}
+		if err != nil {
+			imp.skip(lineNo, text, "%v", err)
+			continue
+		}
+		imp.add(c, imp.outbound(policy))
+	}
+	return scanner.Err()
+}
+
+// cleanClassicalLine 去掉注释、YAML 列表前缀和引号
+func cleanClassicalLine(line string) string {
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") || strings.HasPrefix(line, ";") {
+		return ""
+	}
+	if i := strings.Index(line, " //"); i >= 0 {
+		line = line[:i]
+	}
+	if i := strings.Index(line, " #"); i >= 0 {
+		line = line[:i]
+	}
+	line = strings.TrimSpace(strings.TrimPrefix(line, "- "))
+	return strings.Trim(line, `'"`)
+}
+
+// classicalCondition 转换单条规则的类型和值
+func classicalCondition(typ, value string) (condition, error) {
+	if value == "" {
+		return condition{}, fmt.Errorf("%s 缺少值", typ)
+	}
+	var c condition
+	switch typ {
+	case "DOMAIN":
+		c = condition{"domain", "full:" + strings.ToLower(value)}
+	case "DOMAIN-SUFFIX":
+		c = condition{"domain", "domain:" + strings.ToLower(strings.TrimPrefix(value, "."))}
+	case "DOMAIN-KEYWORD":
+		c = condition{"domain", "keyword:" + strings.ToLower(value)}
+	case "DOMAIN-REGEX":
+		c = condition{"domain", "regexp:" + value}
+	case "DOMAIN-WILDCARD":
+		c = condition{"domain", "regexp:" + wildcardRegexp(strings.ToLower(value))}
+	case "GEOSITE":
+		c = condition{"domain", "geosite:" + strings.ToLower(value)}
+	case "IP-CIDR", "IP-CIDR6":
+		c = condition{"ip", value}
+	case "GEOIP":
+		c = condition{"ip", "geoip:" + strings.ToLower(value)}
+	case "SRC-IP-CIDR", "SRC-IP":
+		c = condition{"source", value}
+	case "DST-PORT", "DEST-PORT":
+		c = condition{"port", value}
+	case "SRC-PORT":
+		c = condition{"sourcePort", value}
+	case "IN-PORT":
+		c = condition{"localPort", value}
+	case "NETWORK", "PROTOCOL":
+		n := strings.ToLower(value)
+		if n != "tcp" && n != "udp" {
+			return condition{}, fmt.Errorf("%s 只支持 tcp/udp", typ)
+		}
+		c = condition{"network", n}
+	default:
+		return condition{}, fmt.Errorf("不支持的规则类型 %s", typ)
+	}
+	return c, validateCondition(c)
+}
+
+// domainSetEntry 转换域名集条目
+// Clash: +.x 为域名及子域名，.x 仅子域名，*.x 为一级子域名；Surge: .x 为域名及子域名；其余为完整域名
+func domainSetEntry(line, format string) (condition, error) {
+	if _, _, err := net.ParseCIDR(line); err == nil {
+		return condition{"ip", line}, nil
+	}
+	if net.ParseIP(line) != nil {
+		return condition{"ip", line}, nil
+	}
+
+	d := strings.ToLower(line)
+	var c condition
+	switch {
+	case strings.HasPrefix(d, "+."):
+		c = condition{"domain", "domain:" + d[2:]}
+	case strings.HasPrefix(d, ".") && format == FormatSurge:
+		c = condition{"domain", "domain:" + d[1:]}
+	case strings.HasPrefix(d, "."):
+		c = condition{"domain", "regexp:" + regexp.QuoteMeta(d) + "$"}
+	case strings.Contains(d, "*"):
+		c = condition{"domain", "regexp:" + wildcardRegexp(d)}
+	default:
+		c = condition{"domain", "full:" + d}
+	}
+	return c, validateCondition(c)
+}
+
+// wildcardRegexp 将 *.example.com / ads?.example.com 转换为正则
+func wildcardRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString("[^.]*")
+		case '?':
+			b.WriteString("[^.]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// validateCondition 按 Xray 规则校验转换结果
+func validateCondition(c condition) error {
+	switch c.field {
+	case "domain":
+		value := c.value[strings.IndexByte(c.value, ':')+1:]
+		if strings.ContainsAny(value, " /\\") && !strings.HasPrefix(c.value, "regexp:") {
+			return fmt.Errorf("无效的域名 %q", value)
+		}
+		_, err := normalizeDomain(c.value)
+		return err
+	case "ip", "source":
+		return validateIP(c.value)
+	case "port", "sourcePort", "localPort":
+		return validatePorts(c.value)
+	}
+	return nil
+}
+
+// singBox 解析 sing-box 源规则集 (headless rule)
+func (imp *importer) singBox(data []byte) error {
+	var src struct {
+		Version int                          `json:"version"`
+		Rules   []map[string]json.RawMessage `json:"rules"`
+	}
+	if err := json.Unmarshal(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), &src); err != nil {
+		return fmt.Errorf("sing-box 规则集: %v", err)
+	}
+
+	for i, item := range src.Rules {
+		text, _ := json.Marshal(item)
+		conds, err := singBoxConditions(item)
+		if err != nil {
+			imp.skip(i+1, string(text), "%v", err)
+			continue
+		}
+
+		fields := map[string]bool{}
+		for _, c := range conds {
+			fields[c.field] = true
+		}
+		if len(fields) == 1 {
+			for _, c := range conds {
+				imp.add(c, imp.opts.Outbound)
+			}
+			continue
+		}
+
+		// 多种条件同时存在时为"与"，单独生成一条规则
+		keys := make([]string, 0, len(fields))
+		for f := range fields {
+			keys = append(keys, fieldLabels[f])
+		}
+		sort.Strings(keys)
+		rule := imp.newRule(strings.Join(keys, "+"), imp.opts.Outbound)
+		for _, c := range conds {
+			addCondition(rule, c)
+		}
+		imp.result.Rules = append(imp.result.Rules, rule)
+		imp.last = nil
+	}
+	return nil
+}
+
+// singBoxConditions 转换 sing-box 规则的各字段，包含不支持的字段时整条规则不转换
+func singBoxConditions(item map[string]json.RawMessage) ([]condition, error) {
+	keys := make([]string, 0, len(item))
+	for k := range item {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var conds []condition
+	for _, key := range keys {
+		raw := item[key]
+		if key == "ip_is_private" {
+			var private bool
+			if err := json.Unmarshal(raw, &private); err != nil || !private {
+				return nil, fmt.Errorf("不支持的字段 %s: %s", key, raw)
+			}
+			conds = append(conds, condition{"ip", "geoip:private"})
+			continue
+		}
+
+		values, err := listable(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", key, err)
+		}
+		for _, v := range values {
+			var c condition
+			switch key {
+			case "domain":
+				c = condition{"domain", "full:" + strings.ToLower(v)}
+			case "domain_suffix":
+				if strings.HasPrefix(v, ".") {
+					c = condition{"domain", "regexp:" + regexp.QuoteMeta(strings.ToLower(v)) + "$"}
+				} else {
+					c = condition{"domain", "domain:" + strings.ToLower(v)}
+				}
+			case "domain_keyword":
+				c = condition{"domain", "keyword:" + strings.ToLower(v)}
+			case "domain_regex":
+				c = condition{"domain", "regexp:" + v}
+			case "geosite":
+				c = condition{"domain", "geosite:" + strings.ToLower(v)}
+			case "ip_cidr":
+				c = condition{"ip", v}
+			case "geoip":
+				c = condition{"ip", "geoip:" + strings.ToLower(v)}
+			case "source_ip_cidr":
+				c = condition{"source", v}
+			case "port":
+				c = condition{"port", v}
+			case "port_range":
+				c = condition{"port", singBoxRange(v)}
+			case "source_port":
+				c = condition{"sourcePort", v}
+			case "source_port_range":
+				c = condition{"sourcePort", singBoxRange(v)}
+			case "network":
+				c = condition{"network", strings.ToLower(v)}
+			default:
+				return nil, fmt.Errorf("不支持的字段 %s", key)
+			}
+			if err := validateCondition(c); err != nil {
+				return nil, fmt.Errorf("%s %q: %v", key, v, err)
+			}
+			conds = append(conds, c)
+		}
+	}
+	if len(conds) == 0 {
+		return nil, fmt.Errorf("没有匹配条件")
+	}
+	return conds, nil
+}
+
+// listable 解析 sing-box 的单值或数组 (字符串或数字)
+func listable(raw json.RawMessage) ([]string, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		items = []json.RawMessage{raw}
+	}
+	var values []string
+	for _, item := range items {
+		var s string
+		if err := json.Unmarshal(item, &s); err == nil {
+			values = append(values, s)
+			continue
+		}
+		var n int
+		if err := json.Unmarshal(item, &n); err != nil {
+			return nil, fmt.Errorf("want string or number, got %s", item)
+		}
+		values = append(values, strconv.Itoa(n))
+	}
+	return values, nil
+}
+
+// singBoxRange 将 1000:2000、:3000、4000: 转换为 Xray 端口范围
+func singBoxRange(s string) string {
+	from, to, ok := strings.Cut(s, ":")
+	if !ok {
+		return s
+	}
+	if from == "" {
+		from = "0"
+	}
+	if to == "" {
+		to = "65535"
+	}
+	return from + "-" + to
+}
+
+func appendUnique(list StringList, value string) StringList {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+// joinUnique 向逗号分隔列表追加不重复的项
+func joinUnique(list, value string) string {
+	if list == "" {
+		return value
+	}
+	for _, v := range strings.Split(list, ",") {
+		if v == value {
+			return list
+		}
+	}
+	return list + "," + value
+}
+
+// MarshalJSON 按 WebUI 保存的字段顺序输出: name 在前，enabled/visible 在后
+func (r UIRule) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	if r.Name != "" {
+		name, _ := json.Marshal(r.Name)
+		buf.WriteString(`"name":`)
+		buf.Write(name)
+	}
+	rule, err := json.Marshal(r.Rule)
+	if err != nil {
+		return nil, err
+	}
+	if inner := bytes.TrimSuffix(bytes.TrimPrefix(rule, []byte("{")), []byte("}")); len(inner) > 0 {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(inner)
+	}
+	for _, f := range []struct {
+		key   string
+		value *bool
+	}{{"enabled", r.Enabled}, {"visible", r.Visible}} {
+		if f.value != nil {
+			if buf.Len() > 1 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(&buf, "%q:%t", f.key, *f.value)
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
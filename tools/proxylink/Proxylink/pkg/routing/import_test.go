@@ -0,0 +1,59 @@
+package routing
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImportKeepsOrder(t *testing.T) {
+	data := []byte(`payload:
+  - DOMAIN-SUFFIX,a.com,DIRECT
+  - DOMAIN-SUFFIX,c.com,DIRECT
+  - IP-CIDR,1.0.0.0/8,Proxy
+  - DOMAIN,b.a.com,Proxy
+  - DOMAIN-SUFFIX,d.com,DIRECT
+`)
+	result, err := Import(data, ImportOptions{Name: "t", Policies: map[string]string{"Proxy": "proxy"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type rule struct {
+		name   string
+		domain StringList
+		ip     StringList
+	}
+	want := []rule{
+		{"t: 域名 → direct", StringList{"domain:a.com", "domain:c.com"}, nil},
+		{"t: IP → proxy", nil, StringList{"1.0.0.0/8"}},
+		{"t: 域名 → proxy", StringList{"full:b.a.com"}, nil},
+		{"t: 域名 → direct #2", StringList{"domain:d.com"}, nil},
+	}
+	var got []rule
+	for _, r := range result.Rules {
+		got = append(got, rule{r.Name, r.Domain, r.IP})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rules = %+v, want %+v", got, want)
+	}
+}
+
+func TestImportSingBoxBreaksRun(t *testing.T) {
+	data := []byte(`{"version": 1, "rules": [
+  {"domain_suffix": ["a.com"]},
+  {"domain": ["b.com"], "port": [443]},
+  {"domain_suffix": ["c.com"]}
+]}`)
+	result, err := Import(data, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range result.Rules {
+		names = append(names, r.Name)
+	}
+	want := []string{"域名 → proxy", "域名+端口 → proxy", "域名 → proxy #2"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %q, want %q", names, want)
+	}
+}
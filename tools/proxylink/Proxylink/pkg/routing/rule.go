@@ -0,0 +1,97 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rule Xray 路由规则 (field 类型)
+type Rule struct {
+	Type          string          `json:"type,omitempty"`
+	Domain        StringList      `json:"domain,omitempty"`
+	IP            StringList      `json:"ip,omitempty"`
+	Port          PortSpec        `json:"port,omitempty"`
+	SourcePort    PortSpec        `json:"sourcePort,omitempty"`
+	LocalPort     PortSpec        `json:"localPort,omitempty"`
+	Network       string          `json:"network,omitempty"`
+	Source        StringList      `json:"source,omitempty"`
+	LocalIP       StringList      `json:"localIP,omitempty"`
+	User          StringList      `json:"user,omitempty"`
+	VlessRoute    PortSpec        `json:"vlessRoute,omitempty"`
+	InboundTag    StringList      `json:"inboundTag,omitempty"`
+	Protocol      StringList      `json:"protocol,omitempty"`
+	Process       StringList      `json:"process,omitempty"`
+	Attrs         json.RawMessage `json:"attrs,omitempty"`
+	DomainMatcher string          `json:"domainMatcher,omitempty"`
+	OutboundTag   string          `json:"outboundTag,omitempty"`
+	BalancerTag   string          `json:"balancerTag,omitempty"`
+	RuleTag       string          `json:"ruleTag,omitempty"`
+}
+
+// UIRule routing_rules.json 中的规则，附加 WebUI 使用的字段
+type UIRule struct {
+	Index   int    `json:"-"` // 在文件中的序号
+	Name    string `json:"name,omitempty"`
+	Enabled *bool  `json:"enabled,omitempty"`
+	Visible *bool  `json:"visible,omitempty"`
+	Rule
+}
+
+// IsEnabled 未设置 enabled 时视为启用 (与 WebUI 一致)
+func (r *UIRule) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// StringList 字符串列表，兼容 JSON 数组和 WebUI 的逗号分隔字符串
+type StringList []string
+
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = splitList(s)
+		return nil
+	}
+
+	var items []string
+	if err := json.Unmarshal(data, &items); err != nil {
+		return fmt.Errorf("want string array or comma separated string, got %s", data)
+	}
+	var result StringList
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	*l = result
+	return nil
+}
+
+// PortSpec 端口表达式 ("53"、"1000-2000"、"53,443")，兼容数字写法
+type PortSpec string
+
+func (p *PortSpec) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*p = PortSpec(strconv.Itoa(n))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("want number or string, got %s", data)
+	}
+	*p = PortSpec(strings.TrimSpace(s))
+	return nil
+}
+
+// splitList 按逗号拆分并去掉空项
+func splitList(s string) StringList {
+	var result StringList
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
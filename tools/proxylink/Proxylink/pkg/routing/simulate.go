@@ -0,0 +1,352 @@
+package routing
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Target 待模拟的连接目标
+type Target struct {
+	Domain     string   `json:"domain,omitempty"`
+	IP         []net.IP `json:"ip,omitempty"`
+	Port       int      `json:"port"`
+	Network    string   `json:"network"`
+	InboundTag string   `json:"inboundTag,omitempty"`
+}
+
+// ParseTarget 解析 domain、ip、domain:port、ip:port 或 [ipv6]:port
+// 未指定端口时使用 443，网络默认为 tcp
+func ParseTarget(s string) (*Target, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("目标为空")
+	}
+
+	host, port := s, 443
+	if h, p, err := net.SplitHostPort(s); err == nil {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || n > 65535 {
+			return nil, fmt.Errorf("%s: 无效端口 %q", s, p)
+		}
+		host, port = h, n
+	} else if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		host = s[1 : len(s)-1]
+	}
+
+	target := &Target{Port: port, Network: "tcp"}
+	if ip := net.ParseIP(host); ip != nil {
+		target.IP = []net.IP{ip}
+		return target, nil
+	}
+	if strings.ContainsAny(host, "/ []") || strings.Contains(host, ":") {
+		return nil, fmt.Errorf("%s: 无效的域名或 IP", s)
+	}
+	target.Domain = strings.ToLower(strings.TrimSuffix(host, "."))
+	return target, nil
+}
+
+// String 以 host:port/network 形式显示
+func (t *Target) String() string {
+	host := t.Domain
+	if host == "" && len(t.IP) > 0 {
+		host = t.IP[0].String()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(t.Port)) + "/" + t.Network
+}
+
+// GeoData geosite/geoip 数据源
+// file 为 dat 文件名 (geosite: 对应 geosite.dat，ext:file:code 对应 file)，
+// code 可带 @attr 属性过滤
+type GeoData interface {
+	MatchSite(file, code, domain string) (bool, error)
+	MatchIP(file, code string, ip net.IP) (bool, error)
+}
+
+// Resolver 按 domainStrategy 需要解析域名时使用
+type Resolver func(domain string) ([]net.IP, error)
+
+// SimulateOptions 模拟参数
+type SimulateOptions struct {
+	DomainStrategy string
+	Geo            GeoData  // 为空时包含 geosite/geoip 的条件无法判断
+	Resolve        Resolver // 为空时 IPIfNonMatch/IPOnDemand 不解析域名
+}
+
+// Decision 模拟结果
+type Decision struct {
+	Target      *Target  `json:"target"`
+	Matched     bool     `json:"matched"`
+	Index       int      `json:"index"` // 命中规则序号 (从 0 开始)，未命中为 -1
+	Name        string   `json:"name,omitempty"`
+	OutboundTag string   `json:"outboundTag,omitempty"`
+	BalancerTag string   `json:"balancerTag,omitempty"`
+	Resolved    bool     `json:"resolved,omitempty"` // 是否解析域名后才命中
+	Notes       []string `json:"notes,omitempty"`    // 无法判断而跳过的条件
+}
+
+// Simulate 按顺序匹配规则，返回第一条命中的规则
+// 未命中时 Matched 为 false，由调用方使用默认出站 (第一个出站)
+func Simulate(rules []Rule, names []string, target *Target, opts SimulateOptions) *Decision {
+	sim := &simulator{opts: opts, notes: map[string]bool{}}
+	d := &Decision{Target: target, Index: -1}
+
+	strategy := opts.DomainStrategy
+	if strategy == "" {
+		strategy = DefaultDomainStrategy
+	}
+	t := *target
+	onDemand := strategy == "IPOnDemand" && t.Domain != "" && len(t.IP) == 0
+
+	for i := range rules {
+		if onDemand && len(rules[i].IP) > 0 {
+			onDemand = false
+			if sim.resolve(&t, strategy) {
+				d.Resolved = true
+			}
+		}
+		if sim.match(&rules[i], &t, i) {
+			sim.decide(d, rules, names, i)
+			return d
+		}
+	}
+
+	// IPIfNonMatch: 域名未命中任何规则时解析为 IP 再匹配一次
+	if strategy == "IPIfNonMatch" && t.Domain != "" && len(t.IP) == 0 && sim.resolve(&t, strategy) {
+		d.Resolved = true
+		for i := range rules {
+			if sim.match(&rules[i], &t, i) {
+				sim.decide(d, rules, names, i)
+				return d
+			}
+		}
+	}
+
+	d.Notes = sim.list
+	return d
+}
+
+// simulator 保存一次模拟中的参数和跳过说明
+type simulator struct {
+	opts  SimulateOptions
+	notes map[string]bool
+	list  []string
+}
+
+func (s *simulator) note(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if !s.notes[msg] {
+		s.notes[msg] = true
+		s.list = append(s.list, msg)
+	}
+}
+
+func (s *simulator) decide(d *Decision, rules []Rule, names []string, i int) {
+	d.Matched = true
+	d.Index = i
+	if i < len(names) {
+		d.Name = names[i]
+	}
+	if d.Name == "" {
+		d.Name = rules[i].RuleTag
+	}
+	d.OutboundTag = rules[i].OutboundTag
+	d.BalancerTag = rules[i].BalancerTag
+	d.Notes = s.list
+}
+
+func (s *simulator) resolve(t *Target, strategy string) bool {
+	if s.opts.Resolve == nil {
+		s.note("domainStrategy 为 %s，未解析域名，IP 规则只按域名目标判断", strategy)
+		return false
+	}
+	ips, err := s.opts.Resolve(t.Domain)
+	if err != nil || len(ips) == 0 {
+		s.note("解析 %s 失败: %v", t.Domain, err)
+		return false
+	}
+	t.IP = ips
+	return true
+}
+
+// match 规则内各条件为"与"，列表内各项为"或"
+func (s *simulator) match(r *Rule, t *Target, index int) bool {
+	for _, c := range []struct {
+		name string
+		set  bool
+	}{
+		{"source", len(r.Source) > 0},
+		{"sourcePort", r.SourcePort != ""},
+		{"localIP", len(r.LocalIP) > 0},
+		{"localPort", r.LocalPort != ""},
+		{"user", len(r.User) > 0},
+		{"vlessRoute", r.VlessRoute != ""},
+		{"protocol", len(r.Protocol) > 0},
+		{"process", len(r.Process) > 0},
+		{"attrs", len(r.Attrs) > 0},
+	} {
+		if c.set {
+			s.note("规则 #%d 含无法模拟的条件 %s，视为不命中", index+1, c.name)
+			return false
+		}
+	}
+
+	if len(r.InboundTag) > 0 && !containsString(r.InboundTag, t.InboundTag) {
+		return false
+	}
+	if r.Network != "" && !containsString(splitList(r.Network), t.Network) {
+		return false
+	}
+	if r.Port != "" && !matchPort(string(r.Port), t.Port) {
+		return false
+	}
+	if len(r.Domain) > 0 && !s.matchDomain(r.Domain, t.Domain, index) {
+		return false
+	}
+	if len(r.IP) > 0 && !s.matchIP(r.IP, t.IP, index) {
+		return false
+	}
+	return true
+}
+
+func (s *simulator) matchDomain(patterns []string, domain string, index int) bool {
+	if domain == "" {
+		return false
+	}
+	for _, p := range patterns {
+		ok, err := s.matchDomainPattern(p, domain)
+		if err != nil {
+			s.note("规则 #%d 的 %s: %v，视为不命中", index+1, p, err)
+			continue
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *simulator) matchDomainPattern(pattern, domain string) (bool, error) {
+	switch {
+	case strings.HasPrefix(pattern, "full:"):
+		return domain == strings.ToLower(pattern[len("full:"):]), nil
+	case strings.HasPrefix(pattern, "domain:"):
+		suffix := strings.ToLower(pattern[len("domain:"):])
+		return domain == suffix || strings.HasSuffix(domain, "."+suffix), nil
+	case strings.HasPrefix(pattern, "keyword:"):
+		return strings.Contains(domain, strings.ToLower(pattern[len("keyword:"):])), nil
+	case strings.HasPrefix(pattern, "regexp:"):
+		re, err := regexp.Compile(pattern[len("regexp:"):])
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(domain), nil
+	case strings.HasPrefix(pattern, "dotless:"):
+		return !strings.Contains(domain, ".") && strings.Contains(domain, pattern[len("dotless:"):]), nil
+	case strings.HasPrefix(pattern, "geosite:"):
+		return s.matchSite("geosite.dat", pattern[len("geosite:"):], domain)
+	case strings.HasPrefix(pattern, "ext:"):
+		file, code, ok := splitExt(pattern)
+		if !ok {
+			return false, fmt.Errorf("want ext:file:code")
+		}
+		return s.matchSite(file, code, domain)
+	default:
+		// 无前缀时 Xray 按子串匹配
+		return strings.Contains(domain, strings.ToLower(pattern)), nil
+	}
+}
+
+func (s *simulator) matchSite(file, code, domain string) (bool, error) {
+	if s.opts.Geo == nil {
+		return false, fmt.Errorf("需要 %s 数据", file)
+	}
+	return s.opts.Geo.MatchSite(file, code, domain)
+}
+
+func (s *simulator) matchIP(patterns []string, ips []net.IP, index int) bool {
+	if len(ips) == 0 {
+		return false
+	}
+	for _, p := range patterns {
+		for _, ip := range ips {
+			ok, err := s.matchIPPattern(p, ip)
+			if err != nil {
+				s.note("规则 #%d 的 %s: %v，视为不命中", index+1, p, err)
+				break
+			}
+			if ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *simulator) matchIPPattern(pattern string, ip net.IP) (bool, error) {
+	file, code := "", ""
+	switch {
+	case strings.HasPrefix(pattern, "geoip:"):
+		file, code = "geoip.dat", pattern[len("geoip:"):]
+	case strings.HasPrefix(pattern, "ext:"):
+		var ok bool
+		if file, code, ok = splitExt(pattern); !ok {
+			return false, fmt.Errorf("want ext:file:code")
+		}
+	default:
+		if _, cidr, err := net.ParseCIDR(pattern); err == nil {
+			return cidr.Contains(ip), nil
+		}
+		if parsed := net.ParseIP(pattern); parsed != nil {
+			return parsed.Equal(ip), nil
+		}
+		return false, fmt.Errorf("无效的 IP 或 CIDR")
+	}
+
+	inverted := strings.HasPrefix(code, "!")
+	code = strings.TrimPrefix(code, "!")
+	if s.opts.Geo == nil {
+		return false, fmt.Errorf("需要 %s 数据", file)
+	}
+	ok, err := s.opts.Geo.MatchIP(file, code, ip)
+	if err != nil {
+		return false, err
+	}
+	return ok != inverted, nil
+}
+
+// splitExt 拆分 ext:file:code
+func splitExt(pattern string) (file, code string, ok bool) {
+	parts := strings.SplitN(strings.TrimPrefix(pattern, "ext:"), ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// matchPort 判断端口是否在 "53,443,1000-2000" 表达式内
+func matchPort(spec string, port int) bool {
+	for _, part := range splitList(spec) {
+		from, to := part, part
+		if i := strings.Index(part, "-"); i > 0 {
+			from, to = part[:i], part[i+1:]
+		}
+		lo, err1 := strconv.Atoi(strings.TrimSpace(from))
+		hi, err2 := strconv.Atoi(strings.TrimSpace(to))
+		if err1 == nil && err2 == nil && port >= lo && port <= hi {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
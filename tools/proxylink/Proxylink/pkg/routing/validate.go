@@ -0,0 +1,161 @@
+package routing
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 域名匹配前缀
+var domainPrefixes = []string{"geosite:", "domain:", "full:", "keyword:", "regexp:", "dotless:", "ext:"}
+
+// 可嗅探的协议
+var knownProtocols = map[string]bool{"http": true, "tls": true, "quic": true, "bittorrent": true}
+
+// Validate 按 Xray 规则校验并规范化 (无前缀的域名补全为 domain:)
+func (r *Rule) Validate() error {
+	if r.Type != "" && r.Type != "field" {
+		return fmt.Errorf("type must be \"field\", got %q", r.Type)
+	}
+	r.Type = "field"
+
+	for i, d := range r.Domain {
+		normalized, err := normalizeDomain(d)
+		if err != nil {
+			return fmt.Errorf("domain %q: %v", d, err)
+		}
+		r.Domain[i] = normalized
+	}
+	for _, list := range []struct {
+		name  string
+		items StringList
+	}{{"ip", r.IP}, {"source", r.Source}, {"localIP", r.LocalIP}} {
+		for _, ip := range list.items {
+			if err := validateIP(ip); err != nil {
+				return fmt.Errorf("%s %q: %v", list.name, ip, err)
+			}
+		}
+	}
+
+	for _, p := range []struct {
+		name string
+		spec PortSpec
+	}{{"port", r.Port}, {"sourcePort", r.SourcePort}, {"localPort", r.LocalPort}, {"vlessRoute", r.VlessRoute}} {
+		if err := validatePorts(string(p.spec)); err != nil {
+			return fmt.Errorf("%s %q: %v", p.name, p.spec, err)
+		}
+	}
+
+	if r.Network != "" {
+		for _, n := range splitList(r.Network) {
+			if n != "tcp" && n != "udp" {
+				return fmt.Errorf("network %q: want tcp, udp or tcp,udp", r.Network)
+			}
+		}
+	}
+	for _, p := range r.Protocol {
+		if !knownProtocols[p] {
+			return fmt.Errorf("protocol %q: want http, tls, quic or bittorrent", p)
+		}
+	}
+	switch r.DomainMatcher {
+	case "", "linear", "mph", "hybrid":
+	default:
+		return fmt.Errorf("domainMatcher %q: want linear, mph or hybrid", r.DomainMatcher)
+	}
+
+	if r.OutboundTag == "" && r.BalancerTag == "" {
+		return fmt.Errorf("missing outboundTag or balancerTag")
+	}
+	if r.OutboundTag != "" && r.BalancerTag != "" {
+		return fmt.Errorf("outboundTag and balancerTag are mutually exclusive")
+	}
+	if !r.hasCondition() {
+		return fmt.Errorf("rule has no matching condition")
+	}
+	return nil
+}
+
+// hasCondition 判断规则是否包含至少一个匹配条件 (Xray 拒绝没有条件的规则)
+func (r *Rule) hasCondition() bool {
+	return len(r.Domain) > 0 || len(r.IP) > 0 || r.Port != "" || r.SourcePort != "" || r.LocalPort != "" ||
+		r.Network != "" || len(r.Source) > 0 || len(r.LocalIP) > 0 || len(r.User) > 0 || r.VlessRoute != "" ||
+		len(r.InboundTag) > 0 || len(r.Protocol) > 0 || len(r.Process) > 0 || len(r.Attrs) > 0
+}
+
+// normalizeDomain 校验域名匹配表达式，无前缀时补全 domain:
+func normalizeDomain(d string) (string, error) {
+	for _, prefix := range domainPrefixes {
+		if !strings.HasPrefix(d, prefix) {
+			continue
+		}
+		value := strings.TrimPrefix(d, prefix)
+		if value == "" && prefix != "dotless:" {
+			return "", fmt.Errorf("empty value after %s", prefix)
+		}
+		switch prefix {
+		case "regexp:":
+			if _, err := regexp.Compile(value); err != nil {
+				return "", err
+			}
+		case "ext:":
+			if !strings.Contains(value, ":") {
+				return "", fmt.Errorf("want ext:file:tag")
+			}
+		}
+		return d, nil
+	}
+	if strings.ContainsAny(d, " /\\") {
+		return "", fmt.Errorf("invalid domain")
+	}
+	return "domain:" + d, nil
+}
+
+// validateIP 校验 IP/CIDR/geoip 表达式
+func validateIP(s string) error {
+	v := strings.TrimPrefix(s, "!")
+	switch {
+	case strings.HasPrefix(v, "geoip:"):
+		if strings.TrimPrefix(v, "geoip:") == "" {
+			return fmt.Errorf("empty geoip code")
+		}
+		return nil
+	case strings.HasPrefix(v, "ext:"):
+		if strings.Count(v, ":") < 2 {
+			return fmt.Errorf("want ext:file:tag")
+		}
+		return nil
+	}
+	if net.ParseIP(v) != nil {
+		return nil
+	}
+	if _, _, err := net.ParseCIDR(v); err != nil {
+		return fmt.Errorf("not an IP, CIDR or geoip")
+	}
+	return nil
+}
+
+// validatePorts 校验端口表达式
+func validatePorts(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		from, to := part, part
+		if idx := strings.Index(part, "-"); idx > 0 {
+			from, to = part[:idx], part[idx+1:]
+		}
+		lo, err1 := strconv.Atoi(strings.TrimSpace(from))
+		hi, err2 := strconv.Atoi(strings.TrimSpace(to))
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("invalid port %q", part)
+		}
+		if lo < 0 || hi > 65535 || lo > hi {
+			return fmt.Errorf("port range %q out of 0-65535", part)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,418 @@
+package tproxy
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"proxylink/pkg/util"
+)
+
+// Mode 透明代理方式
+type Mode string
+
+const (
+	ModeTProxy   Mode = "tproxy"
+	ModeRedirect Mode = "redirect"
+)
+
+// defaults tproxy.sh 中的 DEFAULT_* 值，变量未设置或为空时使用 (与脚本的 ${VAR:-默认值} 一致)
+var defaults = map[string]string{
+	"CORE_USER_GROUP":         "root:net_admin",
+	"ROUTING_MARK":            "",
+	"FORCE_MARK_BYPASS":       "0",
+	"PROXY_TCP_PORT":          "1536",
+	"PROXY_UDP_PORT":          "1536",
+	"PROXY_MODE":              "0",
+	"PERFORMANCE_MODE":        "0",
+	"DNS_HIJACK_ENABLE":       "1",
+	"DNS_PORT":                "1053",
+	"MOBILE_INTERFACE":        "rmnet_data+",
+	"WIFI_INTERFACE":          "wlan0",
+	"HOTSPOT_INTERFACE":       "wlan2",
+	"USB_INTERFACE":           "rndis+",
+	"OTHER_BYPASS_INTERFACES": "",
+	"OTHER_PROXY_INTERFACES":  "",
+	"PROXY_MOBILE":            "1",
+	"PROXY_WIFI":              "1",
+	"PROXY_HOTSPOT":           "0",
+	"PROXY_USB":               "0",
+	"PROXY_TCP":               "1",
+	"PROXY_UDP":               "1",
+	"PROXY_IPV6":              "0",
+	"BYPASS_IPv4_LIST":        "0.0.0.0/8 10.0.0.0/8 100.0.0.0/8 127.0.0.0/8 169.254.0.0/16 172.16.0.0/12 192.0.0.0/24 192.0.2.0/24 192.88.99.0/24 192.168.0.0/16 198.51.100.0/24 203.0.113.0/24 224.0.0.0/4 240.0.0.0/4 255.255.255.255/32",
+	"BYPASS_IPv6_LIST":        "::/128 ::1/128 ::ffff:0:0/96 100::/64 64:ff9b::/96 2001::/32 2001:10::/28 2001:20::/28 2001:db8::/32 2002::/16 fe80::/10 ff00::/8",
+	"PROXY_IPv4_LIST":         "",
+	"PROXY_IPv6_LIST":         "",
+	"HOTSPOT_SUBNET_IPV4":     "192.168.43.0/24",
+	"HOTSPOT_SUBNET_IPV6":     "fe80::/10",
+	"MARK_VALUE":              "20",
+	"MARK_VALUE6":             "25",
+	"TABLE_ID":                "2025",
+	"APP_PROXY_ENABLE":        "0",
+	"PROXY_APPS_LIST":         "",
+	"BYPASS_APPS_LIST":        "",
+	"APP_PROXY_MODE":          "blacklist",
+	"BYPASS_CN_IP":            "0",
+	"MAC_FILTER_ENABLE":       "0",
+	"PROXY_MACS_LIST":         "",
+	"BYPASS_MACS_LIST":        "",
+	"MAC_PROXY_MODE":          "blacklist",
+	"BLOCK_QUIC":              "0",
+}
+
+// Config tproxy.conf 的类型化内容，字段与 tproxy.sh 的变量一一对应
+type Config struct {
+	CoreUser        string `json:"coreUser"`
+	CoreGroup       string `json:"coreGroup"`
+	RoutingMark     string `json:"routingMark,omitempty"` // 值[/掩码]，原样传给 -m mark --mark
+	ForceMarkBypass bool   `json:"forceMarkBypass"`
+	TCPPort         int    `json:"tcpPort"`
+	UDPPort         int    `json:"udpPort"`
+	ProxyMode       int    `json:"proxyMode"` // 0 自动, 1 TPROXY, 2 REDIRECT
+	PerformanceMode bool   `json:"performanceMode"`
+	DNSHijack       int    `json:"dnsHijack"` // 0 禁用, 1 tproxy, 2 redirect
+	DNSPort         int    `json:"dnsPort"`
+
+	MobileInterface       string   `json:"mobileInterface"`
+	WiFiInterface         string   `json:"wifiInterface"`
+	HotspotInterface      string   `json:"hotspotInterface"`
+	USBInterface          string   `json:"usbInterface"`
+	OtherBypassInterfaces []string `json:"otherBypassInterfaces,omitempty"`
+	OtherProxyInterfaces  []string `json:"otherProxyInterfaces,omitempty"`
+
+	ProxyMobile  bool `json:"proxyMobile"`
+	ProxyWiFi    bool `json:"proxyWifi"`
+	ProxyHotspot bool `json:"proxyHotspot"`
+	ProxyUSB     bool `json:"proxyUsb"`
+	ProxyTCP     bool `json:"proxyTcp"`
+	ProxyUDP     bool `json:"proxyUdp"`
+	IPv6         int  `json:"ipv6"` // -1 禁用协议栈, 0 不代理, 1 代理
+
+	BypassIPv4        []string `json:"bypassIPv4,omitempty"`
+	BypassIPv6        []string `json:"bypassIPv6,omitempty"`
+	ProxyIPv4         []string `json:"proxyIPv4,omitempty"`
+	ProxyIPv6         []string `json:"proxyIPv6,omitempty"`
+	HotspotSubnetIPv4 string   `json:"hotspotSubnetIPv4"`
+	HotspotSubnetIPv6 string   `json:"hotspotSubnetIPv6"`
+
+	Mark    string `json:"mark"` // 十进制或 0x 十六进制，原样传给 --tproxy-mark 和 ip rule fwmark
+	Mark6   string `json:"mark6"`
+	TableID int    `json:"tableId"`
+
+	AppProxy     bool     `json:"appProxy"`
+	ProxyApps    []string `json:"proxyApps,omitempty"`
+	BypassApps   []string `json:"bypassApps,omitempty"`
+	AppProxyMode string   `json:"appProxyMode"`
+
+	BypassCNIP   bool     `json:"bypassCnIp"`
+	MACFilter    bool     `json:"macFilter"`
+	ProxyMACs    []string `json:"proxyMacs,omitempty"`
+	BypassMACs   []string `json:"bypassMacs,omitempty"`
+	MACProxyMode string   `json:"macProxyMode"`
+	BlockQUIC    bool     `json:"blockQuic"`
+
+	Warnings []string `json:"warnings,omitempty"` // 脚本会自行修正、不影响启动的问题
+}
+
+// FieldError 一个变量的校验错误
+type FieldError struct {
+	Key    string
+	Value  string
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s=%q: %s", e.Key, e.Value, e.Reason)
+}
+
+// Errors 全部校验错误，一次报告所有问题
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	lines := make([]string, len(e))
+	for i, fe := range e {
+		lines[i] = fe.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Load 读取并校验 tproxy.conf
+func Load(path string) (*Config, error) {
+	vars, err := util.ReadShellVars(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(vars)
+}
+
+// Parse 按 tproxy.sh 的默认值补全变量并逐项校验，返回的错误为 Errors
+func Parse(vars map[string]string) (*Config, error) {
+	p := &varParser{vars: vars}
+	c := &Config{}
+
+	userGroup := p.value("CORE_USER_GROUP")
+	if i := strings.Index(userGroup, ":"); i >= 0 {
+		c.CoreUser, c.CoreGroup = userGroup[:i], userGroup[i+1:]
+		if j := strings.Index(c.CoreGroup, ":"); j >= 0 {
+			c.CoreGroup = c.CoreGroup[:j]
+		}
+	}
+	if c.CoreUser == "" || c.CoreGroup == "" || strings.ContainsAny(userGroup, " \t") {
+		c.Warnings = append(c.Warnings, fmt.Sprintf("CORE_USER_GROUP=%q 无效，tproxy.sh 将使用 root:net_admin", userGroup))
+		c.CoreUser, c.CoreGroup = "root", "net_admin"
+	}
+	c.RoutingMark = p.mark("ROUTING_MARK")
+	c.ForceMarkBypass = p.flag("FORCE_MARK_BYPASS")
+	c.TCPPort = p.port("PROXY_TCP_PORT")
+	c.UDPPort = p.port("PROXY_UDP_PORT")
+	c.ProxyMode = p.intRange("PROXY_MODE", 0, 2)
+	c.PerformanceMode = p.flag("PERFORMANCE_MODE")
+	c.DNSHijack = p.intRange("DNS_HIJACK_ENABLE", 0, 2)
+	c.DNSPort = p.port("DNS_PORT")
+
+	c.MobileInterface = p.iface("MOBILE_INTERFACE")
+	c.WiFiInterface = p.iface("WIFI_INTERFACE")
+	c.HotspotInterface = p.iface("HOTSPOT_INTERFACE")
+	c.USBInterface = p.iface("USB_INTERFACE")
+	c.OtherBypassInterfaces = p.ifaces("OTHER_BYPASS_INTERFACES")
+	c.OtherProxyInterfaces = p.ifaces("OTHER_PROXY_INTERFACES")
+
+	c.ProxyMobile = p.flag("PROXY_MOBILE")
+	c.ProxyWiFi = p.flag("PROXY_WIFI")
+	c.ProxyHotspot = p.flag("PROXY_HOTSPOT")
+	c.ProxyUSB = p.flag("PROXY_USB")
+	c.ProxyTCP = p.flag("PROXY_TCP")
+	c.ProxyUDP = p.flag("PROXY_UDP")
+	c.IPv6 = p.intRange("PROXY_IPV6", -1, 1)
+
+	c.BypassIPv4 = p.cidrs("BYPASS_IPv4_LIST", 4)
+	c.BypassIPv6 = p.cidrs("BYPASS_IPv6_LIST", 6)
+	c.ProxyIPv4 = p.cidrs("PROXY_IPv4_LIST", 4)
+	c.ProxyIPv6 = p.cidrs("PROXY_IPv6_LIST", 6)
+	if s := p.cidrs("HOTSPOT_SUBNET_IPV4", 4); len(s) > 0 {
+		c.HotspotSubnetIPv4 = s[0]
+	}
+	if s := p.cidrs("HOTSPOT_SUBNET_IPV6", 6); len(s) > 0 {
+		c.HotspotSubnetIPv6 = s[0]
+	}
+
+	c.Mark = p.fwmark("MARK_VALUE")
+	c.Mark6 = p.fwmark("MARK_VALUE6")
+	c.TableID = p.intRange("TABLE_ID", 1, 65535)
+
+	c.AppProxy = p.flag("APP_PROXY_ENABLE")
+	c.ProxyApps = strings.Fields(p.value("PROXY_APPS_LIST"))
+	c.BypassApps = strings.Fields(p.value("BYPASS_APPS_LIST"))
+	c.AppProxyMode = p.oneOf("APP_PROXY_MODE", "blacklist", "whitelist")
+
+	c.BypassCNIP = p.flag("BYPASS_CN_IP")
+	c.MACFilter = p.flag("MAC_FILTER_ENABLE")
+	c.ProxyMACs = p.macs("PROXY_MACS_LIST")
+	c.BypassMACs = p.macs("BYPASS_MACS_LIST")
+	c.MACProxyMode = p.oneOf("MAC_PROXY_MODE", "blacklist", "whitelist")
+	c.BlockQUIC = p.flag("BLOCK_QUIC")
+
+	if len(p.errs) > 0 {
+		return c, p.errs
+	}
+	return c, nil
+}
+
+// Mode 按 PROXY_MODE 选择透明代理方式，自动模式假定内核支持 TPROXY
+func (c *Config) Mode() Mode {
+	if c.ProxyMode == 2 {
+		return ModeRedirect
+	}
+	return ModeTProxy
+}
+
+// androidGroups Android 没有 /etc/group，常用组按 AID 查找 (与 busybox setuidgid 一致)
+var androidGroups = map[string]int{
+	"root":      0,
+	"system":    1000,
+	"shell":     2000,
+	"inet":      3003,
+	"net_raw":   3004,
+	"net_admin": 3005,
+}
+
+// CoreGID 返回核心进程组的数字 GID: 数字直接使用，其次查 /etc/group，最后按 Android AID
+func (c *Config) CoreGID() (int, error) {
+	if gid, err := strconv.Atoi(c.CoreGroup); err == nil {
+		return gid, nil
+	}
+	if g, err := user.LookupGroup(c.CoreGroup); err == nil {
+		return strconv.Atoi(g.Gid)
+	}
+	if gid, ok := androidGroups[c.CoreGroup]; ok {
+		return gid, nil
+	}
+	return 0, fmt.Errorf("未知的用户组: %s", c.CoreGroup)
+}
+
+// RoutingMarkValue 返回 ROUTING_MARK 的标记值 (不含掩码)，未设置时为 0
+func (c *Config) RoutingMarkValue() uint32 {
+	value, _, _ := strings.Cut(c.RoutingMark, "/")
+	mark, _ := strconv.ParseUint(value, 0, 32)
+	return uint32(mark)
+}
+
+// varParser 读取变量并收集校验错误
+type varParser struct {
+	vars map[string]string
+	errs Errors
+}
+
+func (p *varParser) value(key string) string {
+	if v := strings.TrimSpace(p.vars[key]); v != "" {
+		return v
+	}
+	return defaults[key]
+}
+
+func (p *varParser) fail(key, value, reason string) {
+	p.errs = append(p.errs, &FieldError{Key: key, Value: value, Reason: reason})
+}
+
+func (p *varParser) intRange(key string, min, max int) int {
+	v := p.value(key)
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		p.fail(key, v, fmt.Sprintf("应为 %d-%d 的整数", min, max))
+		return 0
+	}
+	return n
+}
+
+func (p *varParser) port(key string) int {
+	return p.intRange(key, 1, 65535)
+}
+
+func (p *varParser) flag(key string) bool {
+	v := p.value(key)
+	if v != "0" && v != "1" {
+		p.fail(key, v, "应为 0 或 1")
+	}
+	return v == "1"
+}
+
+func (p *varParser) oneOf(key string, options ...string) string {
+	v := p.value(key)
+	for _, o := range options {
+		if v == o {
+			return v
+		}
+	}
+	p.fail(key, v, "应为 "+strings.Join(options, " 或 "))
+	return options[0]
+}
+
+// mark 校验 iptables 的 值[/掩码] 写法，支持十进制和 0x 十六进制，为空表示未设置
+func (p *varParser) mark(key string) string {
+	v := p.value(key)
+	if v == "" {
+		return ""
+	}
+	for _, part := range strings.SplitN(v, "/", 2) {
+		if _, err := strconv.ParseUint(part, 0, 32); err != nil {
+			p.fail(key, v, "应为 32 位标记值 (可带 /掩码)")
+			return ""
+		}
+	}
+	return v
+}
+
+// fwmarkRegex tproxy.sh 接受的标记写法: 十进制 (不以 0 开头) 或 0x 十六进制
+var fwmarkRegex = regexp.MustCompile(`^(0[xX][0-9a-fA-F]{1,8}|[1-9][0-9]*)$`)
+
+// fwmark 校验 MARK_VALUE/MARK_VALUE6: 与 ROUTING_MARK 一样按 32 位无符号数解析，不带掩码且不为 0
+func (p *varParser) fwmark(key string) string {
+	v := p.value(key)
+	if !fwmarkRegex.MatchString(v) {
+		p.fail(key, v, "应为 1 到 0xffffffff 之间的十进制或 0x 十六进制标记值")
+		return ""
+	}
+	if mark, err := strconv.ParseUint(v, 0, 32); err != nil || mark == 0 {
+		p.fail(key, v, "应为 1 到 0xffffffff 之间的十进制或 0x 十六进制标记值")
+		return ""
+	}
+	return v
+}
+
+// cidrs 校验以空格分隔的地址或网段，必须属于指定协议族
+func (p *varParser) cidrs(key string, family int) []string {
+	var list []string
+	for _, s := range strings.Fields(p.value(key)) {
+		addr, err := parseAddrOrPrefix(s)
+		if err != nil {
+			p.fail(key, s, "无效的地址或网段")
+			continue
+		}
+		if addr.Is4() != (family == 4) {
+			p.fail(key, s, fmt.Sprintf("不是 IPv%d 地址", family))
+			continue
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+func parseAddrOrPrefix(s string) (netip.Addr, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Addr(), err
+	}
+	return netip.ParseAddr(s)
+}
+
+func (p *varParser) iface(key string) string {
+	v := p.value(key)
+	if reason := checkInterface(v); reason != "" {
+		p.fail(key, v, reason)
+	}
+	return v
+}
+
+func (p *varParser) ifaces(key string) []string {
+	var list []string
+	for _, name := range strings.Fields(p.value(key)) {
+		if reason := checkInterface(name); reason != "" {
+			p.fail(key, name, reason)
+			continue
+		}
+		list = append(list, name)
+	}
+	return list
+}
+
+// checkInterface 校验 iptables -i/-o 接受的接口名: 最长 15 个字符，"+" 只能作为结尾的通配符
+func checkInterface(name string) string {
+	switch {
+	case name == "":
+		return "接口名为空"
+	case len(name) > 15:
+		return "接口名超过 15 个字符"
+	case strings.ContainsAny(name, " \t/!\"'"):
+		return "接口名包含非法字符"
+	case strings.Contains(strings.TrimSuffix(name, "+"), "+"):
+		return `"+" 只能出现在接口名末尾`
+	}
+	return ""
+}
+
+func (p *varParser) macs(key string) []string {
+	var list []string
+	for _, s := range strings.Fields(p.value(key)) {
+		if mac, err := net.ParseMAC(s); err != nil || len(mac) != 6 {
+			p.fail(key, s, "无效的 MAC 地址")
+			continue
+		}
+		list = append(list, s)
+	}
+	return list
+}
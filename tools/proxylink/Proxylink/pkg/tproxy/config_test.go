@@ -0,0 +1,133 @@
+package tproxy
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// scriptPath 仓库中的 tproxy.sh，默认值以它为准
+const scriptPath = "../../../../../src/module/scripts/network/tproxy.sh"
+
+// scriptOnly 只有脚本使用、不影响规则的 DEFAULT_* 变量
+var scriptOnly = map[string]bool{
+	"CN_IP_FILE":   true,
+	"CN_IPV6_FILE": true,
+	"CN_IP_URL":    true,
+	"CN_IPV6_URL":  true,
+	"DRY_RUN":      true,
+}
+
+var scriptDefaultRegex = regexp.MustCompile(`(?m)^readonly DEFAULT_(\w+)=(.*)$`)
+
+func TestDefaultsMatchScript(t *testing.T) {
+	data, err := os.ReadFile(scriptPath)
+	if err != nil {
+		t.Skipf("读取 tproxy.sh: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, m := range scriptDefaultRegex.FindAllStringSubmatch(string(data), -1) {
+		key, value := m[1], strings.Trim(m[2], `"`)
+		if scriptOnly[key] {
+			continue
+		}
+		seen[key] = true
+		want, ok := defaults[key]
+		if !ok {
+			t.Errorf("DEFAULT_%s=%q 不在 defaults 中", key, value)
+		} else if want != value {
+			t.Errorf("defaults[%s] = %q, tproxy.sh 为 %q", key, want, value)
+		}
+	}
+	for key := range defaults {
+		if !seen[key] {
+			t.Errorf("defaults[%s] 在 tproxy.sh 中没有对应的 DEFAULT_%s", key, key)
+		}
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	c, err := Parse(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.CoreUser != "root" || c.CoreGroup != "net_admin" {
+		t.Errorf("core = %s:%s", c.CoreUser, c.CoreGroup)
+	}
+	if c.Mark != "20" || c.Mark6 != "25" || c.RoutingMark != "" || c.TableID != 2025 {
+		t.Errorf("marks = %q %q %q, table %d", c.Mark, c.Mark6, c.RoutingMark, c.TableID)
+	}
+	if c.TCPPort != 1536 || c.UDPPort != 1536 || c.DNSPort != 1053 {
+		t.Errorf("ports = %d %d %d", c.TCPPort, c.UDPPort, c.DNSPort)
+	}
+	if c.Mode() != ModeTProxy || c.PerformanceMode || c.IPv6 != 0 {
+		t.Errorf("mode = %s, performance %v, ipv6 %d", c.Mode(), c.PerformanceMode, c.IPv6)
+	}
+	if len(c.BypassIPv4) != 15 || len(c.BypassIPv6) != 12 {
+		t.Errorf("bypass lists = %d/%d entries", len(c.BypassIPv4), len(c.BypassIPv6))
+	}
+	if len(c.Warnings) != 0 {
+		t.Errorf("warnings = %v", c.Warnings)
+	}
+}
+
+func TestParseMarks(t *testing.T) {
+	tests := []struct {
+		value string
+		ok    bool
+	}{
+		{"20", true},
+		{"0x1e", true},
+		{"0X1F", true},
+		{"4294967295", true},
+		{"0xffffffff", true},
+		{"0", false},
+		{"0x0", false},
+		{"017", false},
+		{"4294967296", false},
+		{"0x100000000", false},
+		{"0x10/0xff", false},
+		{"-1", false},
+	}
+	for _, tt := range tests {
+		c, err := Parse(map[string]string{"MARK_VALUE": tt.value})
+		if tt.ok && (err != nil || c.Mark != tt.value) {
+			t.Errorf("MARK_VALUE=%s: mark %q, error %v", tt.value, c.Mark, err)
+		}
+		var errs Errors
+		if !tt.ok && (!errors.As(err, &errs) || len(errs) != 1 || errs[0].Key != "MARK_VALUE") {
+			t.Errorf("MARK_VALUE=%s: error %v, want one MARK_VALUE error", tt.value, err)
+		}
+	}
+
+	c, err := Parse(map[string]string{"ROUTING_MARK": "0x100/0xff00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.RoutingMarkValue(); got != 0x100 {
+		t.Errorf("RoutingMarkValue() = %#x, want 0x100", got)
+	}
+}
+
+func TestLoadFixture(t *testing.T) {
+	c, err := Load("testdata/tproxy/tproxy.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Mark != "0x1e" || c.Mark6 != "0X1F" || c.RoutingMark != "0x100/0xff00" || !c.ForceMarkBypass {
+		t.Errorf("marks = %q %q %q, force %v", c.Mark, c.Mark6, c.RoutingMark, c.ForceMarkBypass)
+	}
+	if c.TCPPort != 12345 || !c.PerformanceMode || c.IPv6 != 1 || c.Mode() != ModeTProxy {
+		t.Errorf("config = %+v", c)
+	}
+
+	c, err = Load("testdata/redirect/tproxy.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Mode() != ModeRedirect || c.Mark != "20" {
+		t.Errorf("mode = %s, mark %q", c.Mode(), c.Mark)
+	}
+}
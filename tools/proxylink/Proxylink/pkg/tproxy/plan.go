@@ -0,0 +1,502 @@
+package tproxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"proxylink/pkg/appgroup"
+)
+
+// Command 计划中的一条 iptables/ip6tables/ip 命令，或打开转发的 echo
+type Command struct {
+	Family int      `json:"family"` // 4 或 6
+	Tool   string   `json:"tool"`   // iptables, ip6tables, ip, echo (写 /proc/sys)
+	Table  string   `json:"table,omitempty"`
+	Args   []string `json:"args"`
+}
+
+// String 输出可直接执行的 shell 命令行 (参数均已校验，不含需要引号的字符)
+func (c Command) String() string {
+	parts := []string{c.Tool}
+	if c.Table != "" {
+		parts = append(parts, "-t", c.Table)
+	}
+	return strings.Join(append(parts, c.Args...), " ")
+}
+
+// PlanOptions 生成计划所需的外部信息
+type PlanOptions struct {
+	Mode       Mode               // 为空时按 PROXY_MODE
+	ProxyUIDs  []int              // PROXY_APPS_LIST 解析出的 UID (白名单)
+	BypassUIDs []int              // BYPASS_APPS_LIST 解析出的 UID (黑名单)
+	AppGroups  []appgroup.Mapping // app_groups.conf 内容
+}
+
+// Plan 与 tproxy.sh start 顺序一致的完整规则集
+type Plan struct {
+	Mode     Mode      `json:"mode"`
+	Commands []Command `json:"commands"`
+	Warnings []string  `json:"warnings,omitempty"`
+}
+
+// BuildPlan 按 tproxy.sh start 的流程生成规则，假定所需内核特性和 cnip/cnip6 ipset 均可用
+// (与 tproxy.sh --dry-run 一致)
+func BuildPlan(c *Config, opts PlanOptions) *Plan {
+	mode := opts.Mode
+	if mode == "" {
+		mode = c.Mode()
+	}
+	plan := &Plan{Mode: mode}
+	families := []int{4}
+	if c.IPv6 == 1 {
+		families = append(families, 6)
+	}
+	for _, family := range families {
+		b := newChainBuilder(c, opts, plan, family, mode)
+		b.proxyChain()
+		if mode == ModeTProxy {
+			b.routing()
+		}
+	}
+
+	loopback := func(family int, addr string) {
+		plan.add(family, "filter", "-A", "OUTPUT", "-d", addr, "-p", "tcp", "-m", "owner", "--uid-owner", c.CoreUser, "--gid-owner", c.CoreGroup,
+			"-m", "tcp", "--dport", strconv.Itoa(c.TCPPort), "-j", "REJECT")
+	}
+	loopback(6, "::1")
+	loopback(4, "127.0.0.1")
+
+	if c.BlockQUIC {
+		for _, family := range families {
+			set := "cnip"
+			if family == 6 {
+				set = "cnip6"
+			}
+			for _, chain := range []string{"INPUT", "FORWARD", "OUTPUT"} {
+				args := []string{"-A", chain, "-p", "udp", "--dport", "443"}
+				if c.BypassCNIP {
+					args = append(args, "-m", "set", "!", "--match-set", set, "dst")
+				}
+				plan.add(family, "filter", append(args, "-j", "REJECT")...)
+			}
+		}
+	}
+	return plan
+}
+
+// add 追加一条 iptables/ip6tables 命令
+func (p *Plan) add(family int, table string, args ...string) {
+	tool := "iptables"
+	if family == 6 {
+		tool = "ip6tables"
+	}
+	p.Commands = append(p.Commands, Command{Family: family, Tool: tool, Table: table, Args: args})
+}
+
+// addIP 追加一条 ip 命令
+func (p *Plan) addIP(family int, args ...string) {
+	if family == 6 {
+		args = append([]string{"-6"}, args...)
+	}
+	p.Commands = append(p.Commands, Command{Family: family, Tool: "ip", Args: args})
+}
+
+// addSysctl 追加一条写 /proc/sys 的 echo 命令 (与 tproxy.sh 相同的写法)
+func (p *Plan) addSysctl(family int, path string) {
+	p.Commands = append(p.Commands, Command{Family: family, Tool: "echo", Args: []string{"1", ">", path}})
+}
+
+func (p *Plan) warn(format string, a ...interface{}) {
+	p.Warnings = append(p.Warnings, fmt.Sprintf(format, a...))
+}
+
+// Filter 只保留指定协议族的命令
+func (p *Plan) Filter(family int) *Plan {
+	out := &Plan{Mode: p.Mode, Warnings: p.Warnings}
+	for _, cmd := range p.Commands {
+		if cmd.Family == family {
+			out.Commands = append(out.Commands, cmd)
+		}
+	}
+	return out
+}
+
+// Restore 输出指定协议族的 iptables-restore 格式，供 iptables-restore --noflush 使用:
+// 新建链写成 ":链 - [0:0]" (--noflush 下已存在的链会被清空)，按表分段并保持规则顺序;
+// ip rule/route 和转发开关无法通过 iptables-restore 执行，以注释列在末尾
+func (p *Plan) Restore(family int) string {
+	var tables []string
+	chains := map[string][]string{}
+	rules := map[string][]string{}
+	var others []string
+	for _, cmd := range p.Commands {
+		if cmd.Family != family {
+			continue
+		}
+		if cmd.Table == "" {
+			others = append(others, cmd.String())
+			continue
+		}
+		if _, ok := rules[cmd.Table]; !ok {
+			tables = append(tables, cmd.Table)
+			rules[cmd.Table] = nil
+		}
+		switch cmd.Args[0] {
+		case "-N":
+			chains[cmd.Table] = append(chains[cmd.Table], cmd.Args[1])
+		case "-F":
+		default:
+			rules[cmd.Table] = append(rules[cmd.Table], strings.Join(cmd.Args, " "))
+		}
+	}
+
+	var b strings.Builder
+	for _, table := range tables {
+		fmt.Fprintf(&b, "*%s\n", table)
+		for _, chain := range chains[table] {
+			fmt.Fprintf(&b, ":%s - [0:0]\n", chain)
+		}
+		for _, rule := range rules[table] {
+			b.WriteString(rule + "\n")
+		}
+		b.WriteString("COMMIT\n")
+	}
+	for _, line := range others {
+		b.WriteString("# " + line + "\n")
+	}
+	return b.String()
+}
+
+// chainBuilder 生成一个协议族的代理链，对应 tproxy.sh 的 setup_proxy_chain
+type chainBuilder struct {
+	c      *Config
+	opts   PlanOptions
+	plan   *Plan
+	family int
+	mode   Mode
+	suffix string
+	table  string
+	mark   string
+}
+
+func newChainBuilder(c *Config, opts PlanOptions, plan *Plan, family int, mode Mode) *chainBuilder {
+	b := &chainBuilder{c: c, opts: opts, plan: plan, family: family, mode: mode, table: "mangle", mark: c.Mark}
+	if family == 6 {
+		b.suffix = "6"
+		b.mark = c.Mark6
+	}
+	if mode == ModeRedirect {
+		b.table = "nat"
+	}
+	return b
+}
+
+// chain 返回带协议族后缀的链名
+func (b *chainBuilder) chain(name string) string {
+	return name + b.suffix
+}
+
+// add 在当前表的链 (自动加后缀) 末尾追加规则
+func (b *chainBuilder) add(chain string, args ...string) {
+	b.plan.add(b.family, b.table, append([]string{"-A", b.chain(chain)}, args...)...)
+}
+
+// create 新建并清空链，对应 safe_chain_create
+func (b *chainBuilder) create(table, chain string) {
+	b.plan.add(b.family, table, "-N", chain)
+	b.plan.add(b.family, table, "-F", chain)
+}
+
+func (b *chainBuilder) proxyChain() {
+	c := b.c
+	for _, name := range []string{"PROXY_PREROUTING", "PROXY_OUTPUT", "DIVERT", "PROXY_IP", "BYPASS_IP", "BYPASS_INTERFACE",
+		"PROXY_INTERFACE", "DNS_HIJACK_PRE", "DNS_HIJACK_OUT", "APP_CHAIN", "MAC_CHAIN"} {
+		b.create(b.table, b.chain(name))
+	}
+
+	if c.PerformanceMode {
+		b.add("DIVERT", "-j", "MARK", "--set-mark", b.mark)
+		b.add("DIVERT", "-j", "ACCEPT")
+		b.add("PROXY_PREROUTING", "-p", "tcp", "-m", "socket", "--transparent", "-j", b.chain("DIVERT"))
+	}
+
+	b.add("PROXY_PREROUTING", "-m", "conntrack", "--ctdir", "REPLY", "-j", "ACCEPT")
+	b.add("PROXY_OUTPUT", "-m", "conntrack", "--ctdir", "REPLY", "-j", "ACCEPT")
+
+	if c.ForceMarkBypass && c.RoutingMark != "" {
+		b.add("PROXY_PREROUTING", "-m", "mark", "--mark", c.RoutingMark, "-j", "ACCEPT")
+		b.add("PROXY_OUTPUT", "-m", "mark", "--mark", c.RoutingMark, "-j", "ACCEPT")
+	} else {
+		b.add("PROXY_OUTPUT", "-m", "owner", "--uid-owner", c.CoreUser, "--gid-owner", c.CoreGroup, "-j", "ACCEPT")
+	}
+
+	preJumps := []string{"PROXY_IP", "BYPASS_IP", "PROXY_INTERFACE", "MAC_CHAIN", "DNS_HIJACK_PRE"}
+	outJumps := []string{"PROXY_IP", "BYPASS_IP", "BYPASS_INTERFACE", "APP_CHAIN", "DNS_HIJACK_OUT"}
+	for _, hook := range []struct {
+		chain string
+		jumps []string
+	}{{"PROXY_PREROUTING", preJumps}, {"PROXY_OUTPUT", outJumps}} {
+		if !c.PerformanceMode {
+			for _, jump := range hook.jumps {
+				b.add(hook.chain, "-j", b.chain(jump))
+			}
+			continue
+		}
+		for _, jump := range hook.jumps {
+			b.add(hook.chain, "-p", "tcp", "--syn", "-j", b.chain(jump))
+		}
+		for _, jump := range hook.jumps {
+			b.add(hook.chain, "-p", "udp", "-m", "conntrack", "--ctstate", "NEW,RELATED", "-j", b.chain(jump))
+		}
+	}
+
+	proxyList, bypassList, subnet, ipset := c.ProxyIPv4, c.BypassIPv4, c.HotspotSubnetIPv4, "cnip"
+	if b.family == 6 {
+		proxyList, bypassList, subnet, ipset = c.ProxyIPv6, c.BypassIPv6, c.HotspotSubnetIPv6, "cnip6"
+	}
+	for _, cidr := range proxyList {
+		b.add("PROXY_IP", "-d", cidr, "-j", "RETURN")
+	}
+
+	b.add("BYPASS_IP", "-m", "addrtype", "--dst-type", "LOCAL", "-p", "udp", "!", "--dport", "53", "-j", "ACCEPT")
+	b.add("BYPASS_IP", "-m", "addrtype", "--dst-type", "LOCAL", "!", "-p", "udp", "-j", "ACCEPT")
+	for _, cidr := range bypassList {
+		b.add("BYPASS_IP", "-d", cidr, "-p", "udp", "!", "--dport", "53", "-j", "ACCEPT")
+		b.add("BYPASS_IP", "-d", cidr, "!", "-p", "udp", "-j", "ACCEPT")
+	}
+	if c.BypassCNIP {
+		b.add("BYPASS_IP", "-m", "set", "--match-set", ipset, "dst", "-p", "udp", "!", "--dport", "53", "-j", "ACCEPT")
+		b.add("BYPASS_IP", "-m", "set", "--match-set", ipset, "dst", "!", "-p", "udp", "-j", "ACCEPT")
+	}
+
+	b.interfaces(subnet)
+	b.macFilter()
+	b.appGroups("uid")
+	b.appFilter()
+	if c.DNSHijack != 0 {
+		switch {
+		case b.mode == ModeRedirect:
+			b.dnsHijack("redirect")
+		case c.DNSHijack == 2:
+			b.dnsHijack("redirect2")
+		default:
+			b.dnsHijack("tproxy")
+		}
+	}
+	b.appGroups("port")
+
+	tcpPort, udpPort := strconv.Itoa(c.TCPPort), strconv.Itoa(c.UDPPort)
+	switch {
+	case c.PerformanceMode && b.mode == ModeTProxy:
+		b.add("PROXY_PREROUTING", "-m", "conntrack", "--ctstate", "NEW,RELATED", "-j", "CONNMARK", "--set-mark", b.mark)
+		b.add("PROXY_PREROUTING", "-p", "tcp", "-m", "connmark", "--mark", b.mark, "-j", "TPROXY", "--on-port", tcpPort, "--tproxy-mark", b.mark)
+		b.add("PROXY_PREROUTING", "-p", "udp", "-m", "connmark", "--mark", b.mark, "-j", "TPROXY", "--on-port", udpPort, "--tproxy-mark", b.mark)
+		b.add("PROXY_OUTPUT", "-m", "conntrack", "--ctstate", "NEW,RELATED", "-j", "CONNMARK", "--set-mark", b.mark)
+		b.add("PROXY_OUTPUT", "-m", "connmark", "--mark", b.mark, "-j", "MARK", "--set-mark", b.mark)
+	case c.PerformanceMode:
+		b.add("PROXY_PREROUTING", "-m", "conntrack", "--ctstate", "NEW,RELATED", "-j", "CONNMARK", "--set-mark", b.mark)
+		b.add("PROXY_PREROUTING", "-m", "connmark", "--mark", b.mark, "-j", "REDIRECT", "--to-ports", tcpPort)
+		b.add("PROXY_OUTPUT", "-m", "conntrack", "--ctstate", "NEW,RELATED", "-j", "CONNMARK", "--set-mark", b.mark)
+		b.add("PROXY_OUTPUT", "-m", "connmark", "--mark", b.mark, "-j", "REDIRECT", "--to-ports", tcpPort)
+	case b.mode == ModeTProxy:
+		b.add("PROXY_PREROUTING", "-p", "tcp", "-j", "TPROXY", "--on-port", tcpPort, "--tproxy-mark", b.mark)
+		b.add("PROXY_PREROUTING", "-p", "udp", "-j", "TPROXY", "--on-port", udpPort, "--tproxy-mark", b.mark)
+		b.add("PROXY_OUTPUT", "-j", "MARK", "--set-mark", b.mark)
+	default:
+		b.add("PROXY_PREROUTING", "-j", "REDIRECT", "--to-ports", tcpPort)
+		b.add("PROXY_OUTPUT", "-j", "REDIRECT", "--to-ports", tcpPort)
+	}
+
+	if c.ProxyUDP || b.mode == ModeRedirect {
+		b.plan.add(b.family, b.table, "-I", "PREROUTING", "-p", "udp", "-j", b.chain("PROXY_PREROUTING"))
+		b.plan.add(b.family, b.table, "-I", "OUTPUT", "-p", "udp", "-j", b.chain("PROXY_OUTPUT"))
+	}
+	if c.ProxyTCP {
+		b.plan.add(b.family, b.table, "-I", "PREROUTING", "-p", "tcp", "-j", b.chain("PROXY_PREROUTING"))
+		b.plan.add(b.family, b.table, "-I", "OUTPUT", "-p", "tcp", "-j", b.chain("PROXY_OUTPUT"))
+	}
+}
+
+// interfaces 入站接口放行/代理和出站接口绕过
+func (b *chainBuilder) interfaces(subnet string) {
+	c := b.c
+	b.add("PROXY_INTERFACE", "-i", "lo", "-j", "RETURN")
+	iface := func(name string, proxy bool, match ...string) {
+		if proxy {
+			b.add("PROXY_INTERFACE", append(append([]string{"-i", name}, match...), "-j", "RETURN")...)
+			return
+		}
+		b.add("PROXY_INTERFACE", append(append([]string{"-i", name}, match...), "-j", "ACCEPT")...)
+		if len(match) == 0 {
+			b.add("BYPASS_INTERFACE", "-o", name, "-j", "ACCEPT")
+		}
+	}
+
+	iface(c.MobileInterface, c.ProxyMobile)
+	if c.HotspotInterface == c.WiFiInterface {
+		// 热点与 WiFi 共用接口时按热点子网区分; 热点绕过不影响本机经该接口的出站
+		iface(c.HotspotInterface, c.ProxyHotspot, "-s", subnet)
+		iface(c.WiFiInterface, c.ProxyWiFi, "!", "-s", subnet)
+		if !c.ProxyWiFi {
+			b.add("BYPASS_INTERFACE", "-o", c.WiFiInterface, "-j", "ACCEPT")
+		}
+	} else {
+		iface(c.WiFiInterface, c.ProxyWiFi)
+		iface(c.HotspotInterface, c.ProxyHotspot)
+	}
+	iface(c.USBInterface, c.ProxyUSB)
+	for _, name := range c.OtherProxyInterfaces {
+		iface(name, true)
+	}
+	for _, name := range c.OtherBypassInterfaces {
+		iface(name, false)
+	}
+}
+
+// macFilter 热点设备的 MAC 黑白名单
+func (b *chainBuilder) macFilter() {
+	c := b.c
+	if !c.MACFilter || !c.ProxyHotspot || c.HotspotInterface == "" {
+		return
+	}
+	if c.MACProxyMode == "whitelist" {
+		if len(c.ProxyMACs) == 0 {
+			b.plan.warn("MAC 白名单模式未配置 PROXY_MACS_LIST，热点设备均不代理")
+		}
+		for _, mac := range c.ProxyMACs {
+			b.add("MAC_CHAIN", "-m", "mac", "--mac-source", mac, "-i", c.HotspotInterface, "-j", "RETURN")
+		}
+		b.add("MAC_CHAIN", "-i", c.HotspotInterface, "-j", "ACCEPT")
+		return
+	}
+	if len(c.BypassMACs) == 0 {
+		b.plan.warn("MAC 黑名单模式未配置 BYPASS_MACS_LIST")
+	}
+	for _, mac := range c.BypassMACs {
+		b.add("MAC_CHAIN", "-m", "mac", "--mac-source", mac, "-i", c.HotspotInterface, "-j", "ACCEPT")
+	}
+	b.add("MAC_CHAIN", "-i", c.HotspotInterface, "-j", "RETURN")
+}
+
+// appFilter 分应用代理黑白名单
+func (b *chainBuilder) appFilter() {
+	c := b.c
+	if !c.AppProxy {
+		return
+	}
+	if c.AppProxyMode == "whitelist" {
+		if len(c.ProxyApps) == 0 {
+			b.plan.warn("应用白名单模式未配置 PROXY_APPS_LIST，所有应用均不代理")
+		}
+		for _, uid := range b.opts.ProxyUIDs {
+			b.add("APP_CHAIN", "-m", "owner", "--uid-owner", strconv.Itoa(uid), "-j", "RETURN")
+		}
+		b.add("APP_CHAIN", "-j", "ACCEPT")
+		return
+	}
+	if len(c.BypassApps) == 0 {
+		b.plan.warn("应用黑名单模式未配置 BYPASS_APPS_LIST")
+	}
+	for _, uid := range b.opts.BypassUIDs {
+		b.add("APP_CHAIN", "-m", "owner", "--uid-owner", strconv.Itoa(uid), "-j", "ACCEPT")
+	}
+	b.add("APP_CHAIN", "-j", "RETURN")
+}
+
+// appGroups 应用分组，对应 setup_app_groups:
+// uid 阶段在 APP_CHAIN 中标记分组应用的连接，port 阶段把标记的连接转到分组端口
+func (b *chainBuilder) appGroups(stage string) {
+	if len(b.opts.AppGroups) == 0 || (b.mode == ModeRedirect && stage == "port") {
+		return
+	}
+	seen := map[int]bool{}
+	for _, m := range b.opts.AppGroups {
+		if b.mode == ModeTProxy && b.c.markClash(m.Port) {
+			if stage == "uid" && b.family == 4 {
+				b.plan.warn("分组 %s 的端口 %d 与 MARK_VALUE、MARK_VALUE6 或 ROUTING_MARK 相同，已跳过", m.Group, m.Port)
+			}
+			continue
+		}
+		uid, port := strconv.Itoa(m.UID), strconv.Itoa(m.Port)
+		switch {
+		case stage == "uid" && b.mode == ModeRedirect:
+			b.add("APP_CHAIN", "-m", "owner", "--uid-owner", uid, "-j", "REDIRECT", "--to-ports", port)
+		case stage == "uid":
+			// 分组端口同时作为 connmark 值，与 MARK_VALUE 区分
+			b.add("APP_CHAIN", "-m", "owner", "--uid-owner", uid, "-j", "CONNMARK", "--set-mark", port)
+			b.add("APP_CHAIN", "-m", "owner", "--uid-owner", uid, "-j", "RETURN")
+		case !seen[m.Port]:
+			seen[m.Port] = true
+			b.add("PROXY_PREROUTING", "-p", "tcp", "-m", "connmark", "--mark", port, "-j", "TPROXY", "--on-port", port, "--tproxy-mark", b.mark)
+			b.add("PROXY_PREROUTING", "-p", "udp", "-m", "connmark", "--mark", port, "-j", "TPROXY", "--on-port", port, "--tproxy-mark", b.mark)
+			b.add("PROXY_OUTPUT", "-m", "connmark", "--mark", port, "-j", "MARK", "--set-mark", b.mark)
+			b.add("PROXY_OUTPUT", "-m", "connmark", "--mark", port, "-j", "ACCEPT")
+		}
+	}
+}
+
+// markClash 与 app_group_mark_clash 一致: 分组端口作为 connmark 值时不能与任一标记相同
+func (c *Config) markClash(port int) bool {
+	for key, value := range map[string]string{"MARK_VALUE": c.Mark, "MARK_VALUE6": c.Mark6, "ROUTING_MARK": c.RoutingMark} {
+		if value == "" {
+			continue
+		}
+		if m, err := appgroup.ParseMark(key, value); err == nil && m.Matches(port) {
+			return true
+		}
+	}
+	return false
+}
+
+// dnsHijack DNS 劫持，对应 setup_dns_hijack
+func (b *chainBuilder) dnsHijack(method string) {
+	c := b.c
+	dnsPort := strconv.Itoa(c.DNSPort)
+	switch method {
+	case "tproxy":
+		b.add("DNS_HIJACK_PRE", "-j", "RETURN")
+		b.add("DNS_HIJACK_OUT", "-j", "RETURN")
+	case "redirect":
+		for _, chain := range []string{"PROXY_PREROUTING", "PROXY_OUTPUT"} {
+			b.add(chain, "-p", "tcp", "--dport", "53", "-j", "REDIRECT", "--to-ports", dnsPort)
+			b.add(chain, "-p", "udp", "--dport", "53", "-j", "REDIRECT", "--to-ports", dnsPort)
+		}
+	case "redirect2":
+		hijack := b.chain("NAT_DNS_HIJACK")
+		b.create("nat", hijack)
+		b.plan.add(b.family, "nat", "-A", hijack, "-p", "tcp", "--dport", "53", "-j", "REDIRECT", "--to-ports", dnsPort)
+		b.plan.add(b.family, "nat", "-A", hijack, "-p", "udp", "--dport", "53", "-j", "REDIRECT", "--to-ports", dnsPort)
+
+		var ifaces []string
+		if c.ProxyMobile {
+			ifaces = append(ifaces, c.MobileInterface)
+		}
+		if c.ProxyWiFi {
+			ifaces = append(ifaces, c.WiFiInterface)
+		}
+		if c.ProxyUSB {
+			ifaces = append(ifaces, c.USBInterface)
+		}
+		for _, name := range append(ifaces, c.OtherProxyInterfaces...) {
+			b.plan.add(b.family, "nat", "-A", "PREROUTING", "-i", name, "-j", hijack)
+		}
+		for _, proto := range []string{"udp", "tcp"} {
+			b.plan.add(b.family, "nat", "-A", "OUTPUT", "-p", proto, "--dport", "53", "-m", "owner",
+				"--uid-owner", c.CoreUser, "--gid-owner", c.CoreGroup, "-j", "ACCEPT")
+		}
+		b.plan.add(b.family, "nat", "-A", "OUTPUT", "-j", hijack)
+	}
+}
+
+// routing 让带标记的包走本地路由表并打开转发，对应 setup_routing4/6
+func (b *chainBuilder) routing() {
+	table := strconv.Itoa(b.c.TableID)
+	local, forward := "0.0.0.0/0", "/proc/sys/net/ipv4/ip_forward"
+	if b.family == 6 {
+		local, forward = "::/0", "/proc/sys/net/ipv6/conf/all/forwarding"
+	}
+	b.plan.addIP(b.family, "rule", "add", "fwmark", b.mark, "table", table, "pref", table)
+	b.plan.addIP(b.family, "route", "add", "local", local, "dev", "lo", "table", table)
+	b.plan.addSysctl(b.family, forward)
+}
@@ -0,0 +1,142 @@
+package tproxy
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"proxylink/pkg/appgroup"
+)
+
+// loadPlan 按 testdata/<name> 中的 tproxy.conf 和 app_groups.conf 生成计划
+func loadPlan(t *testing.T, name string) *Plan {
+	t.Helper()
+	c, err := Load("testdata/" + name + "/tproxy.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups, err := appgroup.ReadMap("testdata/" + name + "/" + appgroup.MapName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return BuildPlan(c, PlanOptions{AppGroups: groups})
+}
+
+func readGolden(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// lines 计划的命令行，与 proxylink tproxy plan 的默认输出相同
+func lines(p *Plan) []string {
+	out := make([]string, len(p.Commands))
+	for i, cmd := range p.Commands {
+		out[i] = cmd.String()
+	}
+	return out
+}
+
+// index 返回第一条包含全部片段的命令的位置，没有时为 -1
+func index(cmds []string, parts ...string) int {
+next:
+	for i, cmd := range cmds {
+		for _, part := range parts {
+			if !strings.Contains(cmd, part) {
+				continue next
+			}
+		}
+		return i
+	}
+	return -1
+}
+
+// TestPlanFixtures plan.txt 为 tproxy.sh start 在 DRY_RUN=1 下对同一配置输出的命令
+// (INPUT/FORWARD 的 filter 表写法除外)
+func TestPlanFixtures(t *testing.T) {
+	for _, name := range []string{"tproxy", "redirect"} {
+		got := strings.Join(lines(loadPlan(t, name)), "\n") + "\n"
+		if want := readGolden(t, "testdata/"+name+"/plan.txt"); got != want {
+			t.Errorf("%s: plan differs from testdata/%s/plan.txt:\n%s", name, name, got)
+		}
+	}
+}
+
+func TestPlanModes(t *testing.T) {
+	tp := lines(loadPlan(t, "tproxy"))
+	if index(tp, "-j TPROXY --on-port 12345 --tproxy-mark 0x1e") < 0 || index(tp, "ip6tables", "--tproxy-mark 0X1F") < 0 {
+		t.Error("tproxy: missing TPROXY rules with the hex marks")
+	}
+	if index(tp, "ip rule add fwmark 0x1e table 2025") < 0 || index(tp, "echo 1 > /proc/sys/net/ipv6/conf/all/forwarding") < 0 {
+		t.Error("tproxy: missing routing or forwarding commands")
+	}
+	if index(tp, "-t nat") >= 0 {
+		t.Error("tproxy: unexpected nat rules")
+	}
+
+	rd := lines(loadPlan(t, "redirect"))
+	if index(rd, "-t nat -A PROXY_PREROUTING -j REDIRECT --to-ports 12345") < 0 {
+		t.Error("redirect: missing REDIRECT rule")
+	}
+	if index(rd, "-t mangle") >= 0 || index(rd, "ip rule") >= 0 || index(rd, "ip6tables -t nat") >= 0 {
+		t.Error("redirect: unexpected mangle, ip rule or IPv6 rules")
+	}
+	// REDIRECT 模式不使用 connmark，端口与标记相同的分组照常生效
+	if index(rd, "APP_CHAIN -m owner --uid-owner 10300 -j REDIRECT --to-ports 30") < 0 {
+		t.Error("redirect: missing app group redirect")
+	}
+}
+
+func TestPlanPerformanceOrder(t *testing.T) {
+	p := loadPlan(t, "tproxy")
+	cmds := lines(p)
+	for _, family := range []struct{ suffix, mark string }{{"", "0x1e"}, {"6", "0X1F"}} {
+		pre := "PROXY_PREROUTING" + family.suffix
+		setMark := index(cmds, "-A "+pre+" -m conntrack --ctstate NEW,RELATED -j CONNMARK --set-mark "+family.mark)
+		generic := index(cmds, "-A "+pre+" -p tcp -m connmark --mark "+family.mark+" -j TPROXY --on-port 12345")
+		group := index(cmds, "-A "+pre+" -p tcp -m connmark --mark 12346 -j TPROXY --on-port 12346")
+		jump := index(cmds, "-I PREROUTING -p tcp -j "+pre)
+		if setMark < 0 || generic < 0 || group < 0 || jump < 0 {
+			t.Fatalf("IPv%s: missing rules (%d %d %d %d)", family.suffix, setMark, generic, group, jump)
+		}
+		// 分组的 TPROXY 规则在通用规则之前，否则分组连接会进入 tproxy-in
+		if !(group < setMark && setMark < generic && generic < jump) {
+			t.Errorf("IPv%s: order group %d, CONNMARK %d, TPROXY %d, jump %d", family.suffix, group, setMark, generic, jump)
+		}
+		// 性能模式下 PREROUTING 只把新连接的首包送入分类链
+		if index(cmds, "-A "+pre+" -p tcp --syn -j PROXY_IP"+family.suffix) < 0 {
+			t.Errorf("IPv%s: missing --syn jump to PROXY_IP", family.suffix)
+		}
+	}
+
+	// 端口 30 与 MARK_VALUE=0x1e 相同，分组被跳过并给出警告
+	if index(cmds, "--uid-owner 10300") >= 0 || index(cmds, "--mark 30 ") >= 0 {
+		t.Error("clashing app group was not skipped")
+	}
+	if len(p.Warnings) != 1 || !strings.Contains(p.Warnings[0], "分组 bad 的端口 30") {
+		t.Errorf("warnings = %q", p.Warnings)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	p := loadPlan(t, "tproxy")
+	got := p.Restore(4)
+	if want := readGolden(t, "testdata/tproxy/restore4.txt"); got != want {
+		t.Errorf("Restore(4) differs from testdata/tproxy/restore4.txt:\n%s", got)
+	}
+
+	// --noflush 下用 ":链" 声明清空新链，不输出 -N/-F；ip 与 echo 命令以注释列在末尾
+	v6 := p.Restore(6)
+	if strings.Contains(v6, "-N ") || strings.Contains(v6, "-F ") || strings.Contains(v6, "-A PROXY_OUTPUT ") {
+		t.Error("Restore(6) contains -N/-F or IPv4 rules")
+	}
+	if !strings.HasPrefix(v6, "*mangle\n:PROXY_PREROUTING6 - [0:0]\n") {
+		t.Errorf("Restore(6) starts with %q", v6[:40])
+	}
+	if !strings.HasSuffix(v6, "COMMIT\n# ip -6 rule add fwmark 0X1F table 2025 pref 2025\n# ip -6 route add local ::/0 dev lo table 2025\n# echo 1 > /proc/sys/net/ipv6/conf/all/forwarding\n") {
+		t.Errorf("Restore(6) tail:\n%s", v6[len(v6)-200:])
+	}
+}
@@ -0,0 +1,52 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// OrderedObject 按 original 中的字段顺序输出对象，新增字段按名称排在最后
+// 用于修改配置中的单个对象时保持用户文件原有的字段顺序
+func OrderedObject(fields map[string]json.RawMessage, original json.RawMessage) json.RawMessage {
+	var order []string
+	dec := json.NewDecoder(bytes.NewReader(original))
+	if tok, err := dec.Token(); err == nil && tok == json.Delim('{') {
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				break
+			}
+			order = append(order, key.(string))
+			var skip json.RawMessage
+			if dec.Decode(&skip) != nil {
+				break
+			}
+		}
+	}
+	seen := map[string]bool{}
+	var rest []string
+	for key := range fields {
+		rest = append(rest, key)
+	}
+	sort.Strings(rest)
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, key := range append(order, rest...) {
+		value, ok := fields[key]
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
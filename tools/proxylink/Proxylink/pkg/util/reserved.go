@@ -0,0 +1,65 @@
+package util
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var hexReservedRegex = regexp.MustCompile(`^(0x)?[0-9a-fA-F]{6}$`)
+
+// ParseReserved 解析 WireGuard reserved 字段为 3 个字节
+// 支持以下格式:
+// 1. 逗号分隔的十进制: "1,2,3" 或 "[1, 2, 3]"
+// 2. 十六进制字符串: "0a0b0c" 或 "0x0a0b0c"
+// 3. WARP client_id (Base64): "AQID"
+func ParseReserved(s string) ([]int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	var bytes []byte
+
+	switch {
+	case strings.Contains(s, ","):
+		s = strings.Trim(s, "[]")
+		for _, part := range strings.Split(s, ",") {
+			part = strings.TrimSpace(part)
+			v, err := strconv.Atoi(part)
+			if err != nil || v < 0 || v > 255 {
+				return nil, fmt.Errorf("invalid reserved byte %q", part)
+			}
+			bytes = append(bytes, byte(v))
+		}
+	case hexReservedRegex.MatchString(s):
+		decoded, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid reserved hex %q", s)
+		}
+		bytes = decoded
+	default:
+		decoded, err := Base64Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reserved %q: not decimal, hex or base64", s)
+		}
+		bytes = []byte(decoded)
+	}
+
+	if len(bytes) != 3 {
+		return nil, fmt.Errorf("invalid reserved %q: need 3 bytes, got %d", s, len(bytes))
+	}
+
+	return []int{int(bytes[0]), int(bytes[1]), int(bytes[2])}, nil
+}
+
+// NormalizeReserved 将 reserved 字段规范化为 "a,b,c" 格式
+func NormalizeReserved(s string) (string, error) {
+	values, err := ParseReserved(s)
+	if err != nil || values == nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d,%d,%d", values[0], values[1], values[2]), nil
+}
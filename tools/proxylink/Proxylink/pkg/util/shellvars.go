@@ -0,0 +1,48 @@
+package util
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// ReadShellVars 读取 shell 风格的 KEY="value" 配置 (tproxy.conf / module.conf)
+// 忽略注释和空行，去掉值两侧的引号和行尾注释
+func ReadShellVars(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		idx := strings.Index(line, "=")
+		if idx <= 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:idx])
+		vars[key] = unquote(strings.TrimSpace(line[idx+1:]))
+	}
+	return vars, scanner.Err()
+}
+
+// unquote 去掉引号，未加引号的值截断行尾注释
+func unquote(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') {
+		if end := strings.IndexByte(v[1:], v[0]); end >= 0 {
+			return v[1 : end+1]
+		}
+	}
+	if idx := strings.Index(v, " #"); idx >= 0 {
+		v = v[:idx]
+	}
+	return strings.TrimSpace(v)
+}
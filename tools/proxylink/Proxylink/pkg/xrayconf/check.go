@@ -0,0 +1,231 @@
+package xrayconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RoutingRule 检查引用关系所需的路由规则字段
+// Name/Enabled 仅出现在 WebUI 的 routing_rules.json 中
+type RoutingRule struct {
+	Name        string          `json:"name,omitempty"`
+	Enabled     *bool           `json:"enabled,omitempty"`
+	RuleTag     string          `json:"ruleTag,omitempty"`
+	InboundTag  []string        `json:"inboundTag,omitempty"`
+	OutboundTag string          `json:"outboundTag,omitempty"`
+	BalancerTag string          `json:"balancerTag,omitempty"`
+	Raw         json.RawMessage `json:"-"`
+}
+
+// Balancer 负载均衡器
+type Balancer struct {
+	Tag         string   `json:"tag"`
+	Selector    []string `json:"selector"`
+	FallbackTag string   `json:"fallbackTag,omitempty"`
+}
+
+// Routing routing 顶层字段
+type Routing struct {
+	DomainStrategy string        `json:"domainStrategy,omitempty"`
+	Rules          []RoutingRule `json:"rules"`
+	Balancers      []Balancer    `json:"balancers,omitempty"`
+}
+
+// DecodeRouting 解析 routing 字段
+func DecodeRouting(raw json.RawMessage) (*Routing, error) {
+	var routing Routing
+	if err := json.Unmarshal(raw, &routing); err != nil {
+		return nil, err
+	}
+
+	var rules struct {
+		Rules []json.RawMessage `json:"rules"`
+	}
+	if err := json.Unmarshal(raw, &rules); err == nil && len(rules.Rules) == len(routing.Rules) {
+		for i := range routing.Rules {
+			routing.Rules[i].Raw = rules.Rules[i]
+		}
+	}
+	return &routing, nil
+}
+
+// OutboundTags 返回出站 tag 集合
+func (c *Config) OutboundTags() map[string]bool {
+	tags := map[string]bool{}
+	for _, d := range c.Outbounds {
+		tags[d.Tag] = true
+	}
+	return tags
+}
+
+// InboundTags 返回可在 inboundTag 中引用的 tag: 入站、api 以及 DNS 服务器的 tag
+func (c *Config) InboundTags() map[string]bool {
+	tags := map[string]bool{}
+	for _, d := range c.Inbounds {
+		tags[d.Tag] = true
+	}
+	if raw, ok := c.Fields["api"]; ok {
+		var api struct {
+			Tag string `json:"tag"`
+		}
+		if json.Unmarshal(raw, &api) == nil && api.Tag != "" {
+			tags[api.Tag] = true
+		}
+	}
+	if raw, ok := c.Fields["dns"]; ok {
+		if dns, err := DecodeDNS(raw); err == nil {
+			if dns.Tag != "" {
+				tags[dns.Tag] = true
+			}
+			for _, s := range dns.Servers {
+				if s.Tag != "" {
+					tags[s.Tag] = true
+				}
+			}
+		}
+	}
+	return tags
+}
+
+// CheckRouting 检查路由规则引用的出站和负载均衡器是否存在
+func CheckRouting(c *Config) []Issue {
+	raw, ok := c.Fields["routing"]
+	if !ok {
+		return nil
+	}
+	source := c.Sources["routing"]
+
+	routing, err := DecodeRouting(raw)
+	if err != nil {
+		return []Issue{{Level: LevelError, Check: IssueSyntax, Source: source, Path: "routing", Message: fmt.Sprintf("routing: %v", err)}}
+	}
+	return CheckRoutingRefs(routing, c.OutboundTags(), c.InboundTags(), source, "routing")
+}
+
+// CheckRoutingRefs 检查路由对出站/入站/负载均衡器的引用
+// base 为 routing 对象在文件中的 JSON 路径，用于定位问题；为空时表示规则数组位于文件根部 (routing_rules.json)
+func CheckRoutingRefs(routing *Routing, outbounds, inbounds map[string]bool, source, base string) []Issue {
+	var issues []Issue
+	add := func(level, check, path, format string, args ...interface{}) {
+		issues = append(issues, Issue{Level: level, Check: check, Source: source, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	balancers := map[string]bool{}
+	for i, b := range routing.Balancers {
+		path := fmt.Sprintf("%s.balancers[%d]", base, i)
+		balancers[b.Tag] = true
+
+		members := 0
+		for tag := range outbounds {
+			if matchSelector(tag, b.Selector) {
+				members++
+			}
+		}
+		if members == 0 {
+			add(LevelError, IssueEmptyBalancer, path+".selector", "balancer %q 的 selector %v 没有匹配任何出站", b.Tag, b.Selector)
+		}
+		if b.FallbackTag != "" && !outbounds[b.FallbackTag] {
+			add(LevelError, IssueMissingRef, path+".fallbackTag", "balancer %q 的 fallbackTag %q 不存在", b.Tag, b.FallbackTag)
+		}
+	}
+
+	rulesPath := ""
+	if base != "" {
+		rulesPath = base + ".rules"
+	}
+	for i, r := range routing.Rules {
+		if r.Enabled != nil && !*r.Enabled {
+			continue
+		}
+		path := fmt.Sprintf("%s[%d]", rulesPath, i)
+		name := ruleName(i, r)
+		switch {
+		case r.OutboundTag != "" && r.BalancerTag != "":
+			add(LevelWarn, IssueMissingRef, path, "%s 同时设置了 outboundTag 和 balancerTag", name)
+		case r.OutboundTag == "" && r.BalancerTag == "":
+			add(LevelError, IssueMissingRef, path, "%s 缺少 outboundTag/balancerTag", name)
+		}
+		if r.OutboundTag != "" && !outbounds[r.OutboundTag] {
+			add(LevelError, IssueMissingRef, path+".outboundTag", "%s 指向不存在的出站 %q", name, r.OutboundTag)
+		}
+		if r.BalancerTag != "" && !balancers[r.BalancerTag] {
+			add(LevelError, IssueMissingRef, path+".balancerTag", "%s 指向不存在的负载均衡器 %q", name, r.BalancerTag)
+		}
+		if inbounds != nil {
+			for _, tag := range r.InboundTag {
+				if !inbounds[tag] {
+					add(LevelWarn, IssueMissingRef, path+".inboundTag", "%s 的 inboundTag %q 没有对应入站", name, tag)
+				}
+			}
+		}
+	}
+	return issues
+}
+
+// CheckInboundPort 检查指定入站端口是否与期望一致
+func CheckInboundPort(c *Config, tag string, want int) []Issue {
+	idx := findTag(c.Inbounds, tag)
+	if idx < 0 {
+		return []Issue{{Level: LevelError, Check: IssueInboundPort, Message: fmt.Sprintf("缺少入站 %q", tag)}}
+	}
+	in := c.Inbounds[idx]
+
+	port, err := InboundPort(in.Raw)
+	if err != nil {
+		return []Issue{{Level: LevelError, Check: IssueInboundPort, Source: in.Source, Message: fmt.Sprintf("入站 %q: %v", tag, err)}}
+	}
+	if port != want {
+		return []Issue{{Level: LevelError, Check: IssueInboundPort, Source: in.Source, Message: fmt.Sprintf("入站 %q 端口 %d 与 tproxy.conf 的 PROXY_TCP_PORT=%d 不一致", tag, port, want)}}
+	}
+	return nil
+}
+
+// InboundPort 读取入站端口 (数字或数字字符串)
+func InboundPort(raw json.RawMessage) (int, error) {
+	var in struct {
+		Port json.RawMessage `json:"port"`
+	}
+	if err := json.Unmarshal(raw, &in); err != nil {
+		return 0, err
+	}
+	s := strings.Trim(string(in.Port), `"`)
+	port, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("无法识别的端口 %s", in.Port)
+	}
+	return port, nil
+}
+
+// ruleName 规则的显示名称
+func ruleName(i int, r RoutingRule) string {
+	if r.Name != "" {
+		return fmt.Sprintf("规则 #%d (%s)", i+1, r.Name)
+	}
+	if r.RuleTag != "" {
+		return fmt.Sprintf("规则 #%d (%s)", i+1, r.RuleTag)
+	}
+	return fmt.Sprintf("规则 #%d", i+1)
+}
+
+// matchSelector 判断 tag 是否匹配 selector (前缀匹配)
+func matchSelector(tag string, selector []string) bool {
+	for _, s := range selector {
+		if strings.HasPrefix(tag, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Candidates 返回 selector 匹配的出站 tag
+func (b Balancer) Candidates(outbounds []Detour) []string {
+	var tags []string
+	for _, d := range outbounds {
+		if matchSelector(d.Tag, b.Selector) {
+			tags = append(tags, d.Tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,100 @@
+package xrayconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// DNSServer DNS 服务器，兼容字符串和对象两种写法
+type DNSServer struct {
+	Address string   `json:"address"`
+	Port    int      `json:"port,omitempty"`
+	Domains []string `json:"domains,omitempty"`
+	Tag     string   `json:"tag,omitempty"`
+}
+
+func (s *DNSServer) UnmarshalJSON(data []byte) error {
+	var addr string
+	if err := json.Unmarshal(data, &addr); err == nil {
+		*s = DNSServer{Address: addr}
+		return nil
+	}
+	type alias DNSServer
+	return json.Unmarshal(data, (*alias)(s))
+}
+
+// DNS dns 顶层字段
+type DNS struct {
+	Hosts   map[string]json.RawMessage `json:"hosts,omitempty"`
+	Servers []DNSServer                `json:"servers,omitempty"`
+	Tag     string                     `json:"tag,omitempty"`
+}
+
+// DecodeDNS 解析 dns 字段
+func DecodeDNS(raw json.RawMessage) (*DNS, error) {
+	var dns DNS
+	if err := json.Unmarshal(raw, &dns); err != nil {
+		return nil, err
+	}
+	return &dns, nil
+}
+
+// ServerHost 返回 DNS 服务器地址中的主机名 (localhost/fakedns 返回空)
+func ServerHost(address string) string {
+	switch address {
+	case "", "localhost", "fakedns":
+		return ""
+	}
+	if strings.Contains(address, "://") {
+		u, err := url.Parse(address)
+		if err != nil {
+			return ""
+		}
+		return u.Hostname()
+	}
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
+
+// CheckDNSHosts 检查使用域名的 DNS 服务器是否在 hosts 中有静态地址
+// 否则 Xray 需要先用其他 DNS 解析该域名，启动初期或该 DNS 不可用时会解析失败
+func CheckDNSHosts(dns *DNS, source, base string) []Issue {
+	var issues []Issue
+	for i, s := range dns.Servers {
+		host := ServerHost(s.Address)
+		if host == "" || net.ParseIP(host) != nil || hostsContains(dns.Hosts, host) {
+			continue
+		}
+		issues = append(issues, Issue{
+			Level:   LevelWarn,
+			Check:   IssueDNSHosts,
+			Source:  source,
+			Path:    fmt.Sprintf("%s.servers[%d]", base, i),
+			Message: fmt.Sprintf("DNS 服务器 %s 的域名 %s 不在 hosts 中，需要先由其他 DNS 解析", s.Address, host),
+		})
+	}
+	return issues
+}
+
+// hostsContains 判断 hosts 是否覆盖该域名 (纯字符串/full: 完整匹配，domain: 子域名匹配)
+func hostsContains(hosts map[string]json.RawMessage, host string) bool {
+	host = strings.ToLower(host)
+	for key := range hosts {
+		k := strings.ToLower(key)
+		switch {
+		case k == host, k == "full:"+host:
+			return true
+		case strings.HasPrefix(k, "domain:"):
+			d := strings.TrimPrefix(k, "domain:")
+			if host == d || strings.HasSuffix(host, "."+d) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,327 @@
+package xrayconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LintOptions lint 参数
+type LintOptions struct {
+	Root       string            // config/xray 目录
+	TproxyVars map[string]string // tproxy.conf 变量，用于端口冲突检查 (可为空)
+}
+
+// LintResult lint 结果
+type LintResult struct {
+	Files    int     `json:"files"`
+	Errors   int     `json:"errors"`
+	Warnings int     `json:"warnings"`
+	Issues   []Issue `json:"issues"`
+}
+
+// lintFile 已解析的文件
+type lintFile struct {
+	rel  string
+	data []byte
+	file *File
+}
+
+// Lint 检查 config/xray 目录下的全部配置
+func Lint(opts LintOptions) (*LintResult, error) {
+	paths, err := jsonFiles(opts.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LintResult{Files: len(paths)}
+	var issues []Issue
+	files := map[string]*lintFile{}
+
+	// 语法与字段检查
+	for _, path := range paths {
+		rel, _ := filepath.Rel(opts.Root, path)
+		rel = filepath.ToSlash(rel)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			issues = append(issues, Issue{Level: LevelError, Check: IssueSyntax, Source: rel, Message: err.Error()})
+			continue
+		}
+
+		lf := &lintFile{rel: rel, data: data}
+		files[rel] = lf
+
+		var root json.RawMessage
+		if err := json.Unmarshal(trimBOM(data), &root); err != nil {
+			issue := Issue{Level: LevelError, Check: IssueSyntax, Source: rel, Message: err.Error()}
+			if off, ok := errorOffset(err); ok {
+				issue.Line, issue.Column = LineCol(trimBOM(data), off)
+			}
+			issues = append(issues, issue)
+			continue
+		}
+
+		switch {
+		case filepath.Base(rel) == "_meta.json":
+			// 订阅元数据，不是 Xray 配置
+		case filepath.Base(rel) == "routing_rules.json":
+			issues = append(issues, checkFields(webuiRulesSchema, root, "", rel)...)
+		default:
+			f, err := ParseFile(rel, data)
+			if err != nil {
+				issues = append(issues, Issue{Level: LevelError, Check: IssueSyntax, Source: rel, Message: "顶层必须是 JSON 对象"})
+				continue
+			}
+			lf.file = f
+			issues = append(issues, checkFields(configSchema, root, "", rel)...)
+			if raw, ok := f.Fields["dns"]; ok {
+				if dns, err := DecodeDNS(raw); err == nil {
+					issues = append(issues, CheckDNSHosts(dns, rel, "dns")...)
+				}
+			}
+		}
+	}
+
+	issues = append(issues, lintReferences(files)...)
+	issues = append(issues, lintPorts(files, opts.TproxyVars)...)
+	issues = dedupeIssues(issues)
+
+	for rel, lf := range files {
+		Locate(issues, rel, trimBOM(lf.data))
+	}
+	sortIssues(issues)
+
+	for _, issue := range issues {
+		switch issue.Level {
+		case LevelError:
+			result.Errors++
+		case LevelWarn:
+			result.Warnings++
+		}
+	}
+	if issues == nil {
+		issues = []Issue{}
+	}
+	result.Issues = issues
+	return result, nil
+}
+
+// lintReferences 按 service.sh 的加载方式合并各路由文件，检查 tag 引用
+// 出站使用 routing/internal/proxy_freedom.json 作为 "proxy" 占位 (与 switch-mode.sh 一致)；
+// 包含 routing 的节点文件 (如负载均衡输出) 按该节点合并检查
+func lintReferences(files map[string]*lintFile) []Issue {
+	var confdir []*File
+	var routings, nodes []*File
+	var placeholder *File
+	var webuiRules *lintFile
+
+	for _, rel := range sortedKeys(files) {
+		lf := files[rel]
+		switch {
+		case filepath.Base(rel) == "routing_rules.json":
+			webuiRules = lf
+		case lf.file == nil:
+		case rel == "confdir/routing/internal/proxy_freedom.json":
+			placeholder = lf.file
+		case strings.HasPrefix(rel, "confdir/routing/") && !strings.Contains(strings.TrimPrefix(rel, "confdir/routing/"), "/"):
+			routings = append(routings, lf.file)
+		case strings.HasPrefix(rel, "confdir/") && !strings.Contains(strings.TrimPrefix(rel, "confdir/"), "/"):
+			confdir = append(confdir, lf.file)
+		case strings.HasPrefix(rel, "outbounds/"):
+			if _, ok := lf.file.Fields["routing"]; ok {
+				nodes = append(nodes, lf.file)
+			}
+		}
+	}
+
+	var issues []Issue
+	check := func(files []*File) *Config {
+		cfg, mergeIssues := Merge(files)
+		for _, issue := range mergeIssues {
+			if issue.Level == LevelError {
+				issues = append(issues, issue)
+			}
+		}
+		issues = append(issues, CheckRouting(cfg)...)
+		return cfg
+	}
+
+	var ruleConfig *Config
+	for _, r := range routings {
+		files := []*File{r}
+		if placeholder != nil {
+			files = append(files, placeholder)
+		}
+		cfg := check(append(files, confdir...))
+		if filepath.Base(r.Path) == "rule.json" {
+			ruleConfig = cfg
+		}
+	}
+	for _, n := range nodes {
+		check(append([]*File{n}, confdir...))
+	}
+
+	// routing_rules.json 编译为 rule.json，按 rule.json 的出站/入站检查引用
+	if webuiRules != nil && ruleConfig != nil {
+		var rules []RoutingRule
+		if json.Unmarshal(trimBOM(webuiRules.data), &rules) == nil {
+			routing := &Routing{Rules: rules}
+			issues = append(issues, CheckRoutingRefs(routing, ruleConfig.OutboundTags(), ruleConfig.InboundTags(), webuiRules.rel, "")...)
+		}
+	}
+	return issues
+}
+
+// lintPorts 检查 api 监听端口与入站、tproxy.conf 端口是否冲突
+func lintPorts(files map[string]*lintFile, tproxyVars map[string]string) []Issue {
+	type listener struct {
+		name   string
+		source string
+		path   string
+		port   int
+	}
+	var listeners []listener
+	var api *listener
+	byTag := map[string]int{}
+
+	for _, rel := range sortedKeys(files) {
+		lf := files[rel]
+		if lf.file == nil || !strings.HasPrefix(rel, "confdir/") || strings.Contains(strings.TrimPrefix(rel, "confdir/"), "/") {
+			continue
+		}
+		if raw, ok := lf.file.Fields["api"]; ok {
+			var a struct {
+				Listen string `json:"listen"`
+			}
+			if json.Unmarshal(raw, &a) == nil && a.Listen != "" {
+				if _, p, err := net.SplitHostPort(a.Listen); err == nil {
+					if port, err := strconv.Atoi(p); err == nil {
+						api = &listener{"api " + a.Listen, rel, "api.listen", port}
+					}
+				}
+			}
+		}
+		inbounds, _ := decodeDetours(lf.file, "inbounds")
+		for i, in := range inbounds {
+			port, err := InboundPort(in.Raw)
+			if err != nil {
+				continue
+			}
+			l := listener{fmt.Sprintf("入站 %q", in.Tag), rel, fmt.Sprintf("inbounds[%d].port", i), port}
+			// 后加载的同 tag 入站替换前面的 (与 Merge 一致)，不算冲突
+			if idx, ok := byTag[in.Tag]; ok && in.Tag != "" {
+				listeners[idx] = l
+				continue
+			}
+			byTag[in.Tag] = len(listeners)
+			listeners = append(listeners, l)
+		}
+	}
+
+	// DNS 劫持的 REDIRECT 模式会把 53 端口重定向到 DNS_PORT，同样不能与 api 冲突
+	if port, err := strconv.Atoi(tproxyVars["DNS_PORT"]); err == nil {
+		listeners = append(listeners, listener{"tproxy.conf DNS_PORT", "", "", port})
+	}
+
+	var issues []Issue
+	if api != nil {
+		for _, l := range listeners {
+			if l.port == api.port {
+				issues = append(issues, Issue{Level: LevelError, Check: IssuePortConflict, Source: api.source, Path: api.path,
+					Message: fmt.Sprintf("%s 的端口 %d 与 %s 冲突", api.name, api.port, l.name)})
+			}
+		}
+	}
+
+	for i := 0; i < len(listeners); i++ {
+		for j := i + 1; j < len(listeners); j++ {
+			a, b := listeners[i], listeners[j]
+			if a.port == b.port && a.source != "" && b.source != "" {
+				issues = append(issues, Issue{Level: LevelError, Check: IssuePortConflict, Source: b.source, Path: b.path,
+					Message: fmt.Sprintf("%s 的端口 %d 与 %s 冲突", b.name, b.port, a.name)})
+			}
+		}
+	}
+
+	// 透明代理入站端口需与 tproxy.conf 一致
+	for _, key := range []string{"PROXY_TCP_PORT", "PROXY_UDP_PORT"} {
+		want, err := strconv.Atoi(tproxyVars[key])
+		if err != nil {
+			continue
+		}
+		for _, l := range listeners {
+			if l.name == `入站 "tproxy-in"` && l.port != want {
+				issues = append(issues, Issue{Level: LevelError, Check: IssueInboundPort, Source: l.source, Path: l.path,
+					Message: fmt.Sprintf("%s 端口 %d 与 tproxy.conf 的 %s=%d 不一致", l.name, l.port, key, want)})
+			}
+		}
+	}
+	return issues
+}
+
+// jsonFiles 递归列出目录下的 .json 文件
+func jsonFiles(root string) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.EqualFold(filepath.Ext(path), ".json") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	sort.Strings(paths)
+	return paths, err
+}
+
+// errorOffset 取 JSON 错误的偏移量
+func errorOffset(err error) (int64, bool) {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return e.Offset, true
+	case *json.UnmarshalTypeError:
+		return e.Offset, true
+	}
+	return 0, false
+}
+
+// dedupeIssues 去掉重复的问题 (多个路由文件合并同一 confdir 时会重复报告)
+func dedupeIssues(issues []Issue) []Issue {
+	seen := map[string]bool{}
+	var result []Issue
+	for _, issue := range issues {
+		key := issue.Level + "|" + issue.Source + "|" + issue.Path + "|" + issue.Message
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, issue)
+	}
+	return result
+}
+
+// sortIssues 按文件和行号排序
+func sortIssues(issues []Issue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.Line < b.Line
+	})
+}
+
+func sortedKeys(files map[string]*lintFile) []string {
+	keys := make([]string, 0, len(files))
+	for k := range files {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
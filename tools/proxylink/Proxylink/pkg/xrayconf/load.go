@@ -0,0 +1,92 @@
+package xrayconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// File 单个 Xray 配置文件，顶层字段保留原始 JSON
+type File struct {
+	Path   string
+	Fields map[string]json.RawMessage
+}
+
+// LoadFile 读取配置文件，语法错误附带行列号
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFile(path, data)
+}
+
+// ParseFile 解析配置内容
+func ParseFile(path string, data []byte) (*File, error) {
+	data = trimBOM(data)
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, DescribeJSONError(data, err))
+	}
+	return &File{Path: path, Fields: fields}, nil
+}
+
+// ConfdirFiles 按 Xray -confdir 的规则列出目录下的 .json 文件 (不递归，按文件名排序)
+func ConfdirFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".json") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// trimBOM 去掉 UTF-8 BOM
+func trimBOM(data []byte) []byte {
+	return bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+}
+
+// DescribeJSONError 将 JSON 错误的偏移量转换为行列号
+func DescribeJSONError(data []byte, err error) error {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	default:
+		return err
+	}
+	line, col := LineCol(data, offset)
+	return fmt.Errorf("line %d, column %d: %v", line, col, err)
+}
+
+// LineCol 计算字节偏移对应的行列号 (从 1 开始)
+func LineCol(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line, col := 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
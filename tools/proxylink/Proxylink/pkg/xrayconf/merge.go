@@ -0,0 +1,211 @@
+package xrayconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// 问题级别
+const (
+	LevelError = "error"
+	LevelWarn  = "warn"
+	LevelInfo  = "info"
+)
+
+// 检查项名称 (Issue.Check)
+const (
+	IssueSyntax        = "syntax"
+	IssueUnknownField  = "unknown-field"
+	IssueDuplicateTag  = "duplicate-tag"
+	IssueMissingRef    = "missing-ref"
+	IssueEmptyBalancer = "empty-balancer"
+	IssueDNSHosts      = "dns-hosts"
+	IssuePortConflict  = "port-conflict"
+	IssueInboundPort   = "inbound-port"
+)
+
+// Issue 合并或检查时发现的问题
+// Path 为问题所在的 JSON 路径 (如 routing.rules[3].outboundTag)，Line/Column 由调用方按需定位
+type Issue struct {
+	Level   string `json:"level"`
+	Check   string `json:"check,omitempty"`
+	Source  string `json:"file,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	loc := ""
+	if i.Source != "" {
+		loc = i.Source
+		if i.Line > 0 {
+			loc += fmt.Sprintf(":%d:%d", i.Line, i.Column)
+		}
+		loc += ": "
+	}
+	return fmt.Sprintf("[%s] %s%s", i.Level, loc, i.Message)
+}
+
+// Detour 入站或出站，Source 记录生效配置的来源文件
+type Detour struct {
+	Tag    string
+	Source string
+	Raw    json.RawMessage
+}
+
+// Config 合并后的配置
+type Config struct {
+	Fields    map[string]json.RawMessage // inbounds/outbounds 以外的顶层字段
+	Sources   map[string]string          // 顶层字段的来源文件
+	Inbounds  []Detour
+	Outbounds []Detour
+}
+
+// Merge 按 Xray 多配置合并规则合并文件 (files 顺序即加载顺序)
+// - inbounds/outbounds 以外的顶层字段: 后加载的整体覆盖
+// - inbounds: 同 tag 替换，否则追加
+// - outbounds: 同 tag 替换，否则插入到最前 (文件名含 "tail" 时追加到末尾)
+func Merge(files []*File) (*Config, []Issue) {
+	cfg := &Config{
+		Fields:  map[string]json.RawMessage{},
+		Sources: map[string]string{},
+	}
+	var issues []Issue
+
+	for _, f := range files {
+		for key, raw := range f.Fields {
+			switch key {
+			case "inbounds", "outbounds":
+				continue
+			}
+			if prev, ok := cfg.Sources[key]; ok {
+				issues = append(issues, Issue{Level: LevelInfo, Source: f.Path, Message: fmt.Sprintf("%s 覆盖了 %s 中的 %s", filepath.Base(f.Path), filepath.Base(prev), key)})
+			}
+			cfg.Fields[key] = raw
+			cfg.Sources[key] = f.Path
+		}
+
+		inbounds, err := decodeDetours(f, "inbounds")
+		if err != nil {
+			issues = append(issues, Issue{Level: LevelError, Source: f.Path, Message: err.Error()})
+		}
+		issues = append(issues, duplicateTags(f.Path, "inbound", inbounds)...)
+		for _, d := range inbounds {
+			if idx := findTag(cfg.Inbounds, d.Tag); idx >= 0 {
+				issues = append(issues, overrideIssue(f.Path, "inbound", cfg.Inbounds[idx]))
+				cfg.Inbounds[idx] = d
+			} else {
+				cfg.Inbounds = append(cfg.Inbounds, d)
+			}
+		}
+
+		outbounds, err := decodeDetours(f, "outbounds")
+		if err != nil {
+			issues = append(issues, Issue{Level: LevelError, Source: f.Path, Message: err.Error()})
+		}
+		issues = append(issues, duplicateTags(f.Path, "outbound", outbounds)...)
+		tail := strings.Contains(strings.ToLower(filepath.Base(f.Path)), "tail")
+		var prepends []Detour
+		for _, d := range outbounds {
+			if idx := findTag(cfg.Outbounds, d.Tag); idx >= 0 {
+				issues = append(issues, overrideIssue(f.Path, "outbound", cfg.Outbounds[idx]))
+				cfg.Outbounds[idx] = d
+			} else if tail {
+				cfg.Outbounds = append(cfg.Outbounds, d)
+			} else {
+				prepends = append(prepends, d)
+			}
+		}
+		if len(prepends) > 0 {
+			cfg.Outbounds = append(prepends, cfg.Outbounds...)
+		}
+	}
+
+	return cfg, issues
+}
+
+// MarshalJSON 输出单个完整配置
+func (c *Config) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{}
+	for k, v := range c.Fields {
+		out[k] = v
+	}
+	if len(c.Inbounds) > 0 {
+		out["inbounds"] = rawDetours(c.Inbounds)
+	}
+	if len(c.Outbounds) > 0 {
+		out["outbounds"] = rawDetours(c.Outbounds)
+	}
+	return json.Marshal(out)
+}
+
+// decodeDetours 读取文件中的 inbounds/outbounds 数组
+func decodeDetours(f *File, key string) ([]Detour, error) {
+	raw, ok := f.Fields[key]
+	if !ok {
+		return nil, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("%s 必须是数组: %v", key, err)
+	}
+
+	detours := make([]Detour, 0, len(items))
+	for _, item := range items {
+		var head struct {
+			Tag string `json:"tag"`
+		}
+		if err := json.Unmarshal(item, &head); err != nil {
+			return nil, fmt.Errorf("%s: %v", key, err)
+		}
+		detours = append(detours, Detour{Tag: head.Tag, Source: f.Path, Raw: item})
+	}
+	return detours, nil
+}
+
+// duplicateTags 检查同一文件内重复的 tag (Xray 启动时报错)
+func duplicateTags(source, kind string, detours []Detour) []Issue {
+	var issues []Issue
+	seen := map[string]bool{}
+	for _, d := range detours {
+		if d.Tag == "" {
+			continue
+		}
+		if seen[d.Tag] {
+			issues = append(issues, Issue{Level: LevelError, Check: IssueDuplicateTag, Source: source, Message: fmt.Sprintf("重复的 %s tag %q", kind, d.Tag)})
+		}
+		seen[d.Tag] = true
+	}
+	return issues
+}
+
+// overrideIssue 跨文件同 tag 替换的提示
+func overrideIssue(source, kind string, prev Detour) Issue {
+	return Issue{Level: LevelWarn, Check: IssueDuplicateTag, Source: source, Message: fmt.Sprintf("%s tag %q 替换了 %s 中的同名配置", kind, prev.Tag, filepath.Base(prev.Source))}
+}
+
+// findTag 按 tag 查找，空 tag 不参与匹配
+func findTag(detours []Detour, tag string) int {
+	if tag == "" {
+		return -1
+	}
+	for i, d := range detours {
+		if d.Tag == tag {
+			return i
+		}
+	}
+	return -1
+}
+
+func rawDetours(detours []Detour) []json.RawMessage {
+	raws := make([]json.RawMessage, len(detours))
+	for i, d := range detours {
+		raws[i] = d.Raw
+	}
+	return raws
+}
@@ -0,0 +1,131 @@
+package xrayconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// posFrame JSON 遍历中的容器层级
+type posFrame struct {
+	object    bool
+	key       string
+	index     int
+	expectKey bool
+}
+
+// KeyOffsets 返回每个 JSON 路径 (如 routing.rules[3].outboundTag) 在内容中的字节偏移
+func KeyOffsets(data []byte) map[string]int64 {
+	offsets := map[string]int64{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var stack []*posFrame
+	path := func() string {
+		var sb strings.Builder
+		for _, f := range stack {
+			if f.object {
+				if f.expectKey {
+					break
+				}
+				if sb.Len() > 0 {
+					sb.WriteByte('.')
+				}
+				sb.WriteString(f.key)
+			} else {
+				fmt.Fprintf(&sb, "[%d]", f.index)
+			}
+		}
+		return sb.String()
+	}
+	afterValue := func() {
+		if len(stack) == 0 {
+			return
+		}
+		top := stack[len(stack)-1]
+		if top.object {
+			top.expectKey = true
+		} else {
+			top.index++
+		}
+	}
+
+	for {
+		start := dec.InputOffset()
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+
+		if n := len(stack); n > 0 && stack[n-1].object && stack[n-1].expectKey {
+			top := stack[n-1]
+			if d, ok := tok.(json.Delim); ok && d == '}' {
+				stack = stack[:n-1]
+				afterValue()
+				continue
+			}
+			top.key, _ = tok.(string)
+			top.expectKey = false
+			offsets[path()] = keyStart(data, start)
+			continue
+		}
+
+		if n := len(stack); n > 0 && !stack[n-1].object {
+			if d, ok := tok.(json.Delim); !ok || d != ']' {
+				offsets[path()] = keyStart(data, start)
+			}
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, &posFrame{object: true, expectKey: true})
+		case json.Delim('['):
+			stack = append(stack, &posFrame{})
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			afterValue()
+		default:
+			afterValue()
+		}
+	}
+	return offsets
+}
+
+// keyStart 跳过分隔符和空白，返回 token 的起始偏移
+func keyStart(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) {
+		switch data[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+// Locate 为问题填充行列号 (按 Path 查找，找不到时逐级回退到父路径)
+func Locate(issues []Issue, source string, data []byte) {
+	offsets := KeyOffsets(data)
+	for i := range issues {
+		if issues[i].Source != source || issues[i].Line > 0 || issues[i].Path == "" {
+			continue
+		}
+		for p := issues[i].Path; p != ""; p = parentPath(p) {
+			if off, ok := offsets[p]; ok {
+				issues[i].Line, issues[i].Column = LineCol(data, off)
+				break
+			}
+		}
+	}
+}
+
+// parentPath 返回上一级路径
+func parentPath(p string) string {
+	idx := strings.LastIndexAny(p, ".[")
+	if idx < 0 {
+		return ""
+	}
+	return p[:idx]
+}
@@ -0,0 +1,186 @@
+package xrayconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// schema 字段白名单，nil 表示不检查内部字段
+type schema struct {
+	fields map[string]*schema
+	items  *schema
+}
+
+func object(fields map[string]*schema) *schema { return &schema{fields: fields} }
+func array(item *schema) *schema               { return &schema{items: item} }
+
+// keys 构造只检查字段名的对象
+func keys(names ...string) *schema {
+	fields := map[string]*schema{}
+	for _, n := range names {
+		fields[n] = nil
+	}
+	return object(fields)
+}
+
+// with 在对象 schema 基础上增加字段
+func (s *schema) with(fields map[string]*schema) *schema {
+	merged := map[string]*schema{}
+	for k, v := range s.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return object(merged)
+}
+
+var (
+	ruleSchema = keys("type", "domain", "domains", "ip", "port", "sourcePort", "localPort", "network",
+		"source", "sourceIP", "user", "vlessRoute", "inboundTag", "protocol", "attrs", "outboundTag",
+		"balancerTag", "ruleTag", "localIP", "process", "domainMatcher")
+
+	balancerSchema = keys("tag", "selector", "fallbackTag", "strategy")
+
+	dnsServerSchema = keys("address", "port", "domains", "expectIPs", "expectedIPs", "unexpectedIPs",
+		"skipFallback", "clientIP", "queryStrategy", "tag", "timeoutMs", "disableCache", "finalQuery",
+		"serveStale", "serveExpiredTTL")
+
+	inboundSchema = object(map[string]*schema{
+		"tag": nil, "port": nil, "listen": nil, "protocol": nil, "settings": nil,
+		"streamSettings": nil, "allocate": nil,
+		"sniffing": keys("enabled", "destOverride", "metadataOnly", "domainsExcluded", "routeOnly"),
+	})
+
+	outboundSchema = keys("tag", "protocol", "settings", "streamSettings", "proxySettings", "mux",
+		"sendThrough", "targetStrategy")
+
+	// configSchema Xray 配置顶层结构
+	configSchema = object(map[string]*schema{
+		"log": keys("access", "error", "loglevel", "dnsLog", "maskAddress"),
+		"api": keys("tag", "listen", "services"),
+		"dns": object(map[string]*schema{
+			"hosts": nil, "servers": array(dnsServerSchema), "clientIp": nil, "queryStrategy": nil,
+			"disableCache": nil, "disableFallback": nil, "disableFallbackIfMatch": nil, "tag": nil,
+			"useSystemHosts": nil, "serveStale": nil, "serveExpiredTTL": nil, "enableParallelQuery": nil,
+		}),
+		"routing": object(map[string]*schema{
+			"domainStrategy": nil, "domainMatcher": nil,
+			"rules": array(ruleSchema), "balancers": array(balancerSchema),
+		}),
+		"policy":           keys("levels", "system"),
+		"inbounds":         array(inboundSchema),
+		"outbounds":        array(outboundSchema),
+		"observatory":      keys("subjectSelector", "probeURL", "probeUrl", "probeInterval", "enableConcurrency"),
+		"burstObservatory": keys("subjectSelector", "pingConfig"),
+		"stats":            nil,
+		"reverse":          nil,
+		"fakedns":          nil,
+		"metrics":          nil,
+		"transport":        nil,
+		"version":          nil,
+	})
+
+	// webuiRulesSchema WebUI 的 routing_rules.json (规则数组，附加 name/enabled/visible)
+	webuiRulesSchema = array(ruleSchema.with(map[string]*schema{"name": nil, "enabled": nil, "visible": nil}))
+)
+
+// checkFields 按 schema 检查未知字段
+func checkFields(s *schema, raw json.RawMessage, path, source string) []Issue {
+	if s == nil {
+		return nil
+	}
+
+	if s.items != nil {
+		var items []json.RawMessage
+		if json.Unmarshal(raw, &items) != nil {
+			return nil
+		}
+		var issues []Issue
+		for i, item := range items {
+			issues = append(issues, checkFields(s.items, item, fmt.Sprintf("%s[%d]", path, i), source)...)
+		}
+		return issues
+	}
+
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(raw, &obj) != nil {
+		// DNS 服务器等允许字符串写法
+		return nil
+	}
+
+	names := make([]string, 0, len(obj))
+	for k := range obj {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var issues []Issue
+	for _, k := range names {
+		child := joinPath(path, k)
+		sub, known := s.fields[k]
+		if !known {
+			msg := fmt.Sprintf("未知字段 %q", child)
+			if hint := closestField(k, s.fields); hint != "" {
+				msg += fmt.Sprintf("，是否应为 %q?", hint)
+			}
+			issues = append(issues, Issue{Level: LevelWarn, Check: IssueUnknownField, Source: source, Path: child, Message: msg})
+			continue
+		}
+		issues = append(issues, checkFields(sub, obj[k], child, source)...)
+	}
+	return issues
+}
+
+// joinPath 拼接 JSON 路径
+func joinPath(base, key string) string {
+	if base == "" {
+		return key
+	}
+	return base + "." + key
+}
+
+// closestField 返回编辑距离最近的已知字段 (距离不超过 2)
+func closestField(name string, fields map[string]*schema) string {
+	best, bestDist := "", 3
+	for k := range fields {
+		if d := editDistance(name, k); d < bestDist || d == bestDist && k < best {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance 计算大小写不敏感的编辑距离
+func editDistance(a, b string) int {
+	ra, rb := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"proxylink/pkg/geo"
+	"proxylink/pkg/routing"
+	"proxylink/pkg/xrayconf"
+)
+
+// routeCommands route 子命令表
+var routeCommands = map[string]func(args []string) error{
+	"test": runRouteTest,
+}
+
+// runRoute 处理 route 子命令
+func runRoute(args []string) error {
+	if len(args) > 0 {
+		if run, ok := routeCommands[args[0]]; ok {
+			return run(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, `用法:
+  proxylink route test <domain|ip[:port]> [选项]    模拟生效路由，输出命中的规则和出站`)
+	if len(args) == 0 {
+		return fmt.Errorf("缺少 route 子命令")
+	}
+	return fmt.Errorf("未知 route 子命令: %s", args[0])
+}
+
+// routeResult 单个目标的模拟结果
+type routeResult struct {
+	*routing.Decision
+	Default    bool     `json:"default,omitempty"` // 未命中规则，使用默认出站
+	Candidates []string `json:"candidates,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// runRouteTest 模拟路由匹配
+func runRouteTest(args []string) error {
+	fs := flag.NewFlagSet("route test", flag.ExitOnError)
+	source := addEffectiveFlags(fs)
+	network := fs.String("network", "tcp", "网络: tcp 或 udp")
+	inbound := fs.String("inbound", "tproxy-in", "入站 tag")
+	batch := fs.String("file", "", "批量模式: 每行一个目标，可附带网络和入站 (- 为标准输入)")
+	assets := fs.String("assets", "", "geosite/geoip dat 文件目录 (默认 <module>/bin)")
+	resolve := fs.Bool("resolve", false, "domainStrategy 为 IPIfNonMatch/IPOnDemand 时使用系统 DNS 解析域名")
+	jsonOutput := fs.Bool("json", false, "以 JSON 输出")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `用法:
+  proxylink route test <domain|ip[:port]>... [选项]
+  proxylink route test -file targets.txt [选项]
+
+按生效配置 (与 assemble 相同的合并方式) 中的路由规则顺序匹配目标，
+输出第一条命中的规则及出站；未命中时使用第一个出站。
+未指定端口时按 443 处理。批量文件每行格式: <目标> [tcp|udp] [入站 tag]，# 开头为注释。
+
+选项:`)
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if *network != "tcp" && *network != "udp" {
+		return fmt.Errorf("network 必须为 tcp 或 udp: %s", *network)
+	}
+	if len(positional) == 0 && *batch == "" {
+		fs.Usage()
+		return fmt.Errorf("缺少目标")
+	}
+
+	cfg, _, _, err := source.load()
+	if err != nil {
+		return err
+	}
+	r, err := loadRouteRules(cfg, source.layout())
+	if err != nil {
+		return err
+	}
+
+	assetDir := *assets
+	if assetDir == "" {
+		assetDir = filepath.Join(source.layout().Dir, "bin")
+	}
+	opts := routing.SimulateOptions{DomainStrategy: r.DomainStrategy, Geo: geo.NewStore(assetDir)}
+	if *resolve {
+		opts.Resolve = func(domain string) ([]net.IP, error) {
+			return net.DefaultResolver.LookupIP(context.Background(), "ip", domain)
+		}
+	}
+
+	lines := positional
+	if *batch != "" {
+		more, err := readTargetLines(*batch)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, more...)
+	}
+
+	var results []routeResult
+	failed := 0
+	for _, line := range lines {
+		result := testRoute(cfg, r, line, *network, *inbound, opts)
+		if result.Error != "" {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		for _, result := range results {
+			printRouteResult(os.Stdout, result)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 个目标无法解析", failed)
+	}
+	return nil
+}
+
+// routeRules 生效路由规则及名称
+type routeRules struct {
+	DomainStrategy string
+	Rules          []routing.Rule
+	Names          []string
+	Balancers      []xrayconf.Balancer
+}
+
+// loadRouteRules 从合并后的配置读取路由规则
+// 规则本身没有 name 时，若与 routing_rules.json 编译结果一致则沿用其中的名称
+func loadRouteRules(cfg *xrayconf.Config, layout moduleLayout) (*routeRules, error) {
+	raw, ok := cfg.Fields["routing"]
+	if !ok {
+		return &routeRules{}, nil
+	}
+	var section struct {
+		DomainStrategy string              `json:"domainStrategy"`
+		Rules          []routing.UIRule    `json:"rules"`
+		Balancers      []xrayconf.Balancer `json:"balancers"`
+	}
+	if err := json.Unmarshal(raw, &section); err != nil {
+		return nil, fmt.Errorf("%s: routing: %v", cfg.Sources["routing"], err)
+	}
+
+	r := &routeRules{DomainStrategy: section.DomainStrategy, Balancers: section.Balancers}
+	named := false
+	for _, ui := range section.Rules {
+		r.Rules = append(r.Rules, ui.Rule)
+		r.Names = append(r.Names, ui.Name)
+		named = named || ui.Name != ""
+	}
+	if !named {
+		if names := uiRuleNames(layout, r.Rules); names != nil {
+			r.Names = names
+		}
+	}
+	return r, nil
+}
+
+// uiRuleNames 编译 routing_rules.json，生效规则与编译结果逐条一致时返回规则名称
+// (旧版 WebUI 写入的 rule.json 没有末尾的内置规则，只比较生效规则的条数)
+func uiRuleNames(layout moduleLayout, rules []routing.Rule) []string {
+	data, err := os.ReadFile(filepath.Join(layout.Confdir, "routing", "routing_rules.json"))
+	if err != nil {
+		return nil
+	}
+	uiRules, err := routing.ParseRules(data)
+	if err != nil {
+		return nil
+	}
+	compiled, err := routing.Compile(uiRules, "")
+	if err != nil || len(compiled.Routing.Rules) < len(rules) {
+		return nil
+	}
+	for i := range rules {
+		a, _ := json.Marshal(rules[i])
+		b, _ := json.Marshal(compiled.Routing.Rules[i])
+		if string(a) != string(b) {
+			return nil
+		}
+	}
+	return compiled.Routing.Names[:len(rules)]
+}
+
+// testRoute 模拟单行目标
+func testRoute(cfg *xrayconf.Config, r *routeRules, line, network, inbound string, opts routing.SimulateOptions) routeResult {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return routeResult{Decision: &routing.Decision{Index: -1}, Error: "目标为空"}
+	}
+	target, err := routing.ParseTarget(fields[0])
+	if err != nil {
+		return routeResult{Decision: &routing.Decision{Index: -1}, Error: err.Error()}
+	}
+	target.Network = network
+	target.InboundTag = inbound
+	for _, f := range fields[1:] {
+		if f == "tcp" || f == "udp" {
+			target.Network = f
+		} else {
+			target.InboundTag = f
+		}
+	}
+
+	result := routeResult{Decision: routing.Simulate(r.Rules, r.Names, target, opts)}
+	if !result.Matched {
+		result.Default = true
+		if len(cfg.Outbounds) > 0 {
+			result.OutboundTag = cfg.Outbounds[0].Tag
+		}
+	}
+	if result.BalancerTag != "" {
+		for _, b := range r.Balancers {
+			if b.Tag == result.BalancerTag {
+				result.Candidates = b.Candidates(cfg.Outbounds)
+			}
+		}
+	}
+	return result
+}
+
+// printRouteResult 以文本输出模拟结果
+func printRouteResult(w io.Writer, result routeResult) {
+	if result.Error != "" {
+		fmt.Fprintf(w, "错误: %s\n", result.Error)
+		return
+	}
+
+	outbound := result.OutboundTag
+	if result.BalancerTag != "" {
+		outbound = fmt.Sprintf("负载均衡 %s [%s]", result.BalancerTag, strings.Join(result.Candidates, ", "))
+	}
+	via := "默认出站"
+	if !result.Default {
+		via = fmt.Sprintf("规则 #%d", result.Index+1)
+		if result.Name != "" {
+			via += " " + result.Name
+		}
+	}
+	if result.Resolved {
+		via += ", 解析域名后命中"
+	}
+	fmt.Fprintf(w, "%s (入站 %s) → %s (%s)\n", result.Target, result.Target.InboundTag, outbound, via)
+	for _, note := range result.Notes {
+		fmt.Fprintf(w, "  ! %s\n", note)
+	}
+}
+
+// readTargetLines 读取批量目标，跳过空行和注释
+func readTargetLines(path string) ([]string, error) {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// parseInterspersed 允许选项出现在位置参数之后 (如 route test example.com -network udp)
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"proxylink/pkg/routing"
+)
+
+// routingCommands routing 子命令表
+var routingCommands = map[string]func(args []string) error{
+	"build":  runRoutingBuild,
+	"import": runRoutingImport,
+}
+
+// runRouting 处理 routing 子命令
+func runRouting(args []string) error {
+	if len(args) > 0 {
+		if run, ok := routingCommands[args[0]]; ok {
+			return run(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, `用法:
+  proxylink routing build [选项]            将 routing_rules.json 编译为 rule.json
+  proxylink routing import [选项] <file>    将 Clash/Surge/sing-box 规则集转换为 routing_rules.json 条目`)
+	if len(args) == 0 {
+		return fmt.Errorf("缺少 routing 子命令")
+	}
+	return fmt.Errorf("未知 routing 子命令: %s", args[0])
+}
+
+// runRoutingBuild 编译 routing_rules.json
+func runRoutingBuild(args []string) error {
+	fs := flag.NewFlagSet("routing build", flag.ExitOnError)
+	moduleDir := fs.String("module", defaultModuleDir, "模块目录")
+	input := fs.String("in", "", "规则文件 (默认 <confdir>/routing/routing_rules.json)")
+	output := fs.String("o", "", "输出文件 (默认 <confdir>/routing/rule.json，- 为标准输出)")
+	domainStrategy := fs.String("domain-strategy", routing.DefaultDomainStrategy, "routing.domainStrategy: AsIs, IPIfNonMatch, IPOnDemand")
+	checkOnly := fs.Bool("check", false, "仅校验，不写入文件")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `用法:
+  proxylink routing build [选项]
+
+校验 routing_rules.json 中每条规则，跳过禁用的规则，去掉 name/enabled/visible，
+DNS 劫持和 API 规则排在最前，原子写入 rule.json。
+
+选项:`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch *domainStrategy {
+	case "AsIs", "IPIfNonMatch", "IPOnDemand":
+	default:
+		return fmt.Errorf("未知 domainStrategy: %s", *domainStrategy)
+	}
+
+	routingDir := filepath.Join(newModuleLayout(*moduleDir).Confdir, "routing")
+	in := *input
+	if in == "" {
+		in = filepath.Join(routingDir, "routing_rules.json")
+	}
+	out := *output
+	if out == "" {
+		out = filepath.Join(routingDir, "rule.json")
+	}
+
+	config, content, err := compileRoutingFile(in, *domainStrategy)
+	if err != nil {
+		return err
+	}
+
+	if *checkOnly {
+		fmt.Fprintf(os.Stderr, "校验通过: %d 条规则\n", len(config.Routing.Rules))
+		return nil
+	}
+	if out == "-" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	if err := routing.WriteFileAtomic(out, content); err != nil {
+		return fmt.Errorf("写入 %s 失败: %v", out, err)
+	}
+	fmt.Fprintf(os.Stderr, "已写入 %s: %d 条规则\n", out, len(config.Routing.Rules))
+	return nil
+}
+
+// compileRoutingFile 解析并编译 routing_rules.json，返回编译结果和 rule.json 内容
+func compileRoutingFile(path, domainStrategy string) (*routing.Config, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules, parseErr := routing.ParseRules(data)
+	parseErrs, ok := parseErr.(routing.Errors)
+	if parseErr != nil && !ok {
+		return nil, nil, parseErr
+	}
+	config, err := routing.Compile(rules, domainStrategy)
+	if errs := parseErrs.Merge(err); len(errs) > 0 {
+		return nil, nil, errs
+	}
+	content, err := config.Marshal()
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, content, nil
+}
+
+// runRoutingImport 导入第三方规则集
+func runRoutingImport(args []string) error {
+	fs := flag.NewFlagSet("routing import", flag.ExitOnError)
+	moduleDir := fs.String("module", defaultModuleDir, "模块目录")
+	format := fs.String("format", "", "规则集格式: clash, surge, sing-box (默认自动识别)")
+	policyMap := fs.String("map", "", "策略映射，如 \"Proxy=proxy,Netflix=hk,AdBlock=block\" (不区分大小写)")
+	outbound := fs.String("outbound", "proxy", "规则集不带策略或策略未映射时使用的出站")
+	name := fs.String("name", "", "规则名称前缀 (默认文件名)")
+	output := fs.String("o", "", "输出文件 (默认标准输出)")
+	merge := fs.Bool("merge", false, "合并到 <confdir>/routing/routing_rules.json (替换同一前缀的旧规则，否则插在兜底规则之前)")
+	strict := fs.Bool("strict", false, "存在无法转换的规则时失败")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `用法:
+  proxylink routing import [选项] <file|->
+
+将 Clash classical (payload/rules/.list)、Surge 规则集与域名集、sing-box 源规则集
+转换为 routing_rules.json 条目：相邻的、条件类型与出站相同的规则合并为一条，
+保持原文件的先后，带 name/enabled/visible 字段。DIRECT → direct，REJECT* → block，其余策略用 -map 映射，
+未映射时使用 -outbound 并输出警告 (-strict 时失败)。不支持的规则 (PROCESS-NAME、USER-AGENT、
+逻辑规则等) 逐条报告。
+
+格式默认按结构识别，不带 payload:/rules: 的 +. 域名集需用 -format 指定。
+
+选项:`)
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return fmt.Errorf("需要一个规则集文件")
+	}
+	if *merge && *output != "" {
+		return fmt.Errorf("-merge 与 -o 不能同时使用")
+	}
+
+	input := positional[0]
+	var data []byte
+	if input == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(input)
+	}
+	if err != nil {
+		return err
+	}
+
+	policies := map[string]string{}
+	for _, pair := range strings.Split(*policyMap, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		policy, tag, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(policy) == "" || strings.TrimSpace(tag) == "" {
+			return fmt.Errorf("无效的策略映射 %q，格式为 策略=出站", pair)
+		}
+		policies[strings.TrimSpace(policy)] = strings.TrimSpace(tag)
+	}
+
+	prefix := *name
+	if prefix == "" && input != "-" {
+		prefix = strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	}
+	result, err := routing.Import(data, routing.ImportOptions{
+		Format:   *format,
+		Name:     prefix,
+		Outbound: *outbound,
+		Policies: policies,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range result.Skipped {
+		fmt.Fprintf(os.Stderr, "跳过 %s:%s\n", input, issue)
+	}
+	for _, policy := range sortedPolicyNames(result.Policies) {
+		fmt.Fprintf(os.Stderr, "策略 %s → %s\n", policy, result.Policies[policy])
+	}
+	for _, policy := range result.Unmapped {
+		fmt.Fprintf(os.Stderr, "警告: 策略 %s 没有 -map 映射，使用 %s\n", policy, result.Policies[policy])
+	}
+	if *strict && len(result.Skipped) > 0 {
+		return fmt.Errorf("%d 条规则无法转换", len(result.Skipped))
+	}
+	if *strict && len(result.Unmapped) > 0 {
+		return fmt.Errorf("%d 个策略没有映射: %s", len(result.Unmapped), strings.Join(result.Unmapped, ", "))
+	}
+	if len(result.Rules) == 0 {
+		return fmt.Errorf("没有可导入的规则")
+	}
+
+	if *merge {
+		path := filepath.Join(newModuleLayout(*moduleDir).Confdir, "routing", "routing_rules.json")
+		if _, err := mergeRoutingRules(path, prefix, result.Rules, false); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "已合并 %d 条规则到 %s，执行 proxylink routing build 或在 WebUI 中应用后生效\n", len(result.Rules), path)
+		return nil
+	}
+
+	content, err := marshalRoutingRules(rulesToRaw(result.Rules))
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	if err := routing.WriteFileAtomic(*output, content); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "已写入 %s: %d 条规则 (%s)\n", *output, len(result.Rules), result.Format)
+	return nil
+}
+
+// syncRoutingRules 将名称以 prefix 开头的生成规则放在 routing_rules.json 最前 (已有时原位替换)，
+// 文件有变化时重新编译 rule.json
+func syncRoutingRules(layout moduleLayout, prefix string, rules []*routing.UIRule) error {
+	routingDir := filepath.Join(layout.Confdir, "routing")
+	rulesFile := filepath.Join(routingDir, "routing_rules.json")
+	if _, err := os.Stat(rulesFile); os.IsNotExist(err) && len(rules) == 0 {
+		return nil
+	}
+	changed, err := mergeRoutingRules(rulesFile, prefix, rules, true)
+	if err != nil || !changed {
+		return err
+	}
+
+	// 沿用 rule.json 现有的 domainStrategy
+	ruleFile := filepath.Join(routingDir, "rule.json")
+	domainStrategy := routing.DefaultDomainStrategy
+	if data, err := os.ReadFile(ruleFile); err == nil {
+		var current routing.Config
+		if json.Unmarshal(data, &current) == nil && current.Routing.DomainStrategy != "" {
+			domainStrategy = current.Routing.DomainStrategy
+		}
+	}
+	config, content, err := compileRoutingFile(rulesFile, domainStrategy)
+	if err != nil {
+		return fmt.Errorf("%s: %v", rulesFile, err)
+	}
+	if err := routing.WriteFileAtomic(ruleFile, content); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "已更新 %s: %d 条规则\n", ruleFile, len(config.Routing.Rules))
+	return nil
+}
+
+// mergeRoutingRules 将导入的规则合并到 routing_rules.json
+// 已有的同一前缀规则 (上次导入的结果) 整体替换到原位置；否则插在最前 (front) 或末尾的兜底规则 (port 0-65535) 之前
+// 内容没有变化时不写入，返回是否写入
+func mergeRoutingRules(path, prefix string, rules []*routing.UIRule, front bool) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), &items); err != nil {
+		return false, fmt.Errorf("%s 必须是规则数组: %v", path, err)
+	}
+
+	imported := rulesToRaw(rules)
+	names := map[string]bool{}
+	for _, raw := range imported {
+		names[rawRuleName(raw)] = true
+	}
+	previous := func(raw json.RawMessage) bool {
+		name := rawRuleName(raw)
+		return names[name] || (prefix != "" && strings.HasPrefix(name, prefix+": "))
+	}
+
+	var merged []json.RawMessage
+	at := -1
+	for _, item := range items {
+		if previous(item) {
+			if at < 0 {
+				at = len(merged)
+			}
+			continue
+		}
+		merged = append(merged, item)
+	}
+	if at < 0 && len(imported) == 0 {
+		return false, nil
+	}
+	if at < 0 && front {
+		at = 0
+	} else if at < 0 {
+		at = len(merged)
+		if at > 0 && isCatchAll(merged[at-1]) {
+			at--
+		}
+	}
+	merged = append(merged[:at], append(imported, merged[at:]...)...)
+
+	content, err := marshalRoutingRules(merged)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(content, data) {
+		return false, nil
+	}
+	return true, routing.WriteFileAtomic(path, content)
+}
+
+func rawRuleName(raw json.RawMessage) string {
+	var head struct {
+		Name string `json:"name"`
+	}
+	json.Unmarshal(raw, &head)
+	return head.Name
+}
+
+// isCatchAll 判断是否为只按全部端口匹配的兜底规则
+func isCatchAll(raw json.RawMessage) bool {
+	var r routing.UIRule
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return false
+	}
+	rest := r.Rule
+	rest.Port, rest.Type, rest.OutboundTag, rest.BalancerTag = "", "", "", ""
+	empty, _ := json.Marshal(rest)
+	return (r.Port == "0-65535" || r.Port == "1-65535") && string(empty) == "{}"
+}
+
+func rulesToRaw(rules []*routing.UIRule) []json.RawMessage {
+	items := make([]json.RawMessage, 0, len(rules))
+	for _, r := range rules {
+		data, _ := json.Marshal(r)
+		items = append(items, data)
+	}
+	return items
+}
+
+// marshalRoutingRules 按 WebUI 保存的格式 (4 空格缩进) 输出规则数组
+func marshalRoutingRules(items []json.RawMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(items); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func sortedPolicyNames(policies map[string]string) []string {
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"proxylink/pkg/appgroup"
+	"proxylink/pkg/packages"
+	"proxylink/pkg/tproxy"
+)
+
+// tproxyCommands tproxy 子命令表
+var tproxyCommands = map[string]func(args []string) error{
+	"plan":  runTproxyPlan,
+	"check": runTproxyCheck,
+}
+
+// runTproxy 处理 tproxy 子命令
+func runTproxy(args []string) error {
+	if len(args) > 0 {
+		if run, ok := tproxyCommands[args[0]]; ok {
+			return run(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, `用法:
+  proxylink tproxy plan [选项]    按 tproxy.conf 输出 tproxy.sh start 将执行的全部 iptables/ip 规则
+  proxylink tproxy check [选项]   校验 tproxy.conf`)
+	if len(args) == 0 {
+		return fmt.Errorf("缺少 tproxy 子命令")
+	}
+	return fmt.Errorf("未知 tproxy 子命令: %s", args[0])
+}
+
+// tproxyConfPath 返回 -conf 指定的路径，未指定时使用模块内的 tproxy.conf
+func tproxyConfPath(moduleDir, conf string) string {
+	if conf != "" {
+		return conf
+	}
+	return newModuleLayout(moduleDir).TproxyConf
+}
+
+// loadTproxyConfig 读取并校验 tproxy.conf，输出全部校验错误
+func loadTproxyConfig(path string) (*tproxy.Config, error) {
+	config, err := tproxy.Load(path)
+	if errs, ok := err.(tproxy.Errors); ok {
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", e)
+		}
+		return nil, fmt.Errorf("%s: %d 项配置无效", path, len(errs))
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range config.Warnings {
+		fmt.Fprintf(os.Stderr, "警告: %s\n", w)
+	}
+	return config, nil
+}
+
+// runTproxyPlan 输出规则计划
+func runTproxyPlan(args []string) error {
+	fs := flag.NewFlagSet("tproxy plan", flag.ExitOnError)
+	moduleDir := fs.String("module", defaultModuleDir, "模块目录")
+	confPath := fs.String("conf", "", "tproxy.conf 路径 (默认模块内)，同目录的 app_groups.conf 一并读取")
+	mode := fs.String("mode", "", "透明代理方式: tproxy, redirect (默认按 PROXY_MODE，自动模式视为 tproxy)")
+	family := fs.String("family", "all", "协议族: 4, 6, all")
+	restore := fs.Bool("restore", false, "以 iptables-restore 格式输出")
+	asJSON := fs.Bool("json", false, "以 JSON 输出")
+	packageList := fs.String("packages", packages.DefaultPath, "packages.list 路径 (解析分应用代理的 UID)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `用法:
+  proxylink tproxy plan [选项]
+
+按 tproxy.conf 输出 tproxy.sh start 将依次执行的 iptables/ip6tables/ip 命令，顺序与脚本一致，
+可用于对比配置修改前后的差异。假定内核支持所需特性且 cnip/cnip6 ipset 已创建 (同 --dry-run)。
+-restore 按表输出 iptables-restore 格式，ip rule/route 和打开转发的 echo 以注释列出，需另行执行:
+  proxylink tproxy plan -restore -family 4 | iptables-restore --noflush
+
+选项:`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var families []int
+	switch *family {
+	case "4":
+		families = []int{4}
+	case "6":
+		families = []int{6}
+	case "all":
+		families = []int{4, 6}
+	default:
+		return fmt.Errorf("未知协议族: %s", *family)
+	}
+	opts := tproxy.PlanOptions{Mode: tproxy.Mode(*mode)}
+	if opts.Mode != "" && opts.Mode != tproxy.ModeTProxy && opts.Mode != tproxy.ModeRedirect {
+		return fmt.Errorf("未知透明代理方式: %s", *mode)
+	}
+
+	conf := tproxyConfPath(*moduleDir, *confPath)
+	config, err := loadTproxyConfig(conf)
+	if err != nil {
+		return err
+	}
+	if *family == "6" && config.IPv6 != 1 {
+		fmt.Fprintln(os.Stderr, "警告: PROXY_IPV6 不为 1，IPv6 只有回环保护规则")
+	}
+
+	if config.AppProxy {
+		tokens := config.BypassApps
+		if config.AppProxyMode == "whitelist" {
+			tokens = config.ProxyApps
+		}
+		uids := resolvePlanApps(*packageList, tokens)
+		if config.AppProxyMode == "whitelist" {
+			opts.ProxyUIDs = uids
+		} else {
+			opts.BypassUIDs = uids
+		}
+	}
+	if opts.AppGroups, err = appgroup.ReadMap(filepath.Join(filepath.Dir(conf), appgroup.MapName)); err != nil {
+		return err
+	}
+
+	plan := tproxy.BuildPlan(config, opts)
+	for _, w := range plan.Warnings {
+		fmt.Fprintf(os.Stderr, "警告: %s\n", w)
+	}
+	if len(families) == 1 {
+		plan = plan.Filter(families[0])
+	}
+
+	switch {
+	case *asJSON:
+		return printJSON(plan)
+	case *restore:
+		for _, f := range families {
+			if len(families) > 1 {
+				tool := "iptables-restore"
+				if f == 6 {
+					tool = "ip6tables-restore"
+				}
+				fmt.Printf("# %s --noflush\n", tool)
+			}
+			fmt.Print(plan.Restore(f))
+		}
+	default:
+		for _, cmd := range plan.Commands {
+			fmt.Println(cmd)
+		}
+	}
+	return nil
+}
+
+// resolvePlanApps 将应用列表解析为 UID，无法解析的应用输出警告并跳过 (与 tproxy.sh 一致)
+func resolvePlanApps(path string, tokens []string) []int {
+	if len(tokens) == 0 {
+		return nil
+	}
+	list, err := packages.Read(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 无法读取应用列表，分应用规则不含 UID: %v\n", err)
+		return nil
+	}
+	var uids []int
+	for _, token := range tokens {
+		uid, err := list.Resolve(token)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+			continue
+		}
+		uids = append(uids, uid)
+	}
+	return uids
+}
+
+// runTproxyCheck 校验 tproxy.conf
+func runTproxyCheck(args []string) error {
+	fs := flag.NewFlagSet("tproxy check", flag.ExitOnError)
+	moduleDir := fs.String("module", defaultModuleDir, "模块目录")
+	confPath := fs.String("conf", "", "tproxy.conf 路径 (默认模块内)")
+	asJSON := fs.Bool("json", false, "以 JSON 输出解析后的配置")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conf := tproxyConfPath(*moduleDir, *confPath)
+	config, err := loadTproxyConfig(conf)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(config)
+	}
+	fmt.Printf("%s 校验通过: %s 模式，TCP %d / UDP %d，DNS %d，IPv6 %d\n",
+		conf, config.Mode(), config.TCPPort, config.UDPPort, config.DNSPort, config.IPv6)
+	return nil
+}